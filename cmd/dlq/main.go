@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"notificationservice/internal/config"
+	"notificationservice/internal/rabbitmq"
+)
+
+const usage = `Usage: dlq <command> [flags]
+
+Commands:
+  list     show dead-lettered messages grouped by error type
+  replay   publish matching messages back to the main exchange
+  purge    permanently delete matching messages
+
+Run "dlq <command> -h" for the flags of each command.
+`
+
+type options struct {
+	filter   rabbitmq.DeadLetterFilter
+	all      bool
+	edit     bool
+	bodyFile string
+	dryRun   bool
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	opts, err := parseFlags(command, os.Args[2:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	inspector := rabbitmq.NewDeadLetterInspector(
+		cfg.RabbitMQ.URI,
+		cfg.RabbitMQ.Exchange,
+		cfg.RabbitMQ.RoutingKey,
+		rabbitmq.DeadLetterConfig{
+			ExchangeName: cfg.RabbitMQ.DeadLetterQueue.Exchange,
+			QueueName:    cfg.RabbitMQ.DeadLetterQueue.Queue,
+			RoutingKey:   cfg.RabbitMQ.DeadLetterQueue.RoutingKey,
+		},
+	)
+	if err := inspector.Connect(); err != nil {
+		log.Fatal(err)
+	}
+	defer inspector.Close()
+
+	messages, err := inspector.Fetch(opts.filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch command {
+	case "list":
+		list(messages)
+	case "replay":
+		err = replay(inspector, messages, opts)
+	case "purge":
+		err = purge(inspector, messages, opts)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseFlags(command string, args []string) (options, error) {
+	var opts options
+	var ids, since string
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.StringVar(&ids, "id", "", "comma-separated message IDs to select")
+	flags.StringVar(&opts.filter.ErrorType, "type", "", "select messages with this x-error-type")
+	flags.StringVar(&opts.filter.Contains, "contains", "", "select messages whose payload or error message contains this text")
+	flags.StringVar(&since, "since", "", "select messages dead-lettered within this duration, e.g. 2h")
+
+	switch command {
+	case "list":
+	case "replay":
+		flags.BoolVar(&opts.all, "all", false, "allow replaying without filters, selecting every message")
+		flags.BoolVar(&opts.edit, "edit", false, "open each payload in $EDITOR before replaying")
+		flags.StringVar(&opts.bodyFile, "body-file", "", "replace the payload of the one selected message with this file")
+		flags.BoolVar(&opts.dryRun, "dry-run", false, "show what would be replayed without changing anything")
+	case "purge":
+		flags.BoolVar(&opts.all, "all", false, "allow purging without filters, selecting every message")
+		flags.BoolVar(&opts.dryRun, "dry-run", false, "show what would be purged without changing anything")
+	default:
+		return opts, fmt.Errorf("unknown command %q\n\n%s", command, usage)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if ids != "" {
+		for _, id := range strings.Split(ids, ",") {
+			opts.filter.IDs = append(opts.filter.IDs, strings.TrimSpace(id))
+		}
+	}
+	if since != "" {
+		duration, err := time.ParseDuration(since)
+		if err != nil {
+			return opts, fmt.Errorf("invalid -since: %w", err)
+		}
+		opts.filter.Since = time.Now().Add(-duration)
+	}
+
+	hasFilter := len(opts.filter.IDs) > 0 || opts.filter.ErrorType != "" || opts.filter.Contains != "" || since != ""
+	if command != "list" && !hasFilter && !opts.all {
+		return opts, fmt.Errorf("%s needs at least one filter (-id, -type, -contains, -since) or -all", command)
+	}
+	if opts.edit && opts.bodyFile != "" {
+		return opts, fmt.Errorf("-edit and -body-file cannot be combined")
+	}
+	return opts, nil
+}
+
+func list(messages []rabbitmq.DeadLetterMessage) {
+	if len(messages) == 0 {
+		fmt.Println("No dead-lettered messages match")
+		return
+	}
+
+	groups := make(map[string][]rabbitmq.DeadLetterMessage)
+	for _, message := range messages {
+		groups[message.ErrorType] = append(groups[message.ErrorType], message)
+	}
+	errorTypes := make([]string, 0, len(groups))
+	for errorType := range groups {
+		errorTypes = append(errorTypes, errorType)
+	}
+	sort.Strings(errorTypes)
+
+	for _, errorType := range errorTypes {
+		group := groups[errorType]
+		if errorType == "" {
+			errorType = "(none)"
+		}
+		fmt.Printf("== %s (%d)\n", errorType, len(group))
+		for _, message := range group {
+			fmt.Printf("\nID:       %s\n", message.ID)
+			if !message.FailedAt.IsZero() {
+				fmt.Printf("Failed:   %s\n", message.FailedAt.Format(time.RFC3339))
+			}
+			fmt.Printf("Error:    %s\n", message.ErrorMessage)
+			if message.Attempts > 0 {
+				fmt.Printf("Attempts: %d\n", message.Attempts)
+			}
+			fmt.Printf("Payload:\n%s\n", formatPayload(message.Body))
+		}
+		fmt.Println()
+	}
+}
+
+func replay(inspector *rabbitmq.DeadLetterInspector, messages []rabbitmq.DeadLetterMessage, opts options) error {
+	var replacement []byte
+	if opts.bodyFile != "" {
+		data, err := os.ReadFile(opts.bodyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read body file: %w", err)
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("%s does not contain valid JSON", opts.bodyFile)
+		}
+		replacement = data
+		// Copies of one body share its externalId and would be delivered once
+		if len(messages) > 1 {
+			return fmt.Errorf("-body-file replaces a single message, but %d are selected", len(messages))
+		}
+	}
+
+	replayed := 0
+	for _, message := range messages {
+		body := replacement
+		if opts.edit {
+			edited, err := editPayload(message)
+			if err != nil {
+				log.Printf("Skipping %s: %v", message.ID, err)
+				inspector.Release(message)
+				continue
+			}
+			body = edited
+		}
+
+		if opts.dryRun {
+			fmt.Printf("Would replay %s (%s)\n", message.ID, message.ErrorType)
+			continue
+		}
+		if err := inspector.Replay(message, body); err != nil {
+			return err
+		}
+		fmt.Printf("Replayed %s (%s)\n", message.ID, message.ErrorType)
+		replayed++
+	}
+
+	fmt.Printf("%d message(s) replayed\n", replayed)
+	return nil
+}
+
+func purge(inspector *rabbitmq.DeadLetterInspector, messages []rabbitmq.DeadLetterMessage, opts options) error {
+	purged := 0
+	for _, message := range messages {
+		if opts.dryRun {
+			fmt.Printf("Would purge %s (%s)\n", message.ID, message.ErrorType)
+			continue
+		}
+		if err := inspector.Purge(message); err != nil {
+			return fmt.Errorf("failed to purge %s: %w", message.ID, err)
+		}
+		purged++
+	}
+
+	fmt.Printf("%d message(s) purged\n", purged)
+	return nil
+}
+
+func editPayload(message rabbitmq.DeadLetterMessage) ([]byte, error) {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+
+	file, err := os.CreateTemp("", "dlq-"+message.ID+"-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(formatPayload(message.Body)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	file.Close()
+
+	cmd := exec.Command(editor, file.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor failed: %w", err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(edited) {
+		return nil, fmt.Errorf("edited payload is not valid JSON")
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, edited); err != nil {
+		return nil, err
+	}
+	return compacted.Bytes(), nil
+}
+
+func formatPayload(body []byte) []byte {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return body
+	}
+	return indented.Bytes()
+}
@@ -1,15 +1,32 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	_ "time/tzdata"
 
+	"notificationservice/internal/api"
+	"notificationservice/internal/auth"
+	"notificationservice/internal/chat"
 	"notificationservice/internal/config"
+	"notificationservice/internal/email"
 	"notificationservice/internal/handlers"
+	"notificationservice/internal/hub"
+	"notificationservice/internal/models"
+	"notificationservice/internal/outbound"
+	"notificationservice/internal/push"
 	"notificationservice/internal/rabbitmq"
 	"notificationservice/internal/repository"
+	"notificationservice/internal/scheduler"
+	"notificationservice/internal/server"
+	"notificationservice/internal/sms"
+	"notificationservice/internal/templates"
 )
 
 func main() {
@@ -24,8 +41,146 @@ func main() {
     if err != nil {
         log.Fatalf("Failed to connect to MongoDB: %v", err)
     }
+    if err := mongoRepo.EnsureIndexes(); err != nil {
+        log.Fatalf("Failed to create notification indexes: %v", err)
+    }
+    if cfg.MongoDB.ExpiredRetention > 0 {
+        if err := mongoRepo.EnsureExpiryIndex(cfg.MongoDB.ExpiredRetention); err != nil {
+            log.Fatalf("Failed to create expiry index: %v", err)
+        }
+    }
+
+    emailLimits := email.DefaultLimits()
+    if cfg.SMTP.MaxAttachments > 0 {
+        emailLimits.MaxAttachments = cfg.SMTP.MaxAttachments
+    }
+    if cfg.SMTP.MaxAttachmentSize > 0 {
+        emailLimits.MaxAttachmentSize = int64(cfg.SMTP.MaxAttachmentSize)
+    }
+    if cfg.SMTP.MaxMessageSize > 0 {
+        emailLimits.MaxMessageSize = int64(cfg.SMTP.MaxMessageSize)
+    }
+    if emailLimits.MaxMessageSize > email.MaxMessageSizeCeiling {
+        log.Fatalf("SMTP_MAX_MESSAGE_SIZE must not exceed %d bytes", email.MaxMessageSizeCeiling)
+    }
+
+    attachmentRepo := repository.NewMongoAttachmentRepository(mongoRepo.Database())
+    if err := attachmentRepo.EnsureIndexes(cfg.MongoDB.AttachmentRetention); err != nil {
+        log.Fatalf("Failed to create attachment indexes: %v", err)
+    }
+
+    emailSender := email.NewSMTPSender(email.SMTPConfig{
+        Host:          cfg.SMTP.Host,
+        Port:          cfg.SMTP.Port,
+        Username:      cfg.SMTP.Username,
+        Password:      cfg.SMTP.Password,
+        From:          cfg.SMTP.From,
+        Encryption:    email.Encryption(cfg.SMTP.Encryption),
+        AuthMechanism: email.AuthMechanism(cfg.SMTP.AuthMechanism),
+        Limits:        emailLimits,
+        Fetcher:       email.NewHTTPFetcher(emailLimits.MaxAttachmentSize, outbound.Policy{AllowedHosts: cfg.SMTP.AttachmentHosts}),
+    })
+
+    if cfg.Server.AuthTokenSecret == "" {
+        log.Fatalf("AUTH_TOKEN_SECRET is required to authenticate API and websocket requests")
+    }
+    authenticator := auth.NewTokenAuthenticator([]byte(cfg.Server.AuthTokenSecret))
+    notificationHub := hub.NewHub(authenticator)
+
+    renderer, err := newTemplateRenderer(cfg, mongoRepo)
+    if err != nil {
+        log.Fatalf("Failed to set up templates: %v", err)
+    }
+
+    smsChannel := handlers.SMSChannel(sms.NewHTTPProvider(sms.HTTPProviderConfig{
+        URL:     cfg.SMS.ProviderURL,
+        APIKey:  cfg.SMS.APIKey,
+        From:    cfg.SMS.From,
+        Timeout: cfg.SMS.Timeout,
+    }), handlers.SMSOptions{MaxSegments: cfg.SMS.MaxSegments})
+    smsChannel.Config.Disabled = cfg.SMS.ProviderURL == ""
+
+    deviceRepo := repository.NewMongoDeviceRepository(mongoRepo.Database())
+    if err := deviceRepo.EnsureIndexes(); err != nil {
+        log.Fatalf("Failed to create device indexes: %v", err)
+    }
+    pushProviders, err := newPushProviders(cfg)
+    if err != nil {
+        log.Fatalf("Failed to set up push providers: %v", err)
+    }
+    pushChannel := handlers.PushChannel(deviceRepo, pushProviders)
+    pushChannel.Config.Disabled = len(pushProviders) == 0
+
+    webhookRepo := repository.NewMongoWebhookRepository(mongoRepo.Database())
+    // Webhook URLs come from API callers, so outside development they must be
+    // https and public
+    webhookPolicy := outbound.Policy{}
+    if cfg.Environment == "development" {
+        webhookPolicy = outbound.Policy{AllowHTTP: true, AllowPrivate: true}
+    }
+    if err := webhookRepo.EnsureIndexes(); err != nil {
+        log.Fatalf("Failed to create webhook indexes: %v", err)
+    }
+
+    preferenceRepo := repository.NewMongoPreferenceRepository(mongoRepo.Database())
+    if err := preferenceRepo.EnsureIndexes(); err != nil {
+        log.Fatalf("Failed to create preference indexes: %v", err)
+    }
+
+    chatDestinations, err := newChatDestinations(cfg)
+    if err != nil {
+        log.Fatalf("Failed to set up chat webhooks: %v", err)
+    }
+    chatChannel := handlers.ChatChannel(chatDestinations)
+    chatChannel.Config.Disabled = len(chatDestinations) == 0
+
+    channels := handlers.NewChannelRegistry()
+    err = channels.Register(
+        handlers.EmailChannel(emailSender, handlers.EmailOptions{
+            Limits:               emailLimits,
+            AllowedSenderDomains: cfg.SMTP.AllowedSenderDomains,
+            Attachments:          attachmentRepo,
+        }),
+        handlers.InAppChannel(notificationHub),
+        smsChannel,
+        pushChannel,
+        handlers.WebhookChannel(webhookRepo, mongoRepo, handlers.WebhookOptions{Timeout: cfg.Webhook.Timeout, Policy: webhookPolicy}),
+        chatChannel,
+    )
+    if err != nil {
+        log.Fatalf("Failed to register delivery channels: %v", err)
+    }
+
+    handler := handlers.NewHandler(mongoRepo, deviceRepo, webhookRepo, preferenceRepo, channels, renderer)
+    handler.SetLockedCategories(cfg.Preferences.LockedCategories)
+    handler.SetWebhookPolicy(webhookPolicy)
+    handler.SetAttachmentOptions(handlers.AttachmentOptions{Store: attachmentRepo, Limits: emailLimits})
 
-    handler := handlers.NewHandler(mongoRepo)
+    quietHours, err := loadQuietHours(cfg.Preferences.QuietHoursFile)
+    if err != nil {
+        log.Fatalf("Failed to load quiet hours: %v", err)
+    }
+    handler.SetQuietHours(quietHours)
+
+    digestOptions, err := newDigestOptions(cfg, renderer)
+    if err != nil {
+        log.Fatalf("Invalid digest configuration: %v", err)
+    }
+    handler.SetDigestOptions(digestOptions)
+    notificationHub.SetReadHandler(handler)
+
+    fallbackPolicies, err := loadFallbackPolicies(cfg.Fallback.PolicyFile)
+    if err != nil {
+        log.Fatalf("Failed to load fallback policies: %v", err)
+    }
+    handler.SetFallbackPolicies(fallbackPolicies)
+
+    httpServer := server.NewServer(cfg.Server.Port)
+    httpServer.Handle("GET /ws", notificationHub)
+    api.NewAPI(handler, authenticator, cfg.Server.AdminToken).Register(httpServer)
+    if err := httpServer.Start(); err != nil {
+        log.Fatalf("Failed to start HTTP server: %v", err)
+    }
 
     consumer := rabbitmq.NewConsumer(
         cfg.RabbitMQ.URI,
@@ -33,23 +188,40 @@ func main() {
         cfg.RabbitMQ.Exchange,
         cfg.RabbitMQ.RoutingKey,
         &rabbitmq.ConsumerOptions{
+            PrefetchCount: cfg.RabbitMQ.PrefetchCount,
+            WorkerCount:   cfg.RabbitMQ.WorkerCount,
+            RetryConfig: &rabbitmq.RetryConfig{
+                Delays:      cfg.RabbitMQ.RetryDelays,
+                MaxAttempts: cfg.RabbitMQ.MaxRetries,
+            },
             DeadLetterConfig: &rabbitmq.DeadLetterConfig{
                 ExchangeName: cfg.RabbitMQ.DeadLetterQueue.Exchange,
                 QueueName:    cfg.RabbitMQ.DeadLetterQueue.Queue,
                 RoutingKey:   cfg.RabbitMQ.DeadLetterQueue.RoutingKey,
             },
+            OnConnectionEvent: func(event rabbitmq.ConnectionEvent) {
+                if event.Err != nil {
+                    log.Printf("RabbitMQ connection event: %s (attempt %d): %v", event.Type, event.Attempt, event.Err)
+                    return
+                }
+                log.Printf("RabbitMQ connection event: %s (attempt %d)", event.Type, event.Attempt)
+            },
         },
     )
 
     if err := consumer.Connect(); err != nil {
         log.Fatalf("Failed to connect to RabbitMQ: %v", err)
     }
-    defer consumer.Close()
 
     if err := consumer.Start(handler); err != nil {
         log.Fatalf("Failed to start consuming messages: %v", err)
     }
 
+    fallbackPoller := scheduler.NewPoller("Fallback check", cfg.Fallback.CheckInterval, handler.ProcessDueFallbacks)
+    fallbackPoller.Start()
+    releasePoller := scheduler.NewPoller("Scheduled delivery", cfg.Scheduler.Interval, handler.ReleaseDue)
+    releasePoller.Start()
+
     log.Printf("Server started successfully")
 
     quit := make(chan os.Signal, 1)
@@ -57,4 +229,186 @@ func main() {
     <-quit
 
     log.Println("Shutting down server...")
+
+    ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+    defer cancel()
+
+    if err := consumer.Shutdown(ctx); err != nil {
+        log.Printf("Consumer shutdown incomplete: %v", err)
+    }
+    if err := fallbackPoller.Shutdown(ctx); err != nil {
+        log.Printf("Fallback check shutdown incomplete: %v", err)
+    }
+    if err := releasePoller.Shutdown(ctx); err != nil {
+        log.Printf("Scheduled delivery shutdown incomplete: %v", err)
+    }
+
+    notificationHub.Close()
+    if err := httpServer.Shutdown(ctx); err != nil {
+        log.Printf("HTTP server shutdown incomplete: %v", err)
+    }
+
+    if err := mongoRepo.Close(ctx); err != nil {
+        log.Printf("Failed to disconnect from MongoDB: %v", err)
+    }
+
+    log.Println("Server stopped")
+}
+
+func newTemplateRenderer(cfg *config.Config, mongoRepo *repository.MongoRepository) (*templates.Renderer, error) {
+    switch cfg.Templates.Source {
+    case "":
+        return nil, nil
+    case "file":
+        return templates.NewRenderer(templates.NewFileStore(cfg.Templates.Directory)), nil
+    case "mongo":
+        store := templates.NewMongoStore(mongoRepo.Database())
+        if err := store.EnsureIndexes(); err != nil {
+            return nil, err
+        }
+        return templates.NewRenderer(store), nil
+    default:
+        return nil, fmt.Errorf("unknown template source: %s", cfg.Templates.Source)
+    }
+}
+
+// loadFallbackPolicies reads a JSON object mapping categories to fallback
+// chains, e.g. {"security": [{"type": "InApp", "unreadAfter": "15m"}, {"type": "Mail"}]}.
+func loadFallbackPolicies(path string) (map[string][]models.FallbackStep, error) {
+    if path == "" {
+        return nil, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var policies map[string][]models.FallbackStep
+    if err := json.Unmarshal(data, &policies); err != nil {
+        return nil, err
+    }
+    for category, steps := range policies {
+        if len(steps) == 0 {
+            return nil, fmt.Errorf("category %s has an empty fallback chain", category)
+        }
+        if err := models.ValidateFallbackSteps(steps); err != nil {
+            return nil, fmt.Errorf("category %s: %w", category, err)
+        }
+    }
+    return policies, nil
+}
+
+// loadQuietHours reads a JSON object mapping categories to their default quiet
+// hours, e.g. {"marketing": {"start": "21:00", "end": "08:00", "timezone": "Europe/Berlin"}}.
+func loadQuietHours(path string) (map[string]*models.QuietHours, error) {
+    if path == "" {
+        return nil, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var quietHours map[string]*models.QuietHours
+    if err := json.Unmarshal(data, &quietHours); err != nil {
+        return nil, err
+    }
+    for category, window := range quietHours {
+        if window == nil {
+            return nil, fmt.Errorf("category %s has no quiet hours", category)
+        }
+        if err := window.Validate(); err != nil {
+            return nil, fmt.Errorf("category %s: %w", category, err)
+        }
+    }
+    return quietHours, nil
+}
+
+// newDigestOptions reads DIGEST_CATEGORIES entries like "activity=hourly";
+// digests use the "digest" template and daily ones go out at 08:00 UTC unless
+// configured otherwise.
+func newDigestOptions(cfg *config.Config, renderer *templates.Renderer) (handlers.DigestOptions, error) {
+    options := handlers.DigestOptions{
+        Categories: make(map[string]models.DigestFrequency, len(cfg.Digest.Categories)),
+        TemplateID: cfg.Digest.TemplateID,
+        DailyAt:    cfg.Digest.DailyAt,
+    }
+    if options.TemplateID == "" {
+        options.TemplateID = "digest"
+    }
+    if options.DailyAt == "" {
+        options.DailyAt = "08:00"
+    }
+    if _, err := time.Parse("15:04", options.DailyAt); err != nil {
+        return options, fmt.Errorf("invalid DIGEST_DAILY_AT %q: expected HH:MM", options.DailyAt)
+    }
+    for category, frequency := range cfg.Digest.Categories {
+        if !models.DigestFrequency(frequency).IsValid() {
+            return options, fmt.Errorf("category %s has invalid digest frequency %q", category, frequency)
+        }
+        options.Categories[category] = models.DigestFrequency(frequency)
+    }
+    // Without a template every digest would fail when it is due
+    if len(options.Categories) > 0 {
+        if renderer == nil {
+            return options, fmt.Errorf("DIGEST_CATEGORIES needs TEMPLATES_SOURCE to render digests")
+        }
+        found, err := renderer.HasTemplate(options.TemplateID)
+        if err != nil {
+            return options, fmt.Errorf("failed to look up digest template %s: %w", options.TemplateID, err)
+        }
+        if !found {
+            return options, fmt.Errorf("digest template %s does not exist", options.TemplateID)
+        }
+    }
+    return options, nil
+}
+
+func newPushProviders(cfg *config.Config) (map[models.Platform]push.Provider, error) {
+    providers := make(map[models.Platform]push.Provider)
+    if cfg.Push.FCMCredentialsFile != "" {
+        credentials, err := os.ReadFile(cfg.Push.FCMCredentialsFile)
+        if err != nil {
+            return nil, err
+        }
+        provider, err := push.NewFCMProvider(push.FCMConfig{
+            Endpoint:    cfg.Push.FCMEndpoint,
+            ProjectID:   cfg.Push.FCMProjectID,
+            Credentials: credentials,
+            Timeout:     cfg.Push.Timeout,
+        })
+        if err != nil {
+            return nil, err
+        }
+        providers[models.AndroidPlatform] = provider
+    }
+    if cfg.Push.APNsKeyFile != "" {
+        key, err := os.ReadFile(cfg.Push.APNsKeyFile)
+        if err != nil {
+            return nil, err
+        }
+        provider, err := push.NewAPNsProvider(push.APNsConfig{
+            Endpoint:   cfg.Push.APNsEndpoint,
+            Topic:      cfg.Push.APNsTopic,
+            TeamID:     cfg.Push.APNsTeamID,
+            KeyID:      cfg.Push.APNsKeyID,
+            PrivateKey: key,
+            Timeout:    cfg.Push.Timeout,
+        })
+        if err != nil {
+            return nil, err
+        }
+        providers[models.IOSPlatform] = provider
+    }
+    return providers, nil
+}
+
+func newChatDestinations(cfg *config.Config) (map[string]chat.Provider, error) {
+    destinations := make(map[string]chat.Provider)
+    for name, webhook := range cfg.Chat.Webhooks {
+        provider, err := chat.NewProvider(chat.ProviderType(webhook.Provider), webhook.URL, cfg.Chat.Timeout)
+        if err != nil {
+            return nil, fmt.Errorf("chat webhook %s: %w", name, err)
+        }
+        destinations[name] = provider
+    }
+    return destinations, nil
 }
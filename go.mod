@@ -7,7 +7,14 @@ require (
 	go.mongodb.org/mongo-driver v1.17.3
 )
 
-require github.com/google/uuid v1.6.0 // indirect
+require github.com/google/uuid v1.6.0
+
+require github.com/gorilla/websocket v1.5.3
+
+require (
+	cloud.google.com/go/compute/metadata v0.3.0 // indirect
+	golang.org/x/oauth2 v0.30.0
+)
 
 require (
 	github.com/golang/snappy v0.0.4 // indirect
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"notificationservice/internal/auth"
+	"notificationservice/internal/handlers"
+	"notificationservice/internal/server"
+)
+
+type API struct {
+	handler       *handlers.Handler
+	authenticator auth.Authenticator
+	adminToken    string
+}
+
+func NewAPI(handler *handlers.Handler, authenticator auth.Authenticator, adminToken string) *API {
+	return &API{handler: handler, authenticator: authenticator, adminToken: adminToken}
+}
+
+func (api *API) Register(server *server.Server) {
+	server.HandleFunc("GET /v1/users/{userId}/notifications", api.forUser(api.listNotifications))
+	server.HandleFunc("GET /v1/users/{userId}/notifications/unread", api.forUser(api.listUnreadNotifications))
+	server.HandleFunc("POST /v1/users/{userId}/notifications/read", api.forUser(api.markNotificationsRead))
+	server.HandleFunc("POST /v1/users/{userId}/notifications/read-all", api.forUser(api.markAllNotificationsRead))
+	server.HandleFunc("POST /v1/users/{userId}/notifications/{id}/read", api.forUser(api.markNotificationRead))
+	server.HandleFunc("GET /v1/notifications/{id}", api.authenticated(api.getNotification))
+	server.HandleFunc("GET /v1/notifications/{id}/channels", api.authenticated(api.listChannelNotifications))
+	server.HandleFunc("POST /v1/notifications/external/{externalId}/cancel", api.admin(api.cancelScheduledNotification))
+	server.HandleFunc("GET /v1/users/{userId}/devices", api.forUser(api.listDevices))
+	server.HandleFunc("POST /v1/users/{userId}/devices", api.forUser(api.registerDevice))
+	server.HandleFunc("DELETE /v1/users/{userId}/devices/{token}", api.forUser(api.unregisterDevice))
+	server.HandleFunc("GET /v1/users/{userId}/webhook", api.forUser(api.getUserWebhook))
+	server.HandleFunc("PUT /v1/users/{userId}/webhook", api.forUser(api.setUserWebhook))
+	server.HandleFunc("DELETE /v1/users/{userId}/webhook", api.forUser(api.deleteUserWebhook))
+	server.HandleFunc("GET /v1/users/{userId}/preferences", api.forUser(api.listPreferences))
+	server.HandleFunc("GET /v1/users/{userId}/preferences/{category}", api.forUser(api.getPreference))
+	server.HandleFunc("PUT /v1/users/{userId}/preferences/{category}", api.forUser(api.setPreference))
+	server.HandleFunc("DELETE /v1/users/{userId}/preferences/{category}", api.forUser(api.deletePreference))
+	server.HandleFunc("GET /v1/tenants/{tenantId}/webhook", api.admin(api.getTenantWebhook))
+	server.HandleFunc("PUT /v1/tenants/{tenantId}/webhook", api.admin(api.setTenantWebhook))
+	server.HandleFunc("DELETE /v1/tenants/{tenantId}/webhook", api.admin(api.deleteTenantWebhook))
+}
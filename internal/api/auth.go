@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"notificationservice/internal/errors"
+
+	"github.com/google/uuid"
+)
+
+type userContextKey struct{}
+
+// authenticated rejects requests without a valid access token and passes the
+// token's user on in the request context.
+func (api *API) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := api.authenticator.Authenticate(r)
+		if err != nil {
+			writeError(w, errors.NewUnauthorizedError("missing or invalid access token", err))
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, userID)))
+	}
+}
+
+// forUser additionally requires the {userId} of the path to be the token's
+// user.
+func (api *API) forUser(next http.HandlerFunc) http.HandlerFunc {
+	return api.authenticated(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := parseUserID(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if userID != requestUser(r) {
+			writeError(w, errors.NewForbiddenError("the access token is for another user", nil))
+			return
+		}
+		next(w, r)
+	})
+}
+
+// admin guards routes that are not about a single user with the admin token.
+// Without a configured admin token they are disabled.
+func (api *API) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if api.adminToken == "" || !found || subtle.ConstantTimeCompare([]byte(token), []byte(api.adminToken)) != 1 {
+			writeError(w, errors.NewUnauthorizedError("missing or invalid admin token", nil))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func requestUser(r *http.Request) uuid.UUID {
+	userID, _ := r.Context().Value(userContextKey{}).(uuid.UUID)
+	return userID
+}
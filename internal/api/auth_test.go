@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"notificationservice/internal/auth"
+	"notificationservice/internal/handlers"
+	"notificationservice/internal/models"
+	"notificationservice/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// stubAuthenticator knows the users of the bearer tokens it maps.
+type stubAuthenticator map[string]uuid.UUID
+
+func (authenticator stubAuthenticator) Authenticate(r *http.Request) (uuid.UUID, error) {
+	userID, ok := authenticator[r.Header.Get("Authorization")]
+	if !ok {
+		return uuid.Nil, auth.ErrUnauthenticated
+	}
+	return userID, nil
+}
+
+type apiRequest struct {
+	method, target, authorization string
+	pathValues                    map[string]string
+}
+
+func (request apiRequest) serve(handler http.HandlerFunc) int {
+	r := httptest.NewRequest(request.method, request.target, nil)
+	if request.authorization != "" {
+		r.Header.Set("Authorization", request.authorization)
+	}
+	for name, value := range request.pathValues {
+		r.SetPathValue(name, value)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w.Code
+}
+
+func newTestAPI(t *testing.T, users stubAuthenticator) (*API, *repository.MemoryRepository) {
+	t.Helper()
+	repo := repository.NewMemoryRepository()
+	handler := handlers.NewHandler(repo, nil, repository.NewMemoryWebhookRepository(), repository.NewMemoryPreferenceRepository(), handlers.NewChannelRegistry(), nil)
+	return NewAPI(handler, users, "admin-secret"), repo
+}
+
+func TestUserRoutesRequireTheUsersToken(t *testing.T) {
+	jane, john := uuid.New(), uuid.New()
+	api, _ := newTestAPI(t, stubAuthenticator{"Bearer jane": jane})
+	listNotifications := api.forUser(api.listNotifications)
+
+	tests := []struct {
+		name          string
+		authorization string
+		userID        uuid.UUID
+		want          int
+	}{
+		{"own notifications", "Bearer jane", jane, http.StatusOK},
+		{"another user's notifications", "Bearer jane", john, http.StatusForbidden},
+		{"invalid token", "Bearer john", john, http.StatusUnauthorized},
+		{"no token", "", jane, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := apiRequest{
+				method:        http.MethodGet,
+				target:        "/v1/users/" + test.userID.String() + "/notifications",
+				authorization: test.authorization,
+				pathValues:    map[string]string{"userId": test.userID.String()},
+			}
+			if got := request.serve(listNotifications); got != test.want {
+				t.Errorf("status = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNotificationsOfOtherUsersAreNotFound(t *testing.T) {
+	jane, john := uuid.New(), uuid.New()
+	api, repo := newTestAPI(t, stubAuthenticator{"Bearer jane": jane, "Bearer john": john})
+	notification := &models.Notification{UserID: jane, ExternalID: uuid.New(), Type: models.InAppNotification, Subject: "Hi"}
+	if err := repo.SaveNotification(notification); err != nil {
+		t.Fatalf("SaveNotification: %v", err)
+	}
+
+	for authorization, want := range map[string]int{"Bearer jane": http.StatusOK, "Bearer john": http.StatusNotFound} {
+		request := apiRequest{
+			method:        http.MethodGet,
+			target:        "/v1/notifications/" + notification.ID.Hex(),
+			authorization: authorization,
+			pathValues:    map[string]string{"id": notification.ID.Hex()},
+		}
+		if got := request.serve(api.authenticated(api.getNotification)); got != want {
+			t.Errorf("%s: status = %d, want %d", authorization, got, want)
+		}
+	}
+}
+
+func TestAdminRoutesRequireTheAdminToken(t *testing.T) {
+	jane := uuid.New()
+	api, _ := newTestAPI(t, stubAuthenticator{"Bearer jane": jane})
+	getTenantWebhook := api.admin(api.getTenantWebhook)
+
+	for authorization, want := range map[string]int{
+		"Bearer admin-secret": http.StatusNotFound,
+		"Bearer jane":         http.StatusUnauthorized,
+		"":                    http.StatusUnauthorized,
+	} {
+		request := apiRequest{
+			method:        http.MethodGet,
+			target:        "/v1/tenants/acme/webhook",
+			authorization: authorization,
+			pathValues:    map[string]string{"tenantId": "acme"},
+		}
+		if got := request.serve(getTenantWebhook); got != want {
+			t.Errorf("%q: status = %d, want %d", authorization, got, want)
+		}
+	}
+
+	// Without an admin token the routes are disabled
+	api.adminToken = ""
+	request := apiRequest{method: http.MethodGet, target: "/v1/tenants/acme/webhook", authorization: "Bearer ", pathValues: map[string]string{"tenantId": "acme"}}
+	if got := request.serve(getTenantWebhook); got != http.StatusUnauthorized {
+		t.Errorf("without an admin token: status = %d, want %d", got, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+)
+
+type registerDeviceRequest struct {
+	Token    string          `json:"token"`
+	Platform models.Platform `json:"platform"`
+}
+
+type devicesResponse struct {
+	Devices []models.Device `json:"devices"`
+}
+
+func (api *API) listDevices(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	devices, err := api.handler.GetDevices(userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, devicesResponse{Devices: devices})
+}
+
+func (api *API) registerDevice(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var request registerDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, errors.NewValidationError("invalid JSON body", err))
+		return
+	}
+
+	device, err := api.handler.RegisterDevice(userID, request.Token, request.Platform)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, device)
+}
+
+func (api *API) unregisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := api.handler.UnregisterDevice(userID, r.PathValue("token")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+	"notificationservice/internal/repository"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+type notificationsResponse struct {
+	Notifications []models.Notification `json:"notifications"`
+}
+
+type cancelResponse struct {
+	Cancelled int64 `json:"cancelled"`
+}
+
+func (api *API) listNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	filter, err := parseNotificationFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	filter.UserID = userID
+
+	notifications, err := api.handler.GetNotifications(filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, notificationsResponse{Notifications: notifications})
+}
+
+func (api *API) listUnreadNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	notifications, err := api.handler.GetUnreadNotifications(userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, notificationsResponse{Notifications: notifications})
+}
+
+func (api *API) getNotification(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := parseNotificationID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	notification, err := api.ownNotification(r, notificationID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, notification)
+}
+
+func (api *API) listChannelNotifications(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := parseNotificationID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if _, err := api.ownNotification(r, notificationID); err != nil {
+		writeError(w, err)
+		return
+	}
+	notifications, err := api.handler.GetChannelNotifications(notificationID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, notificationsResponse{Notifications: notifications})
+}
+
+func (api *API) cancelScheduledNotification(w http.ResponseWriter, r *http.Request) {
+	externalID, err := uuid.Parse(r.PathValue("externalId"))
+	if err != nil {
+		writeError(w, errors.NewValidationError("invalid externalId", err))
+		return
+	}
+
+	cancelled, err := api.handler.CancelScheduled(externalID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cancelResponse{Cancelled: cancelled})
+}
+
+// ownNotification returns a notification of the request's user. Other users'
+// notifications are reported as not found rather than revealed to exist.
+func (api *API) ownNotification(r *http.Request, notificationID primitive.ObjectID) (*models.Notification, error) {
+	notification, err := api.handler.GetNotification(notificationID)
+	if err != nil {
+		return nil, err
+	}
+	if notification.UserID != requestUser(r) {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("notification %s not found", notificationID.Hex()), nil)
+	}
+	return notification, nil
+}
+
+func parseUserID(r *http.Request) (uuid.UUID, error) {
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil || userID == uuid.Nil {
+		return uuid.Nil, errors.NewValidationError("invalid userId", err)
+	}
+	return userID, nil
+}
+
+func parseNotificationID(r *http.Request) (primitive.ObjectID, error) {
+	notificationID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		return primitive.NilObjectID, errors.NewValidationError("invalid notification id", err)
+	}
+	return notificationID, nil
+}
+
+func parseNotificationFilter(r *http.Request) (repository.NotificationFilter, error) {
+	query := r.URL.Query()
+	filter := repository.NotificationFilter{Limit: defaultPageSize}
+
+	if status := query.Get("status"); status != "" {
+		filter.Status = models.NotificationStatus(status)
+		if !filter.Status.IsValid() {
+			return filter, errors.NewValidationError(fmt.Sprintf("unknown status: %s", status), nil)
+		}
+	}
+	filter.Type = models.NotificationType(query.Get("type"))
+
+	from, err := parseTime(query.Get("from"), "from")
+	if err != nil {
+		return filter, err
+	}
+	to, err := parseTime(query.Get("to"), "to")
+	if err != nil {
+		return filter, err
+	}
+	if from != nil && to != nil && from.After(*to) {
+		return filter, errors.NewValidationError("from must not be after to", nil)
+	}
+	filter.CreatedFrom = from
+	filter.CreatedTo = to
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || value <= 0 || value > maxPageSize {
+			return filter, errors.NewValidationError(fmt.Sprintf("limit must be between 1 and %d", maxPageSize), err)
+		}
+		filter.Limit = value
+	}
+	if offset := query.Get("offset"); offset != "" {
+		value, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil || value < 0 {
+			return filter, errors.NewValidationError("offset must be a non-negative integer", err)
+		}
+		filter.Offset = value
+	}
+
+	return filter, nil
+}
+
+func parseTime(value, name string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("%s must be an RFC 3339 timestamp", name), err)
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+)
+
+type setPreferenceRequest struct {
+	OptOut     bool                             `json:"optOut"`
+	Channels   map[models.NotificationType]bool `json:"channels"`
+	QuietHours *models.QuietHours               `json:"quietHours"`
+	Digest     models.DigestFrequency           `json:"digest"`
+}
+
+type preferencesResponse struct {
+	Preferences []models.Preference `json:"preferences"`
+}
+
+func (api *API) listPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	preferences, err := api.handler.GetPreferences(userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, preferencesResponse{Preferences: preferences})
+}
+
+func (api *API) getPreference(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	preference, err := api.handler.GetPreference(userID, r.PathValue("category"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, preference)
+}
+
+func (api *API) setPreference(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var request setPreferenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, errors.NewValidationError("invalid JSON body", err))
+		return
+	}
+
+	preference, err := api.handler.SetPreference(&models.Preference{
+		UserID:     userID,
+		Category:   r.PathValue("category"),
+		OptOut:     request.OptOut,
+		Channels:   request.Channels,
+		QuietHours: request.QuietHours,
+		Digest:     request.Digest,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, preference)
+}
+
+func (api *API) deletePreference(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := api.handler.DeletePreference(userID, r.PathValue("category")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
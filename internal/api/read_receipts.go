@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type markReadRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type unreadCountResponse struct {
+	UnreadCount int64 `json:"unreadCount"`
+}
+
+func (api *API) markNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	notificationID, err := parseNotificationID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	unreadCount, err := api.handler.MarkNotificationsRead(userID, []primitive.ObjectID{notificationID})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, unreadCountResponse{UnreadCount: unreadCount})
+}
+
+func (api *API) markNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var request markReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, errors.NewValidationError("invalid JSON body", err))
+		return
+	}
+	notificationIDs, err := models.ParseNotificationIDs(request.IDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	unreadCount, err := api.handler.MarkNotificationsRead(userID, notificationIDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, unreadCountResponse{UnreadCount: unreadCount})
+}
+
+func (api *API) markAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	unreadCount, err := api.handler.MarkAllNotificationsRead(userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, unreadCountResponse{UnreadCount: unreadCount})
+}
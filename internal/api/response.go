@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"notificationservice/internal/errors"
+)
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Type    errors.ErrorType `json:"type"`
+	Message string           `json:"message"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := statusCode(err)
+	errorType := errors.GetErrorType(err)
+	if errorType == "" {
+		errorType = errors.ProcessingError
+	}
+
+	message := errors.GetErrorDescription(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("Request failed: %v", err)
+	}
+
+	writeJSON(w, status, errorResponse{
+		Error: errorBody{Type: errorType, Message: message},
+	})
+}
+
+func statusCode(err error) int {
+	switch errors.GetErrorType(err) {
+	case errors.ValidationError:
+		return http.StatusBadRequest
+	case errors.NotFoundError:
+		return http.StatusNotFound
+	case errors.UnauthorizedError:
+		return http.StatusUnauthorized
+	case errors.ForbiddenError:
+		return http.StatusForbidden
+	case errors.RetriableError, errors.UnavailableError:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
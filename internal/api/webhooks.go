@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"notificationservice/internal/errors"
+
+	"github.com/google/uuid"
+)
+
+type setWebhookRequest struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+func (api *API) getUserWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	api.getWebhook(w, userID, "")
+}
+
+func (api *API) setUserWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	api.setWebhook(w, r, userID, "")
+}
+
+func (api *API) deleteUserWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	api.deleteWebhook(w, userID, "")
+}
+
+func (api *API) getTenantWebhook(w http.ResponseWriter, r *http.Request) {
+	api.getWebhook(w, uuid.Nil, r.PathValue("tenantId"))
+}
+
+func (api *API) setTenantWebhook(w http.ResponseWriter, r *http.Request) {
+	api.setWebhook(w, r, uuid.Nil, r.PathValue("tenantId"))
+}
+
+func (api *API) deleteTenantWebhook(w http.ResponseWriter, r *http.Request) {
+	api.deleteWebhook(w, uuid.Nil, r.PathValue("tenantId"))
+}
+
+func (api *API) getWebhook(w http.ResponseWriter, userID uuid.UUID, tenantID string) {
+	webhook, err := api.handler.GetWebhook(userID, tenantID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, webhook)
+}
+
+func (api *API) setWebhook(w http.ResponseWriter, r *http.Request, userID uuid.UUID, tenantID string) {
+	var request setWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, errors.NewValidationError("invalid JSON body", err))
+		return
+	}
+
+	webhook, err := api.handler.SetWebhook(userID, tenantID, request.URL, request.Secret)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, webhook)
+}
+
+func (api *API) deleteWebhook(w http.ResponseWriter, userID uuid.UUID, tenantID string) {
+	if err := api.handler.DeleteWebhook(userID, tenantID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+// Package auth identifies the user behind a request from the access token
+// it carries.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrUnauthenticated = stderrors.New("missing or invalid access token")
+
+// Authenticator identifies the user a request is made for.
+type Authenticator interface {
+	Authenticate(r *http.Request) (uuid.UUID, error)
+}
+
+// TokenAuthenticator accepts HS256 JWTs signed with a secret shared with the
+// service that issues them. The subject is the user ID and the token must
+// carry an expiry.
+type TokenAuthenticator struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewTokenAuthenticator(secret []byte) *TokenAuthenticator {
+	return &TokenAuthenticator{secret: secret, now: time.Now}
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+}
+
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Authenticate reads the token from the Authorization header, or from the
+// access_token query parameter for browsers, which cannot set headers on a
+// websocket request.
+func (authenticator *TokenAuthenticator) Authenticate(r *http.Request) (uuid.UUID, error) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return uuid.Nil, ErrUnauthenticated
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return uuid.Nil, ErrUnauthenticated
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return uuid.Nil, ErrUnauthenticated
+	}
+	mac := hmac.New(sha256.New, authenticator.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return uuid.Nil, ErrUnauthenticated
+	}
+
+	var header tokenHeader
+	if err := decodeTokenPart(parts[0], &header); err != nil || header.Algorithm != "HS256" {
+		return uuid.Nil, ErrUnauthenticated
+	}
+	var claims tokenClaims
+	if err := decodeTokenPart(parts[1], &claims); err != nil {
+		return uuid.Nil, ErrUnauthenticated
+	}
+	if claims.ExpiresAt == 0 || !authenticator.now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return uuid.Nil, ErrUnauthenticated
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil || userID == uuid.Nil {
+		return uuid.Nil, ErrUnauthenticated
+	}
+	return userID, nil
+}
+
+func decodeTokenPart(part string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testSecret = []byte("test-secret")
+
+func signToken(secret []byte, header, claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	unsigned := encode([]byte(header)) + "." + encode([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + encode(mac.Sum(nil))
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	userID := uuid.New()
+	now := time.Unix(1_700_000_000, 0)
+	valid := `{"sub": "` + userID.String() + `", "exp": 1700000060}`
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", signToken(testSecret, `{"alg": "HS256"}`, valid), true},
+		{"other secret", signToken([]byte("other"), `{"alg": "HS256"}`, valid), false},
+		{"unsigned", signToken(testSecret, `{"alg": "none"}`, valid), false},
+		{"expired", signToken(testSecret, `{"alg": "HS256"}`, `{"sub": "`+userID.String()+`", "exp": 1700000000}`), false},
+		{"no expiry", signToken(testSecret, `{"alg": "HS256"}`, `{"sub": "`+userID.String()+`"}`), false},
+		{"no user", signToken(testSecret, `{"alg": "HS256"}`, `{"sub": "admin", "exp": 1700000060}`), false},
+		{"malformed", "not-a-token", false},
+		{"missing", "", false},
+	}
+	authenticator := NewTokenAuthenticator(testSecret)
+	authenticator.now = func() time.Time { return now }
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/ws?userId="+uuid.NewString(), nil)
+			if test.token != "" {
+				request.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			got, err := authenticator.Authenticate(request)
+			if test.ok && (err != nil || got != userID) {
+				t.Errorf("Authenticate = %s, %v, want %s", got, err, userID)
+			}
+			if !test.ok && err == nil {
+				t.Errorf("Authenticate accepted the token as %s", got)
+			}
+		})
+	}
+}
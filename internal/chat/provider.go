@@ -0,0 +1,115 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"notificationservice/internal/errors"
+)
+
+const (
+	defaultTimeout  = 10 * time.Second
+	maxErrorBodyLen = 512
+)
+
+type ProviderType string
+
+const (
+	Slack ProviderType = "slack"
+	Teams ProviderType = "teams"
+)
+
+// Provider posts to one incoming webhook. Slack and Teams only differ in the
+// payload they expect.
+type Provider interface {
+	Send(message *Message) error
+}
+
+type Message struct {
+	Title string
+	Text  string
+}
+
+func NewProvider(providerType ProviderType, webhookURL string, timeout time.Duration) (Provider, error) {
+	switch providerType {
+	case Slack:
+		return NewSlackProvider(webhookURL, timeout), nil
+	case Teams:
+		return NewTeamsProvider(webhookURL, timeout), nil
+	}
+	return nil, fmt.Errorf("unknown chat provider: %s", providerType)
+}
+
+type webhookClient struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+func newWebhookClient(name, webhookURL string, timeout time.Duration) webhookClient {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return webhookClient{name: name, url: webhookURL, client: &http.Client{Timeout: timeout}}
+}
+
+func (client webhookClient) post(payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.NewProcessingError(fmt.Sprintf("failed to serialize %s message", client.name), err)
+	}
+
+	response, err := client.client.Post(client.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.NewRetriableError(fmt.Sprintf("%s webhook request failed", client.name), err)
+	}
+	defer response.Body.Close()
+
+	detail, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodyLen))
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+
+	description := fmt.Sprintf("%s webhook returned %s", client.name, response.Status)
+	if text := strings.TrimSpace(string(detail)); text != "" {
+		description += ": " + text
+	}
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests:
+		return errors.NewRetriableErrorAfter(description, nil, parseRetryAfter(response.Header.Get("Retry-After")))
+	case response.StatusCode >= 500:
+		return errors.NewRetriableError(description, nil)
+	}
+	return errors.NewValidationError(description, nil)
+}
+
+// parseRetryAfter accepts both forms of the header, delay seconds and an
+// HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
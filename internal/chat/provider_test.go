@@ -0,0 +1,89 @@
+package chat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"notificationservice/internal/errors"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"missing", "", 0, 0},
+		{"delay seconds", "30", 30 * time.Second, 30 * time.Second},
+		{"zero seconds", "0", 0, 0},
+		{"negative seconds", "-5", 0, 0},
+		// HTTP dates have second precision, so allow for the truncation
+		{"HTTP date", time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat), 119 * time.Second, 2 * time.Minute},
+		{"HTTP date in the past", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+		{"malformed", "soon", 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseRetryAfter(test.value)
+			if got < test.min || got > test.max {
+				t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", test.value, got, test.min, test.max)
+			}
+		})
+	}
+}
+
+func TestWebhookErrorMapping(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		retryAfter     string
+		wantType       errors.ErrorType
+		wantRetryAfter time.Duration
+	}{
+		{"accepted", http.StatusOK, "", "", 0},
+		{"rate limited", http.StatusTooManyRequests, "12", errors.RetriableError, 12 * time.Second},
+		{"rate limited without delay", http.StatusTooManyRequests, "", errors.RetriableError, 0},
+		{"server error", http.StatusBadGateway, "", errors.RetriableError, 0},
+		{"revoked webhook", http.StatusNotFound, "", errors.ValidationError, 0},
+		{"invalid payload", http.StatusBadRequest, "", errors.ValidationError, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte("no_service"))
+			}))
+			defer server.Close()
+
+			for _, providerType := range []ProviderType{Slack, Teams} {
+				provider, err := NewProvider(providerType, server.URL, 0)
+				if err != nil {
+					t.Fatalf("NewProvider: %v", err)
+				}
+				err = provider.Send(&Message{Title: "Title", Text: "Text"})
+				if got := errors.GetErrorType(err); got != test.wantType {
+					t.Errorf("%s: error type = %q, want %q (%v)", providerType, got, test.wantType, err)
+				}
+				if got := errors.GetRetryAfter(err); got != test.wantRetryAfter {
+					t.Errorf("%s: retry after = %s, want %s", providerType, got, test.wantRetryAfter)
+				}
+				if err != nil && !strings.Contains(err.Error(), "no_service") {
+					t.Errorf("%s: error %q does not carry the response body", providerType, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewProviderRejectsUnknownType(t *testing.T) {
+	if _, err := NewProvider("discord", "https://example.com", 0); err == nil {
+		t.Error("NewProvider accepted an unknown provider type")
+	}
+}
@@ -0,0 +1,58 @@
+package chat
+
+import "time"
+
+// Block Kit limits for header and section text.
+const (
+	slackHeaderLimit  = 150
+	slackSectionLimit = 3000
+)
+
+type SlackProvider struct {
+	client webhookClient
+}
+
+type slackPayload struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func NewSlackProvider(webhookURL string, timeout time.Duration) *SlackProvider {
+	return &SlackProvider{client: newWebhookClient("Slack", webhookURL, timeout)}
+}
+
+func (provider *SlackProvider) Send(message *Message) error {
+	return provider.client.post(slackMessage(message))
+}
+
+// slackMessage renders a header block for the title and a mrkdwn section for
+// the text; Text is the fallback shown in notifications.
+func slackMessage(message *Message) slackPayload {
+	payload := slackPayload{Text: message.Title}
+	if payload.Text == "" {
+		payload.Text = truncate(message.Text, slackSectionLimit)
+	}
+	if message.Title != "" {
+		payload.Blocks = append(payload.Blocks, slackBlock{
+			Type: "header",
+			Text: &slackText{Type: "plain_text", Text: truncate(message.Title, slackHeaderLimit)},
+		})
+	}
+	if message.Text != "" {
+		payload.Blocks = append(payload.Blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: truncate(message.Text, slackSectionLimit)},
+		})
+	}
+	return payload
+}
@@ -0,0 +1,82 @@
+package chat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// captureWebhook records the JSON posted to it.
+func captureWebhook(t *testing.T) (*httptest.Server, *map[string]interface{}) {
+	t.Helper()
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s with %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func decodeJSON(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	return decoded
+}
+
+func TestSlackBlockKitPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		message Message
+		want    string
+	}{
+		{
+			"title and text",
+			Message{Title: "Deploy finished", Text: "*api* is live"},
+			`{"text": "Deploy finished", "blocks": [
+				{"type": "header", "text": {"type": "plain_text", "text": "Deploy finished"}},
+				{"type": "section", "text": {"type": "mrkdwn", "text": "*api* is live"}}
+			]}`,
+		},
+		{
+			"text only falls back to the text",
+			Message{Text: "*api* is live"},
+			`{"text": "*api* is live", "blocks": [
+				{"type": "section", "text": {"type": "mrkdwn", "text": "*api* is live"}}
+			]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, received := captureWebhook(t)
+			if err := NewSlackProvider(server.URL, 0).Send(&test.message); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if want := decodeJSON(t, test.want); !reflect.DeepEqual(*received, want) {
+				t.Errorf("payload = %v, want %v", *received, want)
+			}
+		})
+	}
+}
+
+func TestSlackTruncatesToBlockKitLimits(t *testing.T) {
+	payload := slackMessage(&Message{Title: strings.Repeat("t", 200), Text: strings.Repeat("ü", 3500)})
+
+	header := []rune(payload.Blocks[0].Text.Text)
+	if len(header) != slackHeaderLimit || header[len(header)-1] != '…' {
+		t.Errorf("header has %d characters, want %d ending in an ellipsis", len(header), slackHeaderLimit)
+	}
+	if section := []rune(payload.Blocks[1].Text.Text); len(section) != slackSectionLimit {
+		t.Errorf("section has %d characters, want %d", len(section), slackSectionLimit)
+	}
+}
@@ -0,0 +1,63 @@
+package chat
+
+import "time"
+
+const adaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+
+type TeamsProvider struct {
+	client webhookClient
+}
+
+type teamsPayload struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string          `json:"$schema"`
+	Type    string          `json:"type"`
+	Version string          `json:"version"`
+	Body    []cardTextBlock `json:"body"`
+}
+
+type cardTextBlock struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Wrap   bool   `json:"wrap"`
+	Size   string `json:"size,omitempty"`
+	Weight string `json:"weight,omitempty"`
+}
+
+func NewTeamsProvider(webhookURL string, timeout time.Duration) *TeamsProvider {
+	return &TeamsProvider{client: newWebhookClient("Teams", webhookURL, timeout)}
+}
+
+func (provider *TeamsProvider) Send(message *Message) error {
+	return provider.client.post(teamsMessage(message))
+}
+
+func teamsMessage(message *Message) teamsPayload {
+	card := adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+	}
+	if message.Title != "" {
+		card.Body = append(card.Body, cardTextBlock{
+			Type: "TextBlock", Text: message.Title, Wrap: true, Size: "Medium", Weight: "Bolder",
+		})
+	}
+	if message.Text != "" {
+		card.Body = append(card.Body, cardTextBlock{Type: "TextBlock", Text: message.Text, Wrap: true})
+	}
+
+	return teamsPayload{
+		Type:        "message",
+		Attachments: []teamsAttachment{{ContentType: adaptiveCardContentType, Content: card}},
+	}
+}
@@ -0,0 +1,39 @@
+package chat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTeamsAdaptiveCardPayload(t *testing.T) {
+	server, received := captureWebhook(t)
+	if err := NewTeamsProvider(server.URL, 0).Send(&Message{Title: "Deploy finished", Text: "api is live"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	want := decodeJSON(t, `{
+		"type": "message",
+		"attachments": [{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": {
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type": "AdaptiveCard",
+				"version": "1.4",
+				"body": [
+					{"type": "TextBlock", "text": "Deploy finished", "wrap": true, "size": "Medium", "weight": "Bolder"},
+					{"type": "TextBlock", "text": "api is live", "wrap": true}
+				]
+			}
+		}]
+	}`)
+	if !reflect.DeepEqual(*received, want) {
+		t.Errorf("payload = %v, want %v", *received, want)
+	}
+}
+
+func TestTeamsCardWithoutTitle(t *testing.T) {
+	card := teamsMessage(&Message{Text: "api is live"}).Attachments[0].Content
+	if len(card.Body) != 1 || card.Body[0].Text != "api is live" || card.Body[0].Weight != "" {
+		t.Errorf("body = %+v, want a single plain text block", card.Body)
+	}
+}
@@ -0,0 +1,21 @@
+// Package clock lets time-dependent code take the current time from a
+// dependency, so it can be driven by a fixed or simulated time.
+package clock
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+}
+
+// Func adapts a function to a Clock, e.g. clock.Func(func() time.Time { return fixed }).
+type Func func() time.Time
+
+func (now Func) Now() time.Time {
+	return now()
+}
+
+// System returns the wall clock.
+func System() Clock {
+	return Func(time.Now)
+}
@@ -1,7 +1,12 @@
 package config
 
 import (
+    "fmt"
     "os"
+    "strconv"
+    "strings"
+    "time"
+
     "github.com/joho/godotenv"
 )
 
@@ -9,21 +14,96 @@ type Config struct {
     MongoDB struct {
         URI      string
         Database string
+        ExpiredRetention time.Duration
+        AttachmentRetention time.Duration
     }
     RabbitMQ struct {
         URI      string
         Queue    string
         Exchange string
         RoutingKey string
+        PrefetchCount int
+        WorkerCount   int
+        RetryDelays   []time.Duration
+        MaxRetries    int
         DeadLetterQueue struct {
             Queue      string
             Exchange   string
             RoutingKey string
         }
     }
+    SMTP struct {
+        Host          string
+        Port          string
+        Username      string
+        Password      string
+        From          string
+        Encryption    string
+        AuthMechanism string
+        MaxAttachments    int
+        MaxAttachmentSize int
+        MaxMessageSize    int
+        AllowedSenderDomains []string
+        AttachmentHosts      []string
+    }
+    SMS struct {
+        ProviderURL string
+        APIKey      string
+        From        string
+        Timeout     time.Duration
+        MaxSegments int
+    }
+    Push struct {
+        FCMEndpoint        string
+        FCMProjectID       string
+        FCMCredentialsFile string
+        APNsEndpoint       string
+        APNsTopic          string
+        APNsTeamID         string
+        APNsKeyID          string
+        APNsKeyFile        string
+        Timeout            time.Duration
+    }
+    Webhook struct {
+        Timeout time.Duration
+    }
+    Chat struct {
+        Webhooks map[string]ChatWebhook
+        Timeout  time.Duration
+    }
+    Preferences struct {
+        LockedCategories []string
+        QuietHoursFile   string
+    }
+    Scheduler struct {
+        Interval time.Duration
+    }
+    Digest struct {
+        Categories map[string]string
+        TemplateID string
+        DailyAt    string
+    }
+    Fallback struct {
+        PolicyFile    string
+        CheckInterval time.Duration
+    }
+    Templates struct {
+        Source    string
+        Directory string
+    }
     Server struct {
-        Port string
+        Port            string
+        ShutdownTimeout time.Duration
+        AuthTokenSecret string
+        AdminToken      string
     }
+    // Environment is "development" to relax checks meant for production.
+    Environment string
+}
+
+type ChatWebhook struct {
+    Provider string
+    URL      string
 }
 
 func LoadConfig() (*Config, error) {
@@ -32,19 +112,252 @@ func LoadConfig() (*Config, error) {
     }
 
     config := &Config{}
+    config.Environment = os.Getenv("APP_ENV")
 
     config.MongoDB.URI = os.Getenv("MONGODB_URI")
     config.MongoDB.Database = os.Getenv("MONGODB_DATABASE")
+    expiredRetention, err := getEnvDuration("EXPIRED_RETENTION", 0)
+    if err != nil {
+        return nil, err
+    }
+    config.MongoDB.ExpiredRetention = expiredRetention
+    attachmentRetention, err := getEnvDuration("ATTACHMENT_RETENTION", 30*24*time.Hour)
+    if err != nil {
+        return nil, err
+    }
+    config.MongoDB.AttachmentRetention = attachmentRetention
 
     config.RabbitMQ.URI = os.Getenv("RABBITMQ_URI")
     config.RabbitMQ.Queue = os.Getenv("RABBITMQ_QUEUE")
     config.RabbitMQ.Exchange = os.Getenv("RABBITMQ_EXCHANGE")
     config.RabbitMQ.RoutingKey = os.Getenv("RABBITMQ_ROUTING_KEY")
+    prefetchCount, err := getEnvInt("RABBITMQ_PREFETCH_COUNT")
+    if err != nil {
+        return nil, err
+    }
+    config.RabbitMQ.PrefetchCount = prefetchCount
+    workerCount, err := getEnvInt("RABBITMQ_WORKER_COUNT")
+    if err != nil {
+        return nil, err
+    }
+    config.RabbitMQ.WorkerCount = workerCount
+    retryDelays, err := getEnvDurations("RABBITMQ_RETRY_DELAYS")
+    if err != nil {
+        return nil, err
+    }
+    config.RabbitMQ.RetryDelays = retryDelays
+    maxRetries, err := getEnvInt("RABBITMQ_MAX_RETRIES")
+    if err != nil {
+        return nil, err
+    }
+    config.RabbitMQ.MaxRetries = maxRetries
     config.RabbitMQ.DeadLetterQueue.Queue = os.Getenv("RABBITMQ_DLQ_QUEUE")
     config.RabbitMQ.DeadLetterQueue.Exchange = os.Getenv("RABBITMQ_DLQ_EXCHANGE")
     config.RabbitMQ.DeadLetterQueue.RoutingKey = os.Getenv("RABBITMQ_DLQ_ROUTING_KEY")
 
+    config.SMTP.Host = os.Getenv("SMTP_HOST")
+    config.SMTP.Port = os.Getenv("SMTP_PORT")
+    config.SMTP.Username = os.Getenv("SMTP_USERNAME")
+    config.SMTP.Password = os.Getenv("SMTP_PASSWORD")
+    config.SMTP.From = os.Getenv("SMTP_FROM")
+    config.SMTP.Encryption = os.Getenv("SMTP_ENCRYPTION")
+    config.SMTP.AuthMechanism = os.Getenv("SMTP_AUTH_MECHANISM")
+    maxAttachments, err := getEnvInt("SMTP_MAX_ATTACHMENTS")
+    if err != nil {
+        return nil, err
+    }
+    config.SMTP.MaxAttachments = maxAttachments
+    maxAttachmentSize, err := getEnvInt("SMTP_MAX_ATTACHMENT_SIZE")
+    if err != nil {
+        return nil, err
+    }
+    config.SMTP.MaxAttachmentSize = maxAttachmentSize
+    maxMessageSize, err := getEnvInt("SMTP_MAX_MESSAGE_SIZE")
+    if err != nil {
+        return nil, err
+    }
+    config.SMTP.MaxMessageSize = maxMessageSize
+    config.SMTP.AllowedSenderDomains = getEnvList("SMTP_ALLOWED_SENDER_DOMAINS")
+    config.SMTP.AttachmentHosts = getEnvList("SMTP_ATTACHMENT_HOSTS")
+
+    config.SMS.ProviderURL = os.Getenv("SMS_PROVIDER_URL")
+    config.SMS.APIKey = os.Getenv("SMS_API_KEY")
+    config.SMS.From = os.Getenv("SMS_FROM")
+    smsTimeout, err := getEnvDuration("SMS_TIMEOUT", 10*time.Second)
+    if err != nil {
+        return nil, err
+    }
+    config.SMS.Timeout = smsTimeout
+    maxSegments, err := getEnvInt("SMS_MAX_SEGMENTS")
+    if err != nil {
+        return nil, err
+    }
+    config.SMS.MaxSegments = maxSegments
+
+    config.Push.FCMEndpoint = os.Getenv("FCM_ENDPOINT")
+    config.Push.FCMProjectID = os.Getenv("FCM_PROJECT_ID")
+    config.Push.FCMCredentialsFile = os.Getenv("FCM_CREDENTIALS_FILE")
+    config.Push.APNsEndpoint = os.Getenv("APNS_ENDPOINT")
+    config.Push.APNsTopic = os.Getenv("APNS_TOPIC")
+    config.Push.APNsTeamID = os.Getenv("APNS_TEAM_ID")
+    config.Push.APNsKeyID = os.Getenv("APNS_KEY_ID")
+    config.Push.APNsKeyFile = os.Getenv("APNS_KEY_FILE")
+    pushTimeout, err := getEnvDuration("PUSH_TIMEOUT", 10*time.Second)
+    if err != nil {
+        return nil, err
+    }
+    config.Push.Timeout = pushTimeout
+
+    webhookTimeout, err := getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+    if err != nil {
+        return nil, err
+    }
+    config.Webhook.Timeout = webhookTimeout
+
+    chatWebhooks, err := getEnvChatWebhooks("CHAT_WEBHOOKS")
+    if err != nil {
+        return nil, err
+    }
+    config.Chat.Webhooks = chatWebhooks
+    chatTimeout, err := getEnvDuration("CHAT_TIMEOUT", 10*time.Second)
+    if err != nil {
+        return nil, err
+    }
+    config.Chat.Timeout = chatTimeout
+
+    config.Preferences.LockedCategories = getEnvList("PREFERENCES_LOCKED_CATEGORIES")
+    config.Preferences.QuietHoursFile = os.Getenv("QUIET_HOURS_FILE")
+
+    schedulerInterval, err := getEnvPositiveDuration("SCHEDULER_INTERVAL", time.Minute)
+    if err != nil {
+        return nil, err
+    }
+    config.Scheduler.Interval = schedulerInterval
+
+    digestCategories, err := getEnvMap("DIGEST_CATEGORIES")
+    if err != nil {
+        return nil, err
+    }
+    config.Digest.Categories = digestCategories
+    config.Digest.TemplateID = os.Getenv("DIGEST_TEMPLATE")
+    config.Digest.DailyAt = os.Getenv("DIGEST_DAILY_AT")
+
+    config.Fallback.PolicyFile = os.Getenv("FALLBACK_POLICY_FILE")
+    fallbackCheckInterval, err := getEnvPositiveDuration("FALLBACK_CHECK_INTERVAL", 30*time.Second)
+    if err != nil {
+        return nil, err
+    }
+    config.Fallback.CheckInterval = fallbackCheckInterval
+
+    config.Templates.Source = os.Getenv("TEMPLATES_SOURCE")
+    config.Templates.Directory = os.Getenv("TEMPLATES_DIR")
+
     config.Server.Port = os.Getenv("SERVER_PORT")
+    shutdownTimeout, err := getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second)
+    if err != nil {
+        return nil, err
+    }
+    config.Server.ShutdownTimeout = shutdownTimeout
+    config.Server.AuthTokenSecret = os.Getenv("AUTH_TOKEN_SECRET")
+    config.Server.AdminToken = os.Getenv("ADMIN_API_TOKEN")
 
     return config, nil
-}
\ No newline at end of file
+}
+
+func getEnvInt(key string) (int, error) {
+    value := os.Getenv(key)
+    if value == "" {
+        return 0, nil
+    }
+    parsed, err := strconv.Atoi(value)
+    if err != nil {
+        return 0, fmt.Errorf("invalid %s: %w", key, err)
+    }
+    return parsed, nil
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue, nil
+    }
+    parsed, err := time.ParseDuration(value)
+    if err != nil {
+        return 0, fmt.Errorf("invalid %s: %w", key, err)
+    }
+    return parsed, nil
+}
+
+// getEnvPositiveDuration is for intervals, which a ticker cannot run at
+// when zero or negative.
+func getEnvPositiveDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+    parsed, err := getEnvDuration(key, defaultValue)
+    if err != nil {
+        return 0, err
+    }
+    if parsed <= 0 {
+        return 0, fmt.Errorf("invalid %s: must be positive, got %s", key, parsed)
+    }
+    return parsed, nil
+}
+
+func getEnvDurations(key string) ([]time.Duration, error) {
+    value := os.Getenv(key)
+    if value == "" {
+        return nil, nil
+    }
+    var durations []time.Duration
+    for _, part := range strings.Split(value, ",") {
+        parsed, err := time.ParseDuration(strings.TrimSpace(part))
+        if err != nil {
+            return nil, fmt.Errorf("invalid %s: %w", key, err)
+        }
+        durations = append(durations, parsed)
+    }
+    return durations, nil
+}
+
+func getEnvList(key string) []string {
+    value := os.Getenv(key)
+    if value == "" {
+        return nil
+    }
+    var values []string
+    for _, part := range strings.Split(value, ",") {
+        if part = strings.TrimSpace(part); part != "" {
+            values = append(values, part)
+        }
+    }
+    return values
+}
+
+// getEnvMap parses "key=value" entries, e.g. "activity=hourly,social=daily".
+func getEnvMap(key string) (map[string]string, error) {
+    values := make(map[string]string)
+    for _, entry := range getEnvList(key) {
+        name, value, found := strings.Cut(entry, "=")
+        if !found {
+            return nil, fmt.Errorf("invalid %s entry %q: expected key=value", key, entry)
+        }
+        values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+    }
+    return values, nil
+}
+
+// getEnvChatWebhooks parses "name=provider:url" entries, e.g.
+// "ops=slack:https://hooks.slack.com/services/...".
+func getEnvChatWebhooks(key string) (map[string]ChatWebhook, error) {
+    webhooks := make(map[string]ChatWebhook)
+    for _, entry := range getEnvList(key) {
+        name, target, found := strings.Cut(entry, "=")
+        if !found {
+            return nil, fmt.Errorf("invalid %s entry %q: expected name=provider:url", key, entry)
+        }
+        provider, url, found := strings.Cut(target, ":")
+        if !found {
+            return nil, fmt.Errorf("invalid %s entry %q: expected name=provider:url", key, entry)
+        }
+        webhooks[strings.TrimSpace(name)] = ChatWebhook{Provider: provider, URL: url}
+    }
+    return webhooks, nil
+}
@@ -0,0 +1,157 @@
+package email
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/outbound"
+)
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+	URL         string
+	Inline      bool
+	ContentID   string
+}
+
+func (attachment *Attachment) resolvedContentType() string {
+	if attachment.ContentType != "" {
+		return attachment.ContentType
+	}
+	if byExtension := mime.TypeByExtension(filepath.Ext(attachment.Filename)); byExtension != "" {
+		return byExtension
+	}
+	return http.DetectContentType(attachment.Content)
+}
+
+// MaxMessageSizeCeiling bounds MaxMessageSize. Attachment content is stored
+// in MongoDB until the email is sent, and a document cannot exceed 16MB.
+const MaxMessageSizeCeiling = 15 << 20
+
+type Limits struct {
+	MaxAttachments    int
+	MaxAttachmentSize int64
+	MaxMessageSize    int64
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		MaxAttachments:    10,
+		MaxAttachmentSize: 10 << 20,
+		MaxMessageSize:    MaxMessageSizeCeiling,
+	}
+}
+
+// Validate checks everything that is known before sending. Attachments given
+// by URL are only measured once fetched, see Fetcher.
+func (limits Limits) Validate(message *Message) error {
+	if err := validateCustomHeaders(message.Headers); err != nil {
+		return errors.NewValidationError("invalid custom header", err)
+	}
+	if limits.MaxAttachments > 0 && len(message.Attachments) > limits.MaxAttachments {
+		return errors.NewValidationError(fmt.Sprintf("at most %d attachments are allowed", limits.MaxAttachments), nil)
+	}
+
+	total := int64(len(message.Body) + len(message.HTMLBody))
+	for _, attachment := range message.Attachments {
+		if attachment.Filename == "" {
+			return errors.NewValidationError("attachment filename is required", nil)
+		}
+		if strings.ContainsAny(attachment.Filename, "\r\n") || strings.ContainsAny(attachment.ContentID, "\r\n<>") {
+			return errors.NewValidationError(fmt.Sprintf("attachment %s has an invalid filename or content ID", attachment.Filename), nil)
+		}
+		if (len(attachment.Content) == 0) == (attachment.URL == "") {
+			return errors.NewValidationError(fmt.Sprintf("attachment %s needs either content or a URL", attachment.Filename), nil)
+		}
+		if attachment.Inline && attachment.ContentID == "" {
+			return errors.NewValidationError(fmt.Sprintf("inline attachment %s needs a content ID", attachment.Filename), nil)
+		}
+		if attachment.ContentType != "" {
+			if _, _, err := mime.ParseMediaType(attachment.ContentType); err != nil {
+				return errors.NewValidationError(fmt.Sprintf("attachment %s has an invalid content type", attachment.Filename), err)
+			}
+		}
+
+		size := int64(len(attachment.Content))
+		if limits.MaxAttachmentSize > 0 && size > limits.MaxAttachmentSize {
+			return errors.NewValidationError(
+				fmt.Sprintf("attachment %s exceeds %d bytes", attachment.Filename, limits.MaxAttachmentSize), nil)
+		}
+		total += size
+	}
+
+	return limits.checkMessageSize(total)
+}
+
+func (limits Limits) checkMessageSize(size int64) error {
+	if limits.MaxMessageSize > 0 && size > limits.MaxMessageSize {
+		return errors.NewValidationError(fmt.Sprintf("message exceeds %d bytes", limits.MaxMessageSize), nil)
+	}
+	return nil
+}
+
+type Fetcher interface {
+	Fetch(url string) (content []byte, contentType string, err error)
+}
+
+// HTTPFetcher fetches attachment URLs that the policy allows; by default
+// only https URLs of public hosts.
+type HTTPFetcher struct {
+	client  *http.Client
+	policy  outbound.Policy
+	maxSize int64
+}
+
+func NewHTTPFetcher(maxSize int64, policy outbound.Policy) *HTTPFetcher {
+	return &HTTPFetcher{
+		client:  policy.Client(30 * time.Second),
+		policy:  policy,
+		maxSize: maxSize,
+	}
+}
+
+func (fetcher *HTTPFetcher) Fetch(url string) ([]byte, string, error) {
+	if _, err := fetcher.policy.CheckURL(url); err != nil {
+		return nil, "", errors.NewValidationError(fmt.Sprintf("attachment URL %s is not allowed", url), err)
+	}
+	response, err := fetcher.client.Get(url)
+	if err != nil {
+		if outbound.IsForbidden(err) {
+			return nil, "", errors.NewValidationError(fmt.Sprintf("attachment URL %s is not allowed", url), err)
+		}
+		return nil, "", errors.NewRetriableError(fmt.Sprintf("failed to fetch attachment %s", url), err)
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests:
+		return nil, "", errors.NewRetriableError(fmt.Sprintf("attachment %s returned %s", url, response.Status), nil)
+	case response.StatusCode >= 400:
+		return nil, "", errors.NewValidationError(fmt.Sprintf("attachment %s returned %s", url, response.Status), nil)
+	}
+
+	reader := io.Reader(response.Body)
+	if fetcher.maxSize > 0 {
+		if response.ContentLength > fetcher.maxSize {
+			return nil, "", errors.NewValidationError(fmt.Sprintf("attachment %s exceeds %d bytes", url, fetcher.maxSize), nil)
+		}
+		reader = io.LimitReader(response.Body, fetcher.maxSize+1)
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", errors.NewRetriableError(fmt.Sprintf("failed to read attachment %s", url), err)
+	}
+	if fetcher.maxSize > 0 && int64(len(content)) > fetcher.maxSize {
+		return nil, "", errors.NewValidationError(fmt.Sprintf("attachment %s exceeds %d bytes", url, fetcher.maxSize), nil)
+	}
+	return content, response.Header.Get("Content-Type"), nil
+}
@@ -0,0 +1,51 @@
+package email
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/outbound"
+)
+
+func TestHTTPFetcherRefusesInternalAndPlainURLs(t *testing.T) {
+	fetcher := NewHTTPFetcher(1024, outbound.Policy{AllowedHosts: []string{"files.example.com"}})
+	for _, url := range []string{
+		"http://files.example.com/invoice.pdf",
+		"https://cdn.example.com/invoice.pdf",
+		"https://169.254.169.254/latest/meta-data",
+		"https://127.0.0.1/invoice.pdf",
+	} {
+		if _, _, err := fetcher.Fetch(url); !errors.IsValidationError(err) {
+			t.Errorf("Fetch(%q) error = %v, want a validation error", url, err)
+		}
+	}
+}
+
+func TestHTTPFetcherFetchesAllowedURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		if r.URL.Path == "/large.pdf" {
+			w.Write([]byte(strings.Repeat("x", 2048)))
+			return
+		}
+		w.Write([]byte("%PDF-1.7"))
+	}))
+	defer server.Close()
+
+	// Test servers listen on loopback over plain http
+	fetcher := NewHTTPFetcher(1024, outbound.Policy{AllowHTTP: true, AllowPrivate: true})
+	content, contentType, err := fetcher.Fetch(server.URL + "/invoice.pdf")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if string(content) != "%PDF-1.7" || contentType != "application/pdf" {
+		t.Errorf("fetched %q as %s", content, contentType)
+	}
+
+	if _, _, err := fetcher.Fetch(server.URL + "/large.pdf"); !errors.IsValidationError(err) {
+		t.Errorf("oversized attachment: error = %v, want a validation error", err)
+	}
+}
@@ -0,0 +1,37 @@
+package email
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// net/smtp only ships PLAIN and CRAM-MD5, so LOGIN is implemented here for
+// servers (Exchange, Office 365) that only advertise it.
+type loginAuth struct {
+	username string
+	password string
+}
+
+func LoginAuth(username, password string) smtp.Auth {
+	return &loginAuth{username: username, password: password}
+}
+
+func (auth *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "LOGIN", nil, nil
+}
+
+func (auth *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(prompt, "username"):
+		return []byte(auth.username), nil
+	case strings.HasPrefix(prompt, "password"):
+		return []byte(auth.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge: %s", fromServer)
+	}
+}
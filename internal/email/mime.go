@@ -0,0 +1,178 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+const base64LineLength = 76
+
+// reservedHeaders are written by the sender itself and cannot be overridden
+// through Message.Headers.
+var reservedHeaders = map[string]bool{
+	"From":                      true,
+	"Sender":                    true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Reply-To":                  true,
+	"Subject":                   true,
+	"Date":                      true,
+	"Message-Id":                true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+	"Content-Disposition":       true,
+	"Content-Id":                true,
+}
+
+type mimePart struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// buildBody assembles the MIME tree
+//
+//	multipart/mixed                 (only with regular attachments)
+//	└── multipart/related           (only with inline attachments and HTML)
+//	    └── multipart/alternative   (only with both text and HTML)
+//	        ├── text/plain
+//	        └── text/html
+func buildBody(message *Message) (mimePart, error) {
+	var alternatives []mimePart
+	if message.Body != "" || message.HTMLBody == "" {
+		alternatives = append(alternatives, textPart("text/plain; charset=UTF-8", message.Body))
+	}
+	if message.HTMLBody != "" {
+		alternatives = append(alternatives, textPart("text/html; charset=UTF-8", message.HTMLBody))
+	}
+
+	root := alternatives[0]
+	if len(alternatives) > 1 {
+		var err error
+		if root, err = multipartOf("alternative", alternatives); err != nil {
+			return mimePart{}, err
+		}
+	}
+
+	var inline, attached []mimePart
+	for i := range message.Attachments {
+		attachment := &message.Attachments[i]
+		if attachment.Inline && message.HTMLBody != "" {
+			inline = append(inline, attachmentPart(attachment, "inline"))
+		} else {
+			attached = append(attached, attachmentPart(attachment, "attachment"))
+		}
+	}
+
+	if len(inline) > 0 {
+		related, err := multipartOf("related", append([]mimePart{root}, inline...))
+		if err != nil {
+			return mimePart{}, err
+		}
+		root = related
+	}
+	if len(attached) > 0 {
+		mixed, err := multipartOf("mixed", append([]mimePart{root}, attached...))
+		if err != nil {
+			return mimePart{}, err
+		}
+		root = mixed
+	}
+	return root, nil
+}
+
+func textPart(contentType, body string) mimePart {
+	var buffer bytes.Buffer
+	writeQuotedPrintable(&buffer, body)
+	return mimePart{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		body: buffer.Bytes(),
+	}
+}
+
+func attachmentPart(attachment *Attachment, disposition string) mimePart {
+	header := textproto.MIMEHeader{
+		"Content-Type":              {attachment.resolvedContentType()},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename})},
+	}
+	if attachment.ContentID != "" {
+		header.Set("Content-ID", "<"+attachment.ContentID+">")
+	}
+	return mimePart{header: header, body: encodeBase64Lines(attachment.Content)}
+}
+
+func multipartOf(subtype string, parts []mimePart) (mimePart, error) {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	for _, part := range parts {
+		partWriter, err := writer.CreatePart(part.header)
+		if err != nil {
+			return mimePart{}, err
+		}
+		if _, err := partWriter.Write(part.body); err != nil {
+			return mimePart{}, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return mimePart{}, err
+	}
+
+	return mimePart{
+		header: textproto.MIMEHeader{
+			"Content-Type": {mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": writer.Boundary()})},
+		},
+		body: buffer.Bytes(),
+	}, nil
+}
+
+func encodeBase64Lines(content []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	var buffer bytes.Buffer
+	for len(encoded) > base64LineLength {
+		buffer.WriteString(encoded[:base64LineLength])
+		buffer.WriteString("\r\n")
+		encoded = encoded[base64LineLength:]
+	}
+	buffer.WriteString(encoded)
+	return buffer.Bytes()
+}
+
+func writeHeaders(buffer *bytes.Buffer, header textproto.MIMEHeader) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(buffer, "%s: %s\r\n", name, value)
+		}
+	}
+}
+
+func validateCustomHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if reservedHeaders[canonical] {
+			return fmt.Errorf("header %s cannot be overridden", name)
+		}
+		if name == "" || strings.ContainsAny(name, " :\r\n\t") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("header %s contains a line break", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package email
+
+import (
+	"net/mail"
+	"strings"
+)
+
+type Sender interface {
+	Send(message *Message) error
+}
+
+type Message struct {
+	From        *mail.Address
+	Sender      *mail.Address
+	To          []*mail.Address
+	CC          []*mail.Address
+	BCC         []*mail.Address
+	ReplyTo     []*mail.Address
+	Subject     string
+	Body        string
+	HTMLBody    string
+	Headers     map[string]string
+	Attachments []Attachment
+}
+
+func (message *Message) Recipients() []string {
+	recipients := make([]string, 0, len(message.To)+len(message.CC)+len(message.BCC))
+	for _, list := range [][]*mail.Address{message.To, message.CC, message.BCC} {
+		for _, address := range list {
+			recipients = append(recipients, address.Address)
+		}
+	}
+	return recipients
+}
+
+// formatAddresses renders a header value; mail.Address.String applies RFC 2047
+// encoding to non-ASCII display names.
+func formatAddresses(addresses []*mail.Address) string {
+	formatted := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		formatted = append(formatted, address.String())
+	}
+	return strings.Join(formatted, ", ")
+}
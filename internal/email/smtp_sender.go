@@ -0,0 +1,316 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/outbound"
+
+	"github.com/google/uuid"
+)
+
+type Encryption string
+
+const (
+	EncryptionNone     Encryption = "none"
+	EncryptionStartTLS Encryption = "starttls"
+	EncryptionTLS      Encryption = "tls"
+)
+
+type AuthMechanism string
+
+const (
+	AuthNone  AuthMechanism = ""
+	AuthPlain AuthMechanism = "plain"
+	AuthLogin AuthMechanism = "login"
+)
+
+const defaultTimeout = 30 * time.Second
+
+type SMTPConfig struct {
+	Host          string
+	Port          string
+	Username      string
+	Password      string
+	From          string
+	Encryption    Encryption
+	AuthMechanism AuthMechanism
+	Timeout       time.Duration
+	TLSConfig     *tls.Config
+	Limits        Limits
+	Fetcher       Fetcher
+}
+
+type SMTPSender struct {
+	config SMTPConfig
+	from   *mail.Address
+}
+
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.Encryption == "" {
+		config.Encryption = EncryptionStartTLS
+	}
+	if config.Fetcher == nil {
+		config.Fetcher = NewHTTPFetcher(config.Limits.MaxAttachmentSize, outbound.Policy{})
+	}
+
+	sender := &SMTPSender{config: config}
+	if from, err := mail.ParseAddress(config.From); err == nil {
+		sender.from = from
+	}
+	return sender
+}
+
+func (sender *SMTPSender) Send(message *Message) error {
+	from := message.From
+	if from == nil {
+		from = sender.from
+	}
+	if from == nil {
+		return errors.NewProcessingError("sender address is not configured or invalid", nil)
+	}
+	envelopeFrom := from.Address
+	if message.Sender != nil {
+		envelopeFrom = message.Sender.Address
+	}
+
+	recipients := message.Recipients()
+	if len(recipients) == 0 {
+		return errors.NewValidationError("at least one recipient is required", nil)
+	}
+
+	if err := sender.resolveAttachments(message); err != nil {
+		return err
+	}
+
+	data, err := sender.buildMessage(from, message)
+	if err != nil {
+		return errors.NewProcessingError("failed to build email message", err)
+	}
+	if err := sender.config.Limits.checkMessageSize(int64(len(data))); err != nil {
+		return err
+	}
+
+	client, err := sender.dial()
+	if err != nil {
+		return classifyError("failed to connect to SMTP server", err)
+	}
+	defer client.Close()
+
+	if err := sender.authenticate(client); err != nil {
+		return classifyError("SMTP authentication failed", err)
+	}
+	if err := client.Mail(envelopeFrom); err != nil {
+		return classifyError("SMTP server rejected sender", err)
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return classifyError(fmt.Sprintf("SMTP server rejected recipient %s", recipient), err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return classifyError("SMTP server rejected message data", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return classifyError("failed to write message data", err)
+	}
+	if err := writer.Close(); err != nil {
+		return classifyError("SMTP server rejected message", err)
+	}
+
+	// The message has already been accepted, so a failed QUIT is not a delivery error
+	client.Quit()
+	return nil
+}
+
+func (sender *SMTPSender) dial() (*smtp.Client, error) {
+	address := net.JoinHostPort(sender.config.Host, sender.config.Port)
+	dialer := &net.Dialer{Timeout: sender.config.Timeout}
+
+	var conn net.Conn
+	var err error
+	if sender.config.Encryption == EncryptionTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, sender.tlsConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(sender.config.Timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, sender.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if sender.config.Encryption == EncryptionStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("server %s does not support STARTTLS", address)
+		}
+		if err := client.StartTLS(sender.tlsConfig()); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+func (sender *SMTPSender) tlsConfig() *tls.Config {
+	if sender.config.TLSConfig != nil {
+		return sender.config.TLSConfig
+	}
+	return &tls.Config{ServerName: sender.config.Host}
+}
+
+func (sender *SMTPSender) authenticate(client *smtp.Client) error {
+	var auth smtp.Auth
+	switch sender.config.AuthMechanism {
+	case AuthNone:
+		return nil
+	case AuthPlain:
+		auth = smtp.PlainAuth("", sender.config.Username, sender.config.Password, sender.config.Host)
+	case AuthLogin:
+		auth = LoginAuth(sender.config.Username, sender.config.Password)
+	default:
+		return fmt.Errorf("unsupported auth mechanism: %s", sender.config.AuthMechanism)
+	}
+
+	if ok, _ := client.Extension("AUTH"); !ok {
+		return fmt.Errorf("server does not support AUTH")
+	}
+	return client.Auth(auth)
+}
+
+func (sender *SMTPSender) resolveAttachments(message *Message) error {
+	for i := range message.Attachments {
+		attachment := &message.Attachments[i]
+		if attachment.URL == "" {
+			continue
+		}
+		content, contentType, err := sender.config.Fetcher.Fetch(attachment.URL)
+		if err != nil {
+			return err
+		}
+		attachment.Content = content
+		if attachment.ContentType == "" {
+			attachment.ContentType = contentType
+		}
+	}
+	return nil
+}
+
+func (sender *SMTPSender) buildMessage(from *mail.Address, message *Message) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	headers := []struct {
+		name  string
+		value string
+	}{
+		{"From", from.String()},
+		{"Sender", formatOptionalAddress(message.Sender)},
+		{"To", formatAddresses(message.To)},
+		{"Cc", formatAddresses(message.CC)},
+		{"Reply-To", formatAddresses(message.ReplyTo)},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", uuid.New().String(), messageIDDomain(from.Address, sender.config.Host))},
+		{"MIME-Version", "1.0"},
+	}
+	for _, header := range headers {
+		if header.value == "" {
+			continue
+		}
+		fmt.Fprintf(&buffer, "%s: %s\r\n", header.name, header.value)
+	}
+	custom := make(textproto.MIMEHeader, len(message.Headers))
+	for name, value := range message.Headers {
+		custom.Set(name, mime.QEncoding.Encode("utf-8", value))
+	}
+	writeHeaders(&buffer, custom)
+
+	body, err := buildBody(message)
+	if err != nil {
+		return nil, err
+	}
+	writeHeaders(&buffer, body.header)
+	buffer.WriteString("\r\n")
+	buffer.Write(body.body)
+	return buffer.Bytes(), nil
+}
+
+func formatOptionalAddress(address *mail.Address) string {
+	if address == nil {
+		return ""
+	}
+	return address.String()
+}
+
+func messageIDDomain(from, fallback string) string {
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		return from[at+1:]
+	}
+	return fallback
+}
+
+func writeQuotedPrintable(writer io.Writer, body string) error {
+	qpWriter := quotedprintable.NewWriter(writer)
+	if _, err := qpWriter.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qpWriter.Close()
+}
+
+func classifyError(description string, err error) error {
+	var protoErr *textproto.Error
+	if stderrors.As(err, &protoErr) {
+		switch {
+		case protoErr.Code >= 400 && protoErr.Code < 500:
+			return errors.NewRetriableError(description, err)
+		case isRecipientRejection(protoErr.Code):
+			return errors.NewValidationError(description, err)
+		default:
+			return errors.NewProcessingError(description, err)
+		}
+	}
+
+	var netErr net.Error
+	if stderrors.As(err, &netErr) || stderrors.Is(err, io.EOF) || stderrors.Is(err, io.ErrUnexpectedEOF) {
+		return errors.NewRetriableError(description, err)
+	}
+	return errors.NewProcessingError(description, err)
+}
+
+// 5xx replies that point at the message itself (bad mailbox, bad syntax)
+// rather than at the server or our credentials.
+func isRecipientRejection(code int) bool {
+	switch code {
+	case 501, 550, 551, 553:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,461 @@
+package email
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"notificationservice/internal/errors"
+)
+
+// smtpSession is what the fake server saw of one connection.
+type smtpSession struct {
+	tls      bool
+	username string
+	password string
+	from     string
+	to       []string
+	data     string
+}
+
+// fakeSMTPServer speaks just enough SMTP for SMTPSender. Replies maps a verb
+// to a canned reply that is sent instead of the normal handling.
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	startTLS  bool
+	replies   map[string]string
+	sessions  chan *smtpSession
+}
+
+func newFakeSMTPServer(t *testing.T, startTLS bool, replies map[string]string) (*fakeSMTPServer, *x509.CertPool) {
+	t.Helper()
+	certificate, pool := selfSignedCertificate(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &fakeSMTPServer{
+		listener:  listener,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{certificate}},
+		startTLS:  startTLS,
+		replies:   replies,
+		sessions:  make(chan *smtpSession, 1),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.accept()
+	return server, pool
+}
+
+func (server *fakeSMTPServer) port() string {
+	_, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	return port
+}
+
+func (server *fakeSMTPServer) accept() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.serve(conn)
+	}
+}
+
+func (server *fakeSMTPServer) serve(conn net.Conn) {
+	session := &smtpSession{}
+	defer func() {
+		conn.Close()
+		server.sessions <- session
+	}()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			text.PrintfLine("500 empty command")
+			continue
+		}
+		verb := strings.ToUpper(fields[0])
+		if reply, ok := server.replies[verb]; ok {
+			text.PrintfLine("%s", reply)
+			continue
+		}
+
+		switch verb {
+		case "EHLO":
+			lines := []string{"fake"}
+			if server.startTLS && !session.tls {
+				lines = append(lines, "STARTTLS")
+			}
+			lines = append(lines, "AUTH LOGIN PLAIN", "8BITMIME")
+			for i, value := range lines {
+				separator := "-"
+				if i == len(lines)-1 {
+					separator = " "
+				}
+				text.PrintfLine("250%s%s", separator, value)
+			}
+		case "STARTTLS":
+			text.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, server.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			session.tls = true
+		case "AUTH":
+			if len(fields) < 2 || strings.ToUpper(fields[1]) != "LOGIN" {
+				text.PrintfLine("504 unsupported mechanism")
+				continue
+			}
+			if session.username, err = server.challenge(text, "Username:"); err != nil {
+				return
+			}
+			if session.password, err = server.challenge(text, "Password:"); err != nil {
+				return
+			}
+			text.PrintfLine("235 authenticated")
+		case "MAIL":
+			session.from = addressArgument(line)
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			session.to = append(session.to, addressArgument(line))
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			session.data = string(data)
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (server *fakeSMTPServer) challenge(text *textproto.Conn, prompt string) (string, error) {
+	text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt)))
+	line, err := text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(line)
+	return string(decoded), err
+}
+
+func (server *fakeSMTPServer) session(t *testing.T) *smtpSession {
+	t.Helper()
+	select {
+	case session := <-server.sessions:
+		return session
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP session did not finish")
+		return nil
+	}
+}
+
+func addressArgument(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.LastIndex(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func selfSignedCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake SMTP"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func testMessage() *Message {
+	return &Message{
+		To:      []*mail.Address{{Name: "Jane Doe", Address: "jane@example.com"}},
+		CC:      []*mail.Address{{Address: "team@example.com"}},
+		Subject: "Grüße",
+		Body:    "Hello",
+	}
+}
+
+func TestSendUpgradesWithStartTLSAndAuthenticatesWithLogin(t *testing.T) {
+	server, pool := newFakeSMTPServer(t, true, nil)
+	sender := NewSMTPSender(SMTPConfig{
+		Host:          "127.0.0.1",
+		Port:          server.port(),
+		Username:      "user",
+		Password:      "secret",
+		From:          "Notifications <noreply@example.com>",
+		Encryption:    EncryptionStartTLS,
+		AuthMechanism: AuthLogin,
+		TLSConfig:     &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"},
+	})
+
+	if err := sender.Send(testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	session := server.session(t)
+	if !session.tls {
+		t.Error("connection was not upgraded with STARTTLS")
+	}
+	if session.username != "user" || session.password != "secret" {
+		t.Errorf("LOGIN credentials = %q/%q, want user/secret", session.username, session.password)
+	}
+	if session.from != "noreply@example.com" {
+		t.Errorf("MAIL FROM = %q, want noreply@example.com", session.from)
+	}
+	if strings.Join(session.to, ",") != "jane@example.com,team@example.com" {
+		t.Errorf("RCPT TO = %v", session.to)
+	}
+}
+
+func TestSendFailsWhenStartTLSIsNotOffered(t *testing.T) {
+	server, pool := newFakeSMTPServer(t, false, nil)
+	sender := NewSMTPSender(SMTPConfig{
+		Host:       "127.0.0.1",
+		Port:       server.port(),
+		From:       "noreply@example.com",
+		Encryption: EncryptionStartTLS,
+		TLSConfig:  &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"},
+	})
+
+	err := sender.Send(testMessage())
+	if err == nil {
+		t.Fatal("Send succeeded without STARTTLS")
+	}
+	if errors.IsRetriableError(err) {
+		t.Errorf("missing STARTTLS is a configuration problem, got retriable %v", err)
+	}
+}
+
+func TestSendWritesMultipartMessage(t *testing.T) {
+	server, _ := newFakeSMTPServer(t, false, nil)
+	sender := NewSMTPSender(SMTPConfig{
+		Host:       "127.0.0.1",
+		Port:       server.port(),
+		From:       "noreply@example.com",
+		Encryption: EncryptionNone,
+	})
+
+	message := testMessage()
+	message.HTMLBody = `<p>Hello <img src="cid:logo"></p>`
+	message.Headers = map[string]string{"X-Campaign": "spring"}
+	message.Attachments = []Attachment{
+		{Filename: "logo.png", ContentType: "image/png", Content: []byte("png"), Inline: true, ContentID: "logo"},
+		{Filename: "report.txt", Content: []byte("report")},
+	}
+	if err := sender.Send(message); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(server.session(t).data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "Grüße" {
+		t.Errorf("Subject = %q (%v), want Grüße", subject, err)
+	}
+	if to := parsed.Header.Get("To"); to != `"Jane Doe" <jane@example.com>` {
+		t.Errorf("To = %q", to)
+	}
+	if parsed.Header.Get("Bcc") != "" {
+		t.Error("Bcc header must not be written")
+	}
+	if parsed.Header.Get("X-Campaign") != "spring" {
+		t.Errorf("X-Campaign = %q, want spring", parsed.Header.Get("X-Campaign"))
+	}
+	if !strings.HasSuffix(parsed.Header.Get("Message-Id"), "@example.com>") {
+		t.Errorf("Message-ID = %q", parsed.Header.Get("Message-Id"))
+	}
+
+	// mixed → related → alternative → text/plain, text/html
+	mixed := readParts(t, parsed.Header.Get("Content-Type"), parsed.Body, "multipart/mixed")
+	if len(mixed) != 2 {
+		t.Fatalf("multipart/mixed has %d parts, want 2", len(mixed))
+	}
+	if disposition := mixed[1].header.Get("Content-Disposition"); disposition != `attachment; filename=report.txt` {
+		t.Errorf("attachment disposition = %q", disposition)
+	}
+	related := readParts(t, mixed[0].header.Get("Content-Type"), strings.NewReader(mixed[0].body), "multipart/related")
+	if len(related) != 2 {
+		t.Fatalf("multipart/related has %d parts, want 2", len(related))
+	}
+	if related[1].header.Get("Content-Id") != "<logo>" {
+		t.Errorf("inline Content-ID = %q, want <logo>", related[1].header.Get("Content-Id"))
+	}
+	alternative := readParts(t, related[0].header.Get("Content-Type"), strings.NewReader(related[0].body), "multipart/alternative")
+	if len(alternative) != 2 {
+		t.Fatalf("multipart/alternative has %d parts, want 2", len(alternative))
+	}
+	if contentType := alternative[0].header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("first alternative = %q, want text/plain", contentType)
+	}
+	if contentType := alternative[1].header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("second alternative = %q, want text/html", contentType)
+	}
+}
+
+func TestBuildMessageEncodesNonASCIIHeaders(t *testing.T) {
+	sender := NewSMTPSender(SMTPConfig{Host: "mail.example.com"})
+	message := testMessage()
+	message.To = []*mail.Address{{Name: "José Müller", Address: "jose@example.com"}}
+	message.CC = []*mail.Address{{Name: "田中 太郎", Address: "tanaka@example.com"}}
+	message.ReplyTo = []*mail.Address{{Name: "Kundenservice Zürich", Address: "support@example.com"}}
+	message.Sender = &mail.Address{Name: "Łukasz", Address: "lukasz@example.com"}
+	message.Headers = map[string]string{"X-Campaign": "Frühling"}
+	message.Attachments = []Attachment{{Filename: "Bericht März.txt", Content: []byte("report")}}
+
+	data, err := sender.buildMessage(&mail.Address{Name: "Zoë", Address: "noreply@example.com"}, message)
+	if err != nil {
+		t.Fatalf("buildMessage: %v", err)
+	}
+	header, _, _ := strings.Cut(string(data), "\r\n\r\n")
+	for _, r := range header {
+		if r > 127 {
+			t.Fatalf("header is not ASCII:\n%s", header)
+		}
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	for name, want := range map[string]string{
+		"From":     "Zoë",
+		"Sender":   "Łukasz",
+		"To":       "José Müller",
+		"Cc":       "田中 太郎",
+		"Reply-To": "Kundenservice Zürich",
+	} {
+		addresses, err := parsed.Header.AddressList(name)
+		if err != nil || len(addresses) != 1 || addresses[0].Name != want {
+			t.Errorf("%s = %q (%v), want %s", name, parsed.Header.Get(name), err, want)
+		}
+	}
+	if campaign, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("X-Campaign")); err != nil || campaign != "Frühling" {
+		t.Errorf("X-Campaign = %q (%v), want Frühling", parsed.Header.Get("X-Campaign"), err)
+	}
+
+	mixed := readParts(t, parsed.Header.Get("Content-Type"), parsed.Body, "multipart/mixed")
+	if len(mixed) != 2 {
+		t.Fatalf("multipart/mixed has %d parts, want 2", len(mixed))
+	}
+	_, params, err := mime.ParseMediaType(mixed[1].header.Get("Content-Disposition"))
+	if err != nil || params["filename"] != "Bericht März.txt" {
+		t.Errorf("attachment disposition = %q (%v)", mixed[1].header.Get("Content-Disposition"), err)
+	}
+}
+
+type part struct {
+	header textproto.MIMEHeader
+	body   string
+}
+
+func readParts(t *testing.T, contentType string, body io.Reader, want string) []part {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != want {
+		t.Fatalf("Content-Type = %q (%v), want %s", contentType, err, want)
+	}
+	reader := multipart.NewReader(body, params["boundary"])
+	var parts []part
+	for {
+		next, err := reader.NextRawPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("read %s part: %v", want, err)
+		}
+		content, err := io.ReadAll(next)
+		if err != nil {
+			t.Fatalf("read %s part: %v", want, err)
+		}
+		parts = append(parts, part{header: next.Header, body: string(content)})
+	}
+}
+
+func TestSendMapsSMTPReplies(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies map[string]string
+		want    errors.ErrorType
+	}{
+		{"greylisted recipient", map[string]string{"RCPT": "450 4.2.1 mailbox busy, try later"}, errors.RetriableError},
+		{"server busy on sender", map[string]string{"MAIL": "421 4.3.2 service not available"}, errors.RetriableError},
+		{"unknown recipient", map[string]string{"RCPT": "550 5.1.1 no such user"}, errors.ValidationError},
+		{"rejected credentials", map[string]string{"AUTH": "535 5.7.8 authentication failed"}, errors.ProcessingError},
+		{"rejected message", map[string]string{"DATA": "554 5.7.1 message refused"}, errors.ProcessingError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := newFakeSMTPServer(t, false, test.replies)
+			sender := NewSMTPSender(SMTPConfig{
+				Host:          "127.0.0.1",
+				Port:          server.port(),
+				Username:      "user",
+				Password:      "secret",
+				From:          "noreply@example.com",
+				Encryption:    EncryptionNone,
+				AuthMechanism: AuthLogin,
+			})
+
+			err := sender.Send(testMessage())
+			if got := errors.GetErrorType(err); got != test.want {
+				t.Errorf("error type = %q, want %q (%v)", got, test.want, err)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package errors
 
 import (
 	"fmt"
+	"time"
 )
 
 type ErrorType string
@@ -12,12 +13,23 @@ const (
 	RetriableError ErrorType = "retriable"
 
 	ProcessingError ErrorType = "processing"
+
+	UnavailableError ErrorType = "unavailable"
+
+	NotFoundError ErrorType = "not_found"
+
+	UnauthorizedError ErrorType = "unauthorized"
+
+	ForbiddenError ErrorType = "forbidden"
 )
 
 type NotificationError struct {
 	Type        ErrorType
 	Description string
 	OriginalErr error
+	// RetryAfter is the earliest time a retriable error may be retried, as
+	// asked for by a rate limited provider.
+	RetryAfter time.Duration
 }
 
 func (e *NotificationError) Error() string {
@@ -43,6 +55,15 @@ func NewRetriableError(description string, err error) *NotificationError {
 	}
 }
 
+func NewRetriableErrorAfter(description string, err error, retryAfter time.Duration) *NotificationError {
+	return &NotificationError{
+		Type:        RetriableError,
+		Description: description,
+		OriginalErr: err,
+		RetryAfter:  retryAfter,
+	}
+}
+
 func NewProcessingError(description string, err error) *NotificationError {
 	return &NotificationError{
 		Type:        ProcessingError,
@@ -51,6 +72,38 @@ func NewProcessingError(description string, err error) *NotificationError {
 	}
 }
 
+func NewUnavailableError(description string, err error) *NotificationError {
+	return &NotificationError{
+		Type:        UnavailableError,
+		Description: description,
+		OriginalErr: err,
+	}
+}
+
+func NewNotFoundError(description string, err error) *NotificationError {
+	return &NotificationError{
+		Type:        NotFoundError,
+		Description: description,
+		OriginalErr: err,
+	}
+}
+
+func NewUnauthorizedError(description string, err error) *NotificationError {
+	return &NotificationError{
+		Type:        UnauthorizedError,
+		Description: description,
+		OriginalErr: err,
+	}
+}
+
+func NewForbiddenError(description string, err error) *NotificationError {
+	return &NotificationError{
+		Type:        ForbiddenError,
+		Description: description,
+		OriginalErr: err,
+	}
+}
+
 func IsValidationError(err error) bool {
 	if notifErr, ok := err.(*NotificationError); ok {
 		return notifErr.Type == ValidationError
@@ -65,6 +118,20 @@ func IsRetriableError(err error) bool {
 	return false
 }
 
+func IsUnavailableError(err error) bool {
+	if notifErr, ok := err.(*NotificationError); ok {
+		return notifErr.Type == UnavailableError
+	}
+	return false
+}
+
+func IsNotFoundError(err error) bool {
+	if notifErr, ok := err.(*NotificationError); ok {
+		return notifErr.Type == NotFoundError
+	}
+	return false
+}
+
 func GetErrorType(err error) ErrorType {
 	if notifErr, ok := err.(*NotificationError); ok {
 		return notifErr.Type
@@ -72,6 +139,13 @@ func GetErrorType(err error) ErrorType {
 	return ""
 }
 
+func GetRetryAfter(err error) time.Duration {
+	if notifErr, ok := err.(*NotificationError); ok {
+		return notifErr.RetryAfter
+	}
+	return 0
+}
+
 func GetErrorDescription(err error) string {
 	if notifErr, ok := err.(*NotificationError); ok {
 		return notifErr.Description
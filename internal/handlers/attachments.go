@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+
+	"notificationservice/internal/email"
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+	"notificationservice/internal/repository"
+)
+
+// AttachmentOptions configure how email attachments are checked when a
+// message arrives and where their content is kept until the email is sent.
+type AttachmentOptions struct {
+	Store  repository.AttachmentRepository
+	Limits email.Limits
+}
+
+func (handler *Handler) SetAttachmentOptions(options AttachmentOptions) {
+	handler.attachments = options
+}
+
+// validateAttachments applies the email limits as the message arrives, so
+// an oversized or malformed attachment is rejected before it is stored.
+// Delivery checks again once templates are rendered and URLs fetched.
+func (handler *Handler) validateAttachments(message *models.NotificationMessage) error {
+	mailInfos := []*models.MailDetails{message.MailInfo}
+	for _, override := range message.Channels {
+		mailInfos = append(mailInfos, override.MailInfo)
+	}
+	for _, mailInfo := range mailInfos {
+		if mailInfo == nil || len(mailInfo.Attachments) == 0 {
+			continue
+		}
+		body := message.Body
+		if mailInfo.TextBody != "" {
+			body = mailInfo.TextBody
+		}
+		err := handler.attachments.Limits.Validate(&email.Message{
+			Body:        body,
+			HTMLBody:    mailInfo.HTMLBody,
+			Headers:     mailInfo.Headers,
+			Attachments: emailAttachments(mailInfo.Attachments),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeAttachments moves inline attachment content to the attachment store,
+// leaving a reference in the notification.
+func (handler *Handler) storeAttachments(notification *models.Notification) error {
+	mailInfos := []*models.MailDetails{notification.MailInfo}
+	for _, override := range notification.Channels {
+		mailInfos = append(mailInfos, override.MailInfo)
+	}
+	for _, mailInfo := range mailInfos {
+		if mailInfo == nil {
+			continue
+		}
+		for i := range mailInfo.Attachments {
+			attachment := &mailInfo.Attachments[i]
+			if len(attachment.Content) == 0 || attachment.ContentRef != nil {
+				continue
+			}
+			attachment.Size = int64(len(attachment.Content))
+			if handler.attachments.Store == nil {
+				continue
+			}
+			id, err := handler.attachments.Store.SaveAttachmentContent(attachment.Content)
+			if err != nil {
+				return errors.NewRetriableError("failed to store attachment content", err)
+			}
+			attachment.ContentRef = &id
+		}
+	}
+	return nil
+}
+
+func emailAttachments(attachments []models.Attachment) []email.Attachment {
+	converted := make([]email.Attachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		converted = append(converted, email.Attachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Content:     attachment.Content,
+			URL:         attachment.URL,
+			Inline:      attachment.Inline,
+			ContentID:   attachment.ContentID,
+		})
+	}
+	return converted
+}
+
+// loadAttachmentContent fills in the content of attachments that only carry
+// a reference, as notifications read back from the repository do.
+func loadAttachmentContent(store repository.AttachmentRepository, attachments []email.Attachment, stored []models.Attachment) error {
+	for i := range attachments {
+		if len(attachments[i].Content) > 0 || stored[i].ContentRef == nil {
+			continue
+		}
+		if store == nil {
+			return errors.NewProcessingError("no attachment store configured", nil)
+		}
+		content, err := store.GetAttachmentContent(*stored[i].ContentRef)
+		if err != nil {
+			return errors.NewRetriableError("failed to load attachment content", err)
+		}
+		if content == nil {
+			return errors.NewValidationError(fmt.Sprintf("content of attachment %s is no longer available", stored[i].Filename), nil)
+		}
+		attachments[i].Content = content
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"notificationservice/internal/email"
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+	"notificationservice/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type recordingSender struct {
+	sent []*email.Message
+}
+
+func (sender *recordingSender) Send(message *email.Message) error {
+	sender.sent = append(sender.sent, message)
+	return nil
+}
+
+// attachmentMessage is built as raw JSON, since attachment content is left
+// out when marshalling.
+func attachmentMessage(userId uuid.UUID, content []byte) []byte {
+	return []byte(`{
+		"userId": "` + userId.String() + `",
+		"externalId": "` + uuid.NewString() + `",
+		"subject": "Invoice",
+		"body": "Your invoice is attached",
+		"type": "Mail",
+		"mailInfo": {
+			"to": ["jane@example.com"],
+			"attachments": [{"filename": "invoice.pdf", "content": "` + base64.StdEncoding.EncodeToString(content) + `"}]
+		}
+	}`)
+}
+
+func TestAttachmentLimitsAreCheckedOnArrival(t *testing.T) {
+	test := newTestHandler(t)
+	store := repository.NewMemoryAttachmentRepository()
+	test.SetAttachmentOptions(AttachmentOptions{Store: store, Limits: email.Limits{MaxAttachmentSize: 1024}})
+	userId := uuid.New()
+
+	err := test.ProcessMessage(attachmentMessage(userId, bytes.Repeat([]byte("x"), 1025)))
+	if !errors.IsValidationError(err) {
+		t.Fatalf("error = %v, want a validation error", err)
+	}
+	if got := len(test.notifications(t, userId)); got != 0 {
+		t.Errorf("stored %d notifications, want none", got)
+	}
+	if got := len(test.channels[models.EmailNotification].delivered); got != 0 {
+		t.Errorf("delivered %d times, want 0", got)
+	}
+
+	message := `{"userId": "` + userId.String() + `", "externalId": "` + uuid.NewString() + `", "subject": "s", "body": "b",
+		"type": "Mail", "mailInfo": {"to": ["jane@example.com"], "attachments": [{"filename": "a.txt"}]}}`
+	if err := test.ProcessMessage([]byte(message)); !errors.IsValidationError(err) {
+		t.Errorf("attachment without content or URL: error = %v, want a validation error", err)
+	}
+}
+
+func TestAttachmentContentIsStoredApart(t *testing.T) {
+	test := newTestHandler(t)
+	store := repository.NewMemoryAttachmentRepository()
+	test.SetAttachmentOptions(AttachmentOptions{Store: store, Limits: email.DefaultLimits()})
+	userId := uuid.New()
+	content := []byte("%PDF-1.7 invoice")
+
+	if err := test.ProcessMessage(attachmentMessage(userId, content)); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	// The memory repository round-trips through BSON like MongoDB does
+	notification := test.only(t, userId)
+	attachment := notification.MailInfo.Attachments[0]
+	if attachment.Content != nil {
+		t.Error("content was stored with the notification")
+	}
+	if attachment.ContentRef == nil || attachment.Size != int64(len(content)) {
+		t.Fatalf("attachment = %+v, want a content reference and size", attachment)
+	}
+	if stored, _ := store.GetAttachmentContent(*attachment.ContentRef); !bytes.Equal(stored, content) {
+		t.Errorf("stored content = %q, want %q", stored, content)
+	}
+
+	// Nor is it part of the JSON, even while the content is loaded
+	attachment.Content = content
+	data, err := json.Marshal(attachment)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(data), `"content"`) || !strings.Contains(string(data), `"size":16`) {
+		t.Errorf("JSON = %s, want the size without the content", data)
+	}
+
+	// Delivering the stored notification loads the content back
+	sender := &recordingSender{}
+	emailHandler := NewEmailHandler(sender, EmailOptions{Limits: email.DefaultLimits(), Attachments: store})
+	if err := emailHandler.Deliver(&notification); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if got := sender.sent[0].Attachments[0].Content; !bytes.Equal(got, content) {
+		t.Errorf("sent content = %q, want %q", got, content)
+	}
+}
+
+func TestMissingAttachmentContentFailsDelivery(t *testing.T) {
+	store := repository.NewMemoryAttachmentRepository()
+	missing, _ := repository.NewMemoryAttachmentRepository().SaveAttachmentContent([]byte("gone"))
+	notification := &models.Notification{
+		Subject: "Invoice",
+		Body:    "Your invoice is attached",
+		MailInfo: &models.MailDetails{
+			To:          models.AddressList{{Address: "jane@example.com"}},
+			Attachments: []models.Attachment{{Filename: "invoice.pdf", ContentRef: &missing}},
+		},
+	}
+
+	sender := &recordingSender{}
+	err := NewEmailHandler(sender, EmailOptions{Attachments: store}).Deliver(notification)
+	if !errors.IsValidationError(err) {
+		t.Errorf("error = %v, want a validation error", err)
+	}
+	if len(sender.sent) != 0 {
+		t.Error("sent without the attachment")
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+
+	"notificationservice/internal/chat"
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+)
+
+const defaultChatDestination = "default"
+
+type ChatHandler struct {
+	destinations map[string]chat.Provider
+}
+
+func NewChatHandler(destinations map[string]chat.Provider) IHandler {
+	return &ChatHandler{destinations: destinations}
+}
+
+func ChatChannel(destinations map[string]chat.Provider) Channel {
+	return Channel{
+		Type:    models.ChatNotification,
+		Handler: NewChatHandler(destinations),
+	}
+}
+
+func (h *ChatHandler) Deliver(notification *models.Notification) error {
+	destination := defaultChatDestination
+	if notification.ChatInfo != nil && notification.ChatInfo.Destination != "" {
+		destination = notification.ChatInfo.Destination
+	}
+
+	provider, ok := h.destinations[destination]
+	if !ok {
+		return errors.NewValidationError(fmt.Sprintf("unknown chat destination: %s", destination), nil)
+	}
+	return provider.Send(&chat.Message{Title: notification.Subject, Text: notification.Body})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func (handler *Handler) RegisterDevice(userId uuid.UUID, token string, platform models.Platform) (*models.Device, error) {
+	if token == "" {
+		return nil, errors.NewValidationError("token is required", nil)
+	}
+	if !platform.IsValid() {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid platform: %s", platform), nil)
+	}
+
+	device := &models.Device{UserID: userId, Token: token, Platform: platform}
+	if err := handler.devices.SaveDevice(device); err != nil {
+		return nil, errors.NewProcessingError("failed to register device", err)
+	}
+	return device, nil
+}
+
+func (handler *Handler) UnregisterDevice(userId uuid.UUID, token string) error {
+	deleted, err := handler.devices.DeleteDevice(userId, token)
+	if err != nil {
+		return errors.NewProcessingError("failed to unregister device", err)
+	}
+	if !deleted {
+		return errors.NewNotFoundError("device not found", nil)
+	}
+	return nil
+}
+
+func (handler *Handler) GetDevices(userId uuid.UUID) ([]models.Device, error) {
+	devices, err := handler.devices.GetDevices(userId)
+	if err != nil {
+		return nil, errors.NewProcessingError("failed to get devices", err)
+	}
+	return devices, nil
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"log"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxDigestMoves bounds how often a notification follows digests that were
+// claimed for sending while it was being added.
+const maxDigestMoves = 3
+
+// DigestOptions configure digest delivery. Categories maps each digest
+// category to the frequency used unless the user picks another; DailyAt is
+// the "HH:MM" (UTC) daily digests are sent at.
+type DigestOptions struct {
+	Categories map[string]models.DigestFrequency
+	TemplateID string
+	DailyAt    string
+}
+
+func (handler *Handler) SetDigestOptions(options DigestOptions) {
+	handler.digest = options
+}
+
+// digestFrequency returns how a notification reaches the user. Only email of
+// a digest category is batched, and not when it is part of a fallback chain.
+func (handler *Handler) digestFrequency(notification *models.Notification, specific, general *models.Preference) models.DigestFrequency {
+	frequency, eligible := handler.digest.Categories[notification.Category]
+	if !eligible || notification.Type != models.EmailNotification || notification.Digest != nil || notification.Fallback != nil {
+		return models.DigestImmediate
+	}
+	for _, preference := range []*models.Preference{specific, general} {
+		if preference != nil && preference.Digest != "" {
+			return preference.Digest
+		}
+	}
+	return frequency
+}
+
+// batch adds a notification to the user's open digest of the given frequency,
+// starting one when there is none.
+func (handler *Handler) batch(notification *models.Notification, frequency models.DigestFrequency) error {
+	digest, err := handler.openDigest(notification, frequency)
+	if err != nil {
+		return err
+	}
+	if err := handler.repo.AttachToDigest(notification.ID, digest.ID); err != nil {
+		return errors.NewRetriableError("failed to update notification status", err)
+	}
+
+	// The digest may have been claimed for sending in the meantime. Then either
+	// it collected the notification already or the notification moves on.
+	for moves := 0; ; moves++ {
+		current, err := handler.repo.GetNotificationByID(digest.ID)
+		if err != nil {
+			return errors.NewRetriableError("database query failed", err)
+		}
+		if current != nil && current.DispatchedAt == nil && current.DeliveryStatus.NotificationStatus == models.Scheduled {
+			break
+		}
+		if moves == maxDigestMoves {
+			return errors.NewRetriableError("digest keeps being sent while notifications are added", nil)
+		}
+
+		next, err := handler.openDigest(notification, frequency)
+		if err != nil {
+			return err
+		}
+		moved, err := handler.repo.MoveToDigest(notification.ID, digest.ID, next.ID)
+		if err != nil {
+			return errors.NewRetriableError("failed to update notification status", err)
+		}
+		if !moved {
+			break
+		}
+		digest = next
+	}
+
+	notification.DigestID = &digest.ID
+	notification.DeliveryStatus = models.DeliveryStatus{NotificationStatus: models.Batched}
+	log.Printf("Notification batched into %s digest: ID=%v, Digest=%v, User=%s",
+		frequency, notification.ID, digest.ID, notification.UserID)
+	return nil
+}
+
+func (handler *Handler) openDigest(notification *models.Notification, frequency models.DigestFrequency) (*models.Notification, error) {
+	digest, err := handler.repo.GetOpenDigest(notification.UserID, frequency)
+	if err != nil {
+		return nil, errors.NewRetriableError("database query failed", err)
+	}
+	if digest != nil {
+		return digest, nil
+	}
+
+	dueAt, err := frequency.NextDue(handler.clock.Now(), handler.digest.DailyAt)
+	if err != nil {
+		return nil, errors.NewProcessingError("failed to schedule digest", err)
+	}
+	digest = &models.Notification{
+		UserID:         notification.UserID,
+		ExternalID:     uuid.New(),
+		TenantID:       notification.TenantID,
+		Type:           models.EmailNotification,
+		Digest:         &models.DigestInfo{Frequency: frequency},
+		DeliverAt:      &dueAt,
+		DeliveryStatus: models.DeliveryStatus{NotificationStatus: models.Scheduled},
+	}
+	if err := handler.repo.SaveNotification(digest); err != nil {
+		return nil, errors.NewRetriableError("database operation failed", err)
+	}
+	return digest, nil
+}
+
+// deliverDigest sends a due digest as one email summarising the
+// notifications batched into it.
+func (handler *Handler) deliverDigest(digest *models.Notification) error {
+	count, err := handler.composeDigest(digest)
+	switch {
+	case err == nil && count == 0:
+		return handler.suppress(digest, "no notifications left to send")
+	case err == nil:
+		err = handler.deliverNotification(digest)
+	case !errors.IsRetriableError(err):
+		err = handler.handleDeliveryStatus(digest, err)
+	}
+	if digest.DeliveryStatus.NotificationStatus == models.Failed {
+		if failErr := handler.failDigestItems(digest, err); failErr != nil {
+			return failErr
+		}
+	}
+	return err
+}
+
+// failDigestItems fails the notifications of a digest that could not be sent,
+// which would otherwise count as delivered.
+func (handler *Handler) failDigestItems(digest *models.Notification, deliveryErr error) error {
+	items, err := handler.repo.FailDigestItems(digest.ID, deliveryErr.Error())
+	if err != nil {
+		return errors.NewRetriableError("failed to update notification status", err)
+	}
+	parents := make(map[primitive.ObjectID]bool)
+	for _, item := range items {
+		if item.ParentID != nil {
+			parents[*item.ParentID] = true
+		}
+	}
+	for parentId := range parents {
+		if err := handler.refreshParentStatus(parentId); err != nil {
+			return err
+		}
+	}
+	log.Printf("Digest failed, %d notifications in it failed too: ID=%v, User=%s", len(items), digest.ID, digest.UserID)
+	return nil
+}
+
+// composeDigest collects the notifications batched into a digest and renders
+// the digest template over them. Notifications that expired while waiting
+// are left out.
+func (handler *Handler) composeDigest(digest *models.Notification) (int, error) {
+	items, err := handler.repo.CollectDigestItems(digest.ID)
+	if err != nil {
+		return 0, errors.NewRetriableError("database query failed", err)
+	}
+
+	entries := make([]map[string]interface{}, 0, len(items))
+	parents := make(map[primitive.ObjectID]bool)
+	var recipients models.AddressList
+	for i := range items {
+		item := &items[i]
+		if item.ParentID != nil {
+			parents[*item.ParentID] = true
+		}
+		if handler.expired(item) {
+			if err := handler.expire(item); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		entries = append(entries, digestItem(item))
+		if item.MailInfo != nil {
+			// The latest notification has the user's current address
+			recipients = item.MailInfo.To
+		}
+	}
+	for parentId := range parents {
+		if err := handler.refreshParentStatus(parentId); err != nil {
+			return 0, err
+		}
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	digest.Digest.Count = len(entries)
+	digest.MailInfo = &models.MailDetails{To: recipients}
+	digest.Template = &models.TemplateReference{
+		ID: handler.digest.TemplateID,
+		Data: map[string]interface{}{
+			"frequency":     string(digest.Digest.Frequency),
+			"count":         len(entries),
+			"notifications": entries,
+		},
+	}
+	if err := handler.renderTemplate(digest); err != nil {
+		return 0, err
+	}
+	if err := handler.repo.UpdateDigestContent(digest); err != nil {
+		return 0, errors.NewRetriableError("database operation failed", err)
+	}
+	return len(entries), nil
+}
+
+// digestItem is what the digest template sees of each notification.
+func digestItem(notification *models.Notification) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        notification.ID.Hex(),
+		"category":  notification.Category,
+		"subject":   notification.Subject,
+		"body":      notification.Body,
+		"createdAt": notification.CreatedAt,
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"notificationservice/internal/models"
+	"notificationservice/internal/templates"
+
+	"github.com/google/uuid"
+)
+
+// templateStore serves templates from memory, all as version 1.
+type templateStore map[string]*templates.Template
+
+func (store templateStore) GetTemplate(templateID string, version int) (*templates.Template, error) {
+	return store[templateID], nil
+}
+
+var digestTemplate = &templates.Template{
+	ID:       "digest",
+	Version:  1,
+	Subject:  "{{.count}} {{.frequency}} updates",
+	TextBody: "{{range .notifications}}{{.subject}}\n{{end}}",
+}
+
+// newDigestHandler batches "news" email into hourly digests, due at 13:00.
+func newDigestHandler(t *testing.T, renderer *templates.Renderer) *testHandler {
+	t.Helper()
+	test := newTestHandler(t)
+	test.renderer = renderer
+	test.SetDigestOptions(DigestOptions{
+		Categories: map[string]models.DigestFrequency{"news": models.DigestHourly},
+		TemplateID: "digest",
+	})
+	return test
+}
+
+func newsMessage(userId uuid.UUID, subject string) models.NotificationMessage {
+	message := newMessage(userId, models.EmailNotification)
+	message.Category = "news"
+	message.Subject = subject
+	return message
+}
+
+// split separates a user's digest from the notifications batched into it.
+func (test *testHandler) split(t *testing.T, userId uuid.UUID) (digest *models.Notification, items []models.Notification) {
+	t.Helper()
+	for _, notification := range test.notifications(t, userId) {
+		if notification.Digest != nil {
+			found := notification
+			digest = &found
+		} else if notification.DigestID != nil {
+			items = append(items, notification)
+		}
+	}
+	if digest == nil {
+		t.Fatal("no digest was started")
+	}
+	return digest, items
+}
+
+func TestDigestBatchesAndSendsOneEmail(t *testing.T) {
+	test := newDigestHandler(t, templates.NewRenderer(templateStore{"digest": digestTemplate}))
+	userId := uuid.New()
+	mail := test.channels[models.EmailNotification]
+
+	for _, subject := range []string{"First", "Second"} {
+		if err := test.process(t, newsMessage(userId, subject)); err != nil {
+			t.Fatalf("ProcessMessage: %v", err)
+		}
+	}
+	if got := len(mail.delivered); got != 0 {
+		t.Fatalf("delivered %d emails before the digest was due", got)
+	}
+	digest, items := test.split(t, userId)
+	if len(items) != 2 || items[0].DeliveryStatus.NotificationStatus != models.Batched || *items[0].DigestID != digest.ID {
+		t.Fatalf("items = %+v, want both batched into the digest", items)
+	}
+
+	test.now = time.Date(2026, 10, 18, 13, 0, 0, 0, time.UTC)
+	if err := test.ReleaseDue(); err != nil {
+		t.Fatalf("ReleaseDue: %v", err)
+	}
+
+	if len(mail.delivered) != 1 {
+		t.Fatalf("delivered %d emails, want one digest", len(mail.delivered))
+	}
+	sent := mail.delivered[0]
+	if sent.Subject != "2 hourly updates" || sent.Body != "First\nSecond\n" {
+		t.Errorf("digest = %q / %q", sent.Subject, sent.Body)
+	}
+	digest, items = test.split(t, userId)
+	if digest.DeliveryStatus.NotificationStatus != models.Sent || digest.Digest.Count != 2 {
+		t.Errorf("digest = %s with %d items, want Sent with 2", digest.DeliveryStatus.NotificationStatus, digest.Digest.Count)
+	}
+	for _, item := range items {
+		if item.DeliveryStatus.NotificationStatus != models.Digested {
+			t.Errorf("item %s = %s, want Digested", item.Subject, item.DeliveryStatus.NotificationStatus)
+		}
+	}
+}
+
+func TestDigestWithNothingLeftIsSuppressed(t *testing.T) {
+	test := newDigestHandler(t, templates.NewRenderer(templateStore{"digest": digestTemplate}))
+	userId := uuid.New()
+
+	message := newsMessage(userId, "Flash sale")
+	expiresAt := test.now.Add(30 * time.Minute)
+	message.ExpiresAt = &expiresAt
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	test.now = time.Date(2026, 10, 18, 13, 0, 0, 0, time.UTC)
+	if err := test.ReleaseDue(); err != nil {
+		t.Fatalf("ReleaseDue: %v", err)
+	}
+
+	if got := len(test.channels[models.EmailNotification].delivered); got != 0 {
+		t.Errorf("delivered %d emails, want none", got)
+	}
+	digest, items := test.split(t, userId)
+	if digest.DeliveryStatus.NotificationStatus != models.Suppressed {
+		t.Errorf("digest = %s, want Suppressed", digest.DeliveryStatus.NotificationStatus)
+	}
+	if items[0].DeliveryStatus.NotificationStatus != models.Expired {
+		t.Errorf("item = %s, want Expired", items[0].DeliveryStatus.NotificationStatus)
+	}
+}
+
+func TestFailedDigestFailsItsNotifications(t *testing.T) {
+	tests := []struct {
+		name      string
+		renderer  *templates.Renderer
+		wantError string
+	}{
+		{"templates not configured", nil, "templates are not configured"},
+		{"digest template missing", templates.NewRenderer(templateStore{}), "unknown template digest"},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			test := newDigestHandler(t, testCase.renderer)
+			userId := uuid.New()
+
+			// The in-app channel is sent right away, the email is batched
+			message := newsMessage(userId, "Weekly picks")
+			message.Type = ""
+			message.Channels = []models.ChannelOverride{{Type: models.EmailNotification}, {Type: models.InAppNotification}}
+			if err := test.process(t, message); err != nil {
+				t.Fatalf("ProcessMessage: %v", err)
+			}
+
+			test.now = time.Date(2026, 10, 18, 13, 0, 0, 0, time.UTC)
+			if err := test.ReleaseDue(); err != nil {
+				t.Fatalf("ReleaseDue: %v", err)
+			}
+
+			digest, items := test.split(t, userId)
+			if digest.DeliveryStatus.NotificationStatus != models.Failed {
+				t.Errorf("digest = %s, want Failed", digest.DeliveryStatus.NotificationStatus)
+			}
+			item := items[0]
+			if item.DeliveryStatus.NotificationStatus != models.Failed || !strings.Contains(item.DeliveryStatus.Error, testCase.wantError) {
+				t.Errorf("item = %+v, want Failed with %q", item.DeliveryStatus, testCase.wantError)
+			}
+			parent, err := test.GetNotification(*item.ParentID)
+			if err != nil {
+				t.Fatalf("GetNotification: %v", err)
+			}
+			if parent.DeliveryStatus.NotificationStatus != models.PartiallySent {
+				t.Errorf("parent = %s, want PartiallySent", parent.DeliveryStatus.NotificationStatus)
+			}
+		})
+	}
+}
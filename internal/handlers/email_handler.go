@@ -3,45 +3,124 @@ package handlers
 import (
 	"fmt"
 	"net/mail"
+	"strings"
 
+	"notificationservice/internal/email"
 	"notificationservice/internal/errors"
 	"notificationservice/internal/models"
+	"notificationservice/internal/repository"
 )
 
-type EmailHandler struct{}
+type EmailOptions struct {
+	Limits               email.Limits
+	AllowedSenderDomains []string
+	// Attachments holds the content of attachments that were stored with
+	// the notification.
+	Attachments repository.AttachmentRepository
+}
+
+type EmailHandler struct {
+	sender  email.Sender
+	options EmailOptions
+}
 
-func NewEmailHandler() IHandler {
-	return &EmailHandler{}
+func NewEmailHandler(sender email.Sender, options EmailOptions) IHandler {
+	return &EmailHandler{sender: sender, options: options}
+}
+
+func EmailChannel(sender email.Sender, options EmailOptions) Channel {
+	return Channel{
+		Type:         models.EmailNotification,
+		Handler:      NewEmailHandler(sender, options),
+		Capabilities: Capabilities{HTML: true, Attachments: true},
+	}
 }
 
 func (h *EmailHandler) Deliver(notification *models.Notification) error {
-	if err := h.validate(notification); err != nil {
+	message, err := h.buildMessage(notification)
+	if err != nil {
+		return err
+	}
+	if err := h.options.Limits.Validate(message); err != nil {
 		return err
 	}
-	// TODO: implement email sending logic
-	fmt.Println("Sending email notification to", notification.MailInfo.To)
-	return nil
+	return h.sender.Send(message)
 }
 
-func (h *EmailHandler) validate(notification *models.Notification) error {
-	if notification.MailInfo == nil {
-		return errors.NewValidationError("mailInfo is required for email notifications", nil)
+func (h *EmailHandler) buildMessage(notification *models.Notification) (*email.Message, error) {
+	mailInfo := notification.MailInfo
+	if mailInfo == nil {
+		return nil, errors.NewValidationError("mailInfo is required for email notifications", nil)
+	}
+	if len(mailInfo.To) == 0 {
+		return nil, errors.NewValidationError("recipient email is required", nil)
+	}
+
+	message := &email.Message{
+		Subject:  notification.Subject,
+		Body:     notification.Body,
+		HTMLBody: mailInfo.HTMLBody,
+		Headers:  mailInfo.Headers,
+	}
+	if mailInfo.TextBody != "" {
+		message.Body = mailInfo.TextBody
+	}
+
+	var err error
+	if message.To, err = parseAddresses(mailInfo.To, "recipient"); err != nil {
+		return nil, err
 	}
-	if notification.MailInfo.To == "" {
-		return errors.NewValidationError("recipient email is required", nil)
+	if message.CC, err = parseAddresses(mailInfo.CC, "CC"); err != nil {
+		return nil, err
 	}
-	if _, err := mail.ParseAddress(notification.MailInfo.To); err != nil {
-		return errors.NewValidationError("invalid email address format", err)
+	if message.BCC, err = parseAddresses(mailInfo.BCC, "BCC"); err != nil {
+		return nil, err
 	}
-	for _, cc := range notification.MailInfo.CC {
-		if _, err := mail.ParseAddress(cc); err != nil {
-			return errors.NewValidationError("invalid CC email address format", err)
+	if mailInfo.ReplyTo != nil {
+		if message.ReplyTo, err = parseAddresses(models.AddressList{*mailInfo.ReplyTo}, "Reply-To"); err != nil {
+			return nil, err
 		}
 	}
-	for _, bcc := range notification.MailInfo.BCC {
-		if _, err := mail.ParseAddress(bcc); err != nil {
-			return errors.NewValidationError("invalid BCC email address format", err)
+	if message.From, err = h.parseSenderAddress(mailInfo.From, "From"); err != nil {
+		return nil, err
+	}
+	if message.Sender, err = h.parseSenderAddress(mailInfo.Sender, "Sender"); err != nil {
+		return nil, err
+	}
+
+	message.Attachments = emailAttachments(mailInfo.Attachments)
+	if err := loadAttachmentContent(h.options.Attachments, message.Attachments, mailInfo.Attachments); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (h *EmailHandler) parseSenderAddress(address *models.Address, field string) (*mail.Address, error) {
+	if address == nil {
+		return nil, nil
+	}
+	parsed, err := address.Validate()
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid %s email address format", field), err)
+	}
+
+	domain := strings.ToLower(parsed.Address[strings.LastIndex(parsed.Address, "@")+1:])
+	for _, allowed := range h.options.AllowedSenderDomains {
+		if domain == strings.ToLower(allowed) {
+			return parsed, nil
 		}
 	}
-	return nil
-}
\ No newline at end of file
+	return nil, errors.NewValidationError(fmt.Sprintf("%s domain %s is not an allowed sender domain", field, domain), nil)
+}
+
+func parseAddresses(addresses models.AddressList, field string) ([]*mail.Address, error) {
+	parsed := make([]*mail.Address, 0, len(addresses))
+	for _, address := range addresses {
+		mailAddress, err := address.Validate()
+		if err != nil {
+			return nil, errors.NewValidationError(fmt.Sprintf("invalid %s email address format", field), err)
+		}
+		parsed = append(parsed, mailAddress)
+	}
+	return parsed, nil
+}
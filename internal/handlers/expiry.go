@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+)
+
+// applyExpiry turns a message's ttl into an expiresAt, counted from its
+// sendAt when it is scheduled and from now otherwise.
+func (handler *Handler) applyExpiry(message *models.NotificationMessage, notification *models.Notification) error {
+	if message.TTL < 0 {
+		return errors.NewValidationError("ttl must not be negative", nil)
+	}
+	if message.TTL == 0 {
+		return nil
+	}
+	if message.ExpiresAt != nil {
+		return errors.NewValidationError("expiresAt and ttl are mutually exclusive", nil)
+	}
+
+	from := handler.clock.Now()
+	if notification.DeliverAt != nil && notification.DeliverAt.After(from) {
+		from = *notification.DeliverAt
+	}
+	expiresAt := from.Add(time.Duration(message.TTL))
+	notification.ExpiresAt = &expiresAt
+	return nil
+}
+
+func (handler *Handler) expired(notification *models.Notification) bool {
+	return notification.ExpiresAt != nil && !handler.clock.Now().Before(*notification.ExpiresAt)
+}
+
+func expiredStatus(notification *models.Notification) models.DeliveryStatus {
+	return models.DeliveryStatus{
+		NotificationStatus: models.Expired,
+		Reason:             fmt.Sprintf("expired at %s", notification.ExpiresAt.Format(time.RFC3339)),
+	}
+}
+
+func (handler *Handler) expire(notification *models.Notification) error {
+	notification.DeliveryStatus = expiredStatus(notification)
+	if err := handler.repo.UpdateNotificationStatus(notification.ID, notification.DeliveryStatus); err != nil {
+		return errors.NewRetriableError("failed to update notification status", err)
+	}
+	log.Printf("Notification expired before delivery: ID=%v, User=%s", notification.ID, notification.UserID)
+	return nil
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestExpiredMessageIsSkippedOnRetry(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	mail := test.channels[models.EmailNotification]
+	mail.errs = []error{errors.NewRetriableError("server busy", nil)}
+
+	message := newMessage(userId, models.EmailNotification)
+	message.TTL = models.Duration(10 * time.Minute)
+	if err := test.process(t, message); !errors.IsRetriableError(err) {
+		t.Fatalf("first attempt: error = %v, want a retriable error", err)
+	}
+
+	// The retry comes back after the message expired
+	test.now = test.now.Add(10 * time.Minute)
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+
+	if len(mail.delivered) != 1 {
+		t.Errorf("attempted delivery %d times, want only the first attempt", len(mail.delivered))
+	}
+	status := test.only(t, userId).DeliveryStatus
+	if status.NotificationStatus != models.Expired || !strings.Contains(status.Reason, "2026-10-18T12:10:00Z") {
+		t.Errorf("status = %+v, want Expired at 12:10", status)
+	}
+}
+
+func TestScheduledMessageExpiry(t *testing.T) {
+	tests := []struct {
+		name       string
+		releaseAt  time.Duration
+		wantStatus models.NotificationStatus
+		wantSent   int
+	}{
+		{"released in time", 89 * time.Minute, models.Sent, 1},
+		{"released after expiry", 90 * time.Minute, models.Expired, 0},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			test := newTestHandler(t)
+			userId := uuid.New()
+			start := test.now
+
+			// The ttl counts from the send time, not from now
+			message := newMessage(userId, models.EmailNotification)
+			sendAt := start.Add(time.Hour)
+			message.SendAt = &sendAt
+			message.TTL = models.Duration(30 * time.Minute)
+			if err := test.process(t, message); err != nil {
+				t.Fatalf("ProcessMessage: %v", err)
+			}
+			notification := test.only(t, userId)
+			if notification.ExpiresAt == nil || !notification.ExpiresAt.Equal(sendAt.Add(30*time.Minute)) {
+				t.Fatalf("expires at %v, want 30 minutes after the send time", notification.ExpiresAt)
+			}
+
+			test.now = start.Add(testCase.releaseAt)
+			if err := test.ReleaseDue(); err != nil {
+				t.Fatalf("ReleaseDue: %v", err)
+			}
+			if got := len(test.channels[models.EmailNotification].delivered); got != testCase.wantSent {
+				t.Errorf("delivered %d times, want %d", got, testCase.wantSent)
+			}
+			if status := test.only(t, userId).DeliveryStatus.NotificationStatus; status != testCase.wantStatus {
+				t.Errorf("status = %s, want %s", status, testCase.wantStatus)
+			}
+		})
+	}
+}
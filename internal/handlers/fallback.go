@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+)
+
+const (
+	// fallbackLease keeps other instances off a claimed notification while it
+	// is being delivered.
+	fallbackLease      = 5 * time.Minute
+	fallbackRetryDelay = time.Minute
+	maxFallbackRetries = 5
+)
+
+// SetFallbackPolicies sets the fallback chains applied by category to
+// messages that do not bring their own.
+func (handler *Handler) SetFallbackPolicies(policies map[string][]models.FallbackStep) {
+	handler.fallbackPolicies = policies
+}
+
+// applyFallbackPolicy resolves the chain of a new notification. A message's
+// own chain and its type have to agree; a category policy is only used when
+// the type is unset or matches the policy's first channel.
+func (handler *Handler) applyFallbackPolicy(notification *models.Notification) error {
+	if notification.Fallback == nil {
+		steps, ok := handler.fallbackPolicies[notification.Category]
+		if !ok || notification.Category == "" || len(notification.Channels) > 0 {
+			return nil
+		}
+		if notification.Type != "" && notification.Type != steps[0].Type {
+			return nil
+		}
+		notification.Fallback = models.NewFallbackState(steps)
+	}
+
+	if len(notification.Channels) > 0 {
+		return errors.NewValidationError("fallback and channels are mutually exclusive", nil)
+	}
+	if err := models.ValidateFallbackSteps(notification.Fallback.Steps); err != nil {
+		return errors.NewValidationError("invalid fallback", err)
+	}
+	first := notification.Fallback.Steps[0].Type
+	if notification.Type != "" && notification.Type != first {
+		return errors.NewValidationError(fmt.Sprintf("type %s does not match the first fallback channel %s", notification.Type, first), nil)
+	}
+	notification.Type = first
+	return nil
+}
+
+// deliverWithFallback delivers on the current step of the chain and moves
+// down the chain while channels are unavailable or fail. Retriable errors are
+// returned to the consumer, or, when called by the scheduler, retried by it.
+func (handler *Handler) deliverWithFallback(notification *models.Notification, scheduled bool) error {
+	state := notification.Fallback
+	for {
+		step := state.Current()
+		notification.Type = step.Type
+
+		if handler.expired(notification) {
+			state.CheckAt = nil
+			notification.DeliveryStatus = expiredStatus(notification)
+			return handler.updateFallbackState(notification)
+		}
+
+		check, err := handler.checkPreferences(notification)
+		if err != nil {
+			return err
+		}
+		if check.suppressed != "" {
+			if state.HasNext() {
+				state.Advance(handler.clock.Now(), models.FallbackSuppressed, check.suppressed)
+				continue
+			}
+			state.Record(handler.clock.Now(), models.FallbackSuppressed, check.suppressed)
+			state.CheckAt = nil
+			notification.DeliveryStatus = models.DeliveryStatus{NotificationStatus: models.Suppressed, Reason: check.suppressed}
+			return handler.updateFallbackState(notification)
+		}
+		if check.deferUntil != nil {
+			state.CheckAt = nil
+			return handler.deferDelivery(notification, *check.deferUntil)
+		}
+
+		deliveryErr := handler.channels.Deliver(notification)
+		now := handler.clock.Now()
+
+		if deliveryErr == nil {
+			state.Attempts = 0
+			state.DeliveredVia = step.Type
+			state.Record(now, models.FallbackDelivered, "")
+			state.CheckAt = nil
+			if step.UnreadAfter > 0 && state.HasNext() {
+				checkAt := now.Add(time.Duration(step.UnreadAfter))
+				state.CheckAt = &checkAt
+			}
+			return handler.saveFallbackState(notification, models.Sent, nil)
+		}
+
+		if errors.IsRetriableError(deliveryErr) && (!scheduled || state.Attempts+1 < maxFallbackRetries) {
+			if scheduled {
+				state.Attempts++
+				checkAt := now.Add(fallbackRetryDelay)
+				state.CheckAt = &checkAt
+			}
+			if err := handler.saveFallbackState(notification, models.Pending, deliveryErr); err != nil {
+				log.Printf("Failed to update retry status: %v", err)
+			}
+			return deliveryErr
+		}
+
+		reason := models.FallbackFailed
+		if errors.IsUnavailableError(deliveryErr) {
+			reason = models.FallbackUnavailable
+		}
+		if state.HasNext() {
+			state.Advance(now, reason, deliveryErr.Error())
+			continue
+		}
+
+		state.Record(now, models.FallbackExhausted, deliveryErr.Error())
+		state.CheckAt = nil
+		if errors.IsUnavailableError(deliveryErr) {
+			return handler.saveFallbackState(notification, models.Pending, deliveryErr)
+		}
+		if err := handler.saveFallbackState(notification, models.Failed, deliveryErr); err != nil {
+			log.Printf("Failed to update failed status: %v", err)
+		}
+		return deliveryErr
+	}
+}
+
+func (handler *Handler) saveFallbackState(notification *models.Notification, status models.NotificationStatus, deliveryErr error) error {
+	notification.DeliveryStatus = models.DeliveryStatus{NotificationStatus: status}
+	if deliveryErr != nil {
+		notification.DeliveryStatus.Error = deliveryErr.Error()
+	}
+	return handler.updateFallbackState(notification)
+}
+
+func (handler *Handler) updateFallbackState(notification *models.Notification) error {
+	err := handler.repo.UpdateFallbackState(notification.ID, notification.Type, notification.DeliveryStatus, notification.Fallback)
+	if err != nil {
+		return errors.NewRetriableError("failed to update notification status", err)
+	}
+	return nil
+}
+
+// ProcessDueFallbacks handles every notification whose fallback check is
+// due: a delivered notification still unread moves on to the next channel,
+// a pending one is delivered again.
+func (handler *Handler) ProcessDueFallbacks() error {
+	for {
+		notification, err := handler.repo.ClaimDueFallback(handler.clock.Now(), fallbackLease)
+		if err != nil {
+			return err
+		}
+		if notification == nil {
+			return nil
+		}
+		if err := handler.resumeFallback(notification); err != nil {
+			log.Printf("Fallback delivery failed: ID=%v: %v", notification.ID, err)
+		}
+	}
+}
+
+func (handler *Handler) resumeFallback(notification *models.Notification) error {
+	state := notification.Fallback
+	now := handler.clock.Now()
+	if notification.DeliveryStatus.NotificationStatus == models.Sent {
+		if notification.ReceivedAt != nil || !state.HasNext() || handler.expired(notification) {
+			state.CheckAt = nil
+			if notification.ReceivedAt != nil {
+				state.Record(now, models.FallbackReceived, "")
+			}
+			return handler.saveFallbackState(notification, models.Sent, nil)
+		}
+		state.Advance(now, models.FallbackUnread, fmt.Sprintf("unread after %s", time.Duration(state.Current().UnreadAfter)))
+	}
+	return handler.deliverWithFallback(notification, true)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func fallbackMessage(userId uuid.UUID, steps ...models.FallbackStep) models.NotificationMessage {
+	message := newMessage(userId, "")
+	message.SMSInfo = &models.SMSDetails{PhoneNumber: "+15551234567"}
+	message.Fallback = steps
+	return message
+}
+
+// decisions lists the channel, reason and next channel of each decision.
+func decisions(state *models.FallbackState) [][3]string {
+	var got [][3]string
+	for _, decision := range state.Decisions {
+		got = append(got, [3]string{string(decision.Channel), string(decision.Reason), string(decision.Next)})
+	}
+	return got
+}
+
+func TestFallbackWalksTheChain(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	test.channels[models.InAppNotification].errs = []error{errors.NewUnavailableError("not connected", nil)}
+	test.channels[models.EmailNotification].errs = []error{errors.NewValidationError("no such mailbox", nil)}
+
+	message := fallbackMessage(userId,
+		models.FallbackStep{Type: models.InAppNotification},
+		models.FallbackStep{Type: models.EmailNotification},
+		models.FallbackStep{Type: models.SMSNotification},
+	)
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	notification := test.only(t, userId)
+	if notification.DeliveryStatus.NotificationStatus != models.Sent || notification.Type != models.SMSNotification {
+		t.Errorf("notification = %s via %s, want Sent via SMS", notification.DeliveryStatus.NotificationStatus, notification.Type)
+	}
+	state := notification.Fallback
+	if state.DeliveredVia != models.SMSNotification || state.CheckAt != nil {
+		t.Errorf("state = %+v, want delivered via SMS with nothing left to check", state)
+	}
+	want := [][3]string{
+		{"InApp", "unavailable", "Mail"},
+		{"Mail", "failed", "SMS"},
+		{"SMS", "delivered", ""},
+	}
+	if got := decisions(state); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("decisions = %v, want %v", got, want)
+	}
+	for _, decision := range state.Decisions {
+		if !decision.At.Equal(test.now) {
+			t.Errorf("%s decision at %s, want the handler's clock %s", decision.Channel, decision.At, test.now)
+		}
+	}
+}
+
+func TestFallbackExhaustedChainFails(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	test.channels[models.InAppNotification].errs = []error{errors.NewUnavailableError("not connected", nil)}
+	test.channels[models.EmailNotification].errs = []error{errors.NewValidationError("no such mailbox", nil)}
+
+	message := fallbackMessage(userId,
+		models.FallbackStep{Type: models.InAppNotification},
+		models.FallbackStep{Type: models.EmailNotification},
+	)
+	if err := test.process(t, message); !errors.IsValidationError(err) {
+		t.Fatalf("error = %v, want the last channel's validation error", err)
+	}
+
+	notification := test.only(t, userId)
+	if notification.DeliveryStatus.NotificationStatus != models.Failed {
+		t.Errorf("status = %s, want Failed", notification.DeliveryStatus.NotificationStatus)
+	}
+	if got := decisions(notification.Fallback); len(got) != 2 || got[1] != [3]string{"Mail", "exhausted", ""} {
+		t.Errorf("decisions = %v, want the chain exhausted on Mail", got)
+	}
+}
+
+func TestFallbackEscalatesUnreadNotifications(t *testing.T) {
+	tests := []struct {
+		name         string
+		read         bool
+		wantMail     int
+		wantDecision [3]string
+	}{
+		{"unread", false, 1, [3]string{"InApp", "unread", "Mail"}},
+		{"read", true, 0, [3]string{"InApp", "received", ""}},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			test := newTestHandler(t)
+			userId := uuid.New()
+			start := test.now
+
+			message := fallbackMessage(userId,
+				models.FallbackStep{Type: models.InAppNotification, UnreadAfter: models.Duration(15 * time.Minute)},
+				models.FallbackStep{Type: models.EmailNotification},
+			)
+			if err := test.process(t, message); err != nil {
+				t.Fatalf("ProcessMessage: %v", err)
+			}
+			notification := test.only(t, userId)
+			if checkAt := notification.Fallback.CheckAt; checkAt == nil || !checkAt.Equal(start.Add(15*time.Minute)) {
+				t.Fatalf("check at %v, want 15 minutes after delivery", checkAt)
+			}
+			if testCase.read {
+				if _, err := test.MarkNotificationsRead(userId, []primitive.ObjectID{notification.ID}); err != nil {
+					t.Fatalf("MarkNotificationsRead: %v", err)
+				}
+			}
+
+			mail := test.channels[models.EmailNotification]
+			test.now = start.Add(14 * time.Minute)
+			if err := test.ProcessDueFallbacks(); err != nil {
+				t.Fatalf("ProcessDueFallbacks: %v", err)
+			}
+			if len(mail.delivered) != 0 {
+				t.Fatal("escalated before the notification was due")
+			}
+
+			test.now = start.Add(15 * time.Minute)
+			if err := test.ProcessDueFallbacks(); err != nil {
+				t.Fatalf("ProcessDueFallbacks: %v", err)
+			}
+			if len(mail.delivered) != testCase.wantMail {
+				t.Fatalf("delivered %d emails, want %d", len(mail.delivered), testCase.wantMail)
+			}
+
+			notification = test.only(t, userId)
+			state := notification.Fallback
+			if state.CheckAt != nil {
+				t.Errorf("still checking at %v", state.CheckAt)
+			}
+			got := decisions(state)
+			if len(got) < 2 || got[1] != testCase.wantDecision {
+				t.Fatalf("decisions = %v, want %v second", got, testCase.wantDecision)
+			}
+			if at := state.Decisions[1].At; !at.Equal(test.now) {
+				t.Errorf("decided at %s, want %s", at, test.now)
+			}
+
+			// Nothing is due any more
+			test.now = start.Add(time.Hour)
+			if err := test.ProcessDueFallbacks(); err != nil || len(mail.delivered) != testCase.wantMail {
+				t.Errorf("later check delivered %d emails (%v), want %d", len(mail.delivered), err, testCase.wantMail)
+			}
+		})
+	}
+}
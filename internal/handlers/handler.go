@@ -5,22 +5,43 @@ import (
 	"fmt"
 	"log"
 
+	"notificationservice/internal/clock"
 	"notificationservice/internal/errors"
 	"notificationservice/internal/models"
+	"notificationservice/internal/outbound"
 	"notificationservice/internal/repository"
+	"notificationservice/internal/templates"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Handler struct {
-	repo             *repository.MongoRepository
-	emailHandler     IHandler
-	websocketHandler IHandler
+	repo        repository.NotificationRepository
+	devices     repository.DeviceRepository
+	webhooks    repository.WebhookRepository
+	preferences repository.PreferenceRepository
+	renderer    *templates.Renderer
+	channels    *ChannelRegistry
+
+	fallbackPolicies map[string][]models.FallbackStep
+	lockedCategories map[string]bool
+	quietHours       map[string]*models.QuietHours
+	digest           DigestOptions
+	attachments      AttachmentOptions
+	webhookPolicy    outbound.Policy
+	clock            clock.Clock
 }
 
-func NewHandler(repo *repository.MongoRepository) *Handler {
+func NewHandler(repo repository.NotificationRepository, devices repository.DeviceRepository, webhooks repository.WebhookRepository, preferences repository.PreferenceRepository, channels *ChannelRegistry, renderer *templates.Renderer) *Handler {
 	return &Handler{
-		repo:             repo,
-		emailHandler:     NewEmailHandler(),
-		websocketHandler: NewWebSocketHandler(),
+		repo:        repo,
+		devices:     devices,
+		webhooks:    webhooks,
+		preferences: preferences,
+		renderer:    renderer,
+		channels:    channels,
+		clock:       clock.System(),
 	}
 }
 
@@ -30,10 +51,36 @@ func (handler *Handler) ProcessMessage(data []byte) error {
 		return err
 	}
 
-	deliveryErr := handler.deliverNotification(notification)
+	switch notification.DeliveryStatus.NotificationStatus {
+	case models.Scheduled, models.Deferred, models.Cancelled, models.Batched:
+		// Delivered by ReleaseDue, if at all
+		log.Printf("Notification not delivered now: ID=%v, Status=%s, User=%s",
+			notification.ID, notification.DeliveryStatus.NotificationStatus, notification.UserID)
+		return nil
+	case models.Suppressed, models.Expired:
+		// A replay does not bring back what the user turned off or what is
+		// no longer current
+		log.Printf("Notification not delivered: ID=%v, Status=%s, User=%s",
+			notification.ID, notification.DeliveryStatus.NotificationStatus, notification.UserID)
+		return nil
+	}
+
+	var deliveryErr error
+	switch {
+	case notification.IsMultiChannel():
+		deliveryErr = handler.deliverChannels(notification)
+	case notification.Fallback != nil:
+		deliveryErr = handler.deliverWithFallback(notification, false)
+	default:
+		deliveryErr = handler.deliverNotification(notification)
+	}
 	if deliveryErr != nil {
 		return deliveryErr
 	}
+	switch notification.DeliveryStatus.NotificationStatus {
+	case models.Suppressed, models.Deferred, models.Expired, models.Batched:
+		return nil
+	}
 	
 	log.Printf("Notification processed and delivered: ID=%v, Type=%s, User=%s",
 		notification.ID, notification.Type, notification.UserID)
@@ -52,9 +99,15 @@ func (handler *Handler) getNotification(data []byte) (*models.Notification, erro
 	}
 
 	if existingNotification != nil {
+		if existingNotification.DeliveryStatus.NotificationStatus == models.Failed {
+			return handler.refreshFailed(existingNotification, notification)
+		}
 		return existingNotification, nil
 	}
 	
+	if err := handler.storeAttachments(notification); err != nil {
+		return nil, err
+	}
 	if err := handler.repo.SaveNotification(notification); err != nil {
 		return nil, errors.NewRetriableError("database operation failed", err)
 	}
@@ -62,22 +115,168 @@ func (handler *Handler) getNotification(data []byte) (*models.Notification, erro
 	return notification, nil
 }
 
+// refreshFailed applies a replayed message to the notification that failed
+// with it, since the replay may carry a corrected payload, e.g. one edited
+// with the dlq tool. Of a multi-channel message the channels that failed are
+// rebuilt; the others are left as they are.
+func (handler *Handler) refreshFailed(existing, replayed *models.Notification) (*models.Notification, error) {
+	if err := handler.storeAttachments(replayed); err != nil {
+		return nil, err
+	}
+	replayed.ID = existing.ID
+	replayed.CreatedAt = existing.CreatedAt
+	replayed.DeliveryStatus = existing.DeliveryStatus
+	replayed.DeliveryAttempts = existing.DeliveryAttempts
+	if err := handler.repo.ReplaceNotification(replayed); err != nil {
+		return nil, errors.NewRetriableError("database operation failed", err)
+	}
+
+	if replayed.IsMultiChannel() {
+		children, err := handler.repo.GetChildNotifications(replayed.ID)
+		if err != nil {
+			return nil, errors.NewRetriableError("database query failed", err)
+		}
+		overrides := make(map[models.NotificationType]models.ChannelOverride, len(replayed.Channels))
+		for _, override := range replayed.Channels {
+			overrides[override.Type] = override
+		}
+		for _, child := range children {
+			override, ok := overrides[child.Type]
+			if !ok || child.DeliveryStatus.NotificationStatus != models.Failed {
+				continue
+			}
+			rebuilt, err := handler.buildChannelNotification(replayed, override)
+			if err != nil {
+				return nil, err
+			}
+			rebuilt.ID = child.ID
+			rebuilt.CreatedAt = child.CreatedAt
+			rebuilt.DeliveryStatus = child.DeliveryStatus
+			rebuilt.DeliveryAttempts = child.DeliveryAttempts
+			if err := handler.repo.ReplaceNotification(rebuilt); err != nil {
+				return nil, errors.NewRetriableError("database operation failed", err)
+			}
+		}
+	}
+	return replayed, nil
+}
+
 func (handler *Handler) deliverNotification(notification *models.Notification) error {
-	var deliveryErr error
-	switch notification.Type {
-	case models.EmailNotification:
-		deliveryErr = handler.emailHandler.Deliver(notification)
-	case models.InAppNotification:
-		deliveryErr = handler.websocketHandler.Deliver(notification)
-	default:
-		deliveryErr = errors.NewValidationError(
-			fmt.Sprintf("unknown notification type: %s", notification.Type),
-			nil,
-		)
+	if handler.expired(notification) {
+		return handler.expire(notification)
 	}
+
+	check, err := handler.checkPreferences(notification)
+	if err != nil {
+		return err
+	}
+	if check.suppressed != "" {
+		return handler.suppress(notification, check.suppressed)
+	}
+	if check.digest != "" {
+		return handler.batch(notification, check.digest)
+	}
+	if check.deferUntil != nil {
+		return handler.deferDelivery(notification, *check.deferUntil)
+	}
+
+	deliveryErr := handler.channels.Deliver(notification)
 	return handler.handleDeliveryStatus(notification, deliveryErr)
 }
 
+// deliverChannels delivers each pending channel of a multi-channel message
+// and derives the parent's status from them. A retry redelivers just the
+// channels still pending; replaying a message that failed on every channel
+// tries them all again.
+func (handler *Handler) deliverChannels(parent *models.Notification) error {
+	children, err := handler.channelNotifications(parent)
+	if err != nil {
+		return err
+	}
+
+	replay := parent.DeliveryStatus.NotificationStatus == models.Failed
+	var retryErr, permanentErr error
+	for _, child := range children {
+		status := child.DeliveryStatus.NotificationStatus
+		if status != models.Pending && !(replay && status == models.Failed) {
+			continue
+		}
+		err := handler.deliverNotification(child)
+		switch {
+		case err == nil:
+		case errors.IsRetriableError(err):
+			if retryErr == nil {
+				retryErr = err
+			}
+		case permanentErr == nil:
+			permanentErr = err
+		}
+	}
+
+	parent.DeliveryStatus = models.DeliveryStatus{NotificationStatus: models.AggregateStatus(children)}
+	if err := handler.repo.UpdateNotificationStatus(parent.ID, parent.DeliveryStatus); err != nil {
+		return errors.NewRetriableError("failed to update notification status", err)
+	}
+
+	if retryErr != nil {
+		return retryErr
+	}
+	if parent.DeliveryStatus.NotificationStatus == models.Failed {
+		return permanentErr
+	}
+	return nil
+}
+
+// channelNotifications loads the children of a multi-channel parent and
+// creates those missing, so a retry after a partial save completes the set.
+func (handler *Handler) channelNotifications(parent *models.Notification) ([]*models.Notification, error) {
+	existing, err := handler.repo.GetChildNotifications(parent.ID)
+	if err != nil {
+		return nil, errors.NewRetriableError("database query failed", err)
+	}
+	byType := make(map[models.NotificationType]*models.Notification, len(existing))
+	for i := range existing {
+		byType[existing[i].Type] = &existing[i]
+	}
+
+	children := make([]*models.Notification, 0, len(parent.Channels))
+	for _, override := range parent.Channels {
+		child, ok := byType[override.Type]
+		if !ok {
+			if child, err = handler.newChannelNotification(parent, override); err != nil {
+				return nil, err
+			}
+		}
+		children = append(children, child)
+	}
+	return children, nil
+}
+
+func (handler *Handler) newChannelNotification(parent *models.Notification, override models.ChannelOverride) (*models.Notification, error) {
+	child, err := handler.buildChannelNotification(parent, override)
+	if err != nil {
+		return nil, err
+	}
+	if err := handler.repo.SaveNotification(child); err != nil {
+		return nil, errors.NewRetriableError("database operation failed", err)
+	}
+	return child, nil
+}
+
+func (handler *Handler) buildChannelNotification(parent *models.Notification, override models.ChannelOverride) (*models.Notification, error) {
+	child := parent.NewChannelNotification(override)
+	if err := handler.renderTemplate(child); err != nil {
+		return nil, err
+	}
+	if override.Subject != "" {
+		child.Subject = override.Subject
+	}
+	if override.Body != "" {
+		child.Body = override.Body
+	}
+	return child, nil
+}
+
 func (handler *Handler) handleDeliveryStatus(notification *models.Notification, deliveryErr error) error {
 	if deliveryErr == nil {
 		notification.DeliveryStatus = models.DeliveryStatus{
@@ -89,6 +288,19 @@ func (handler *Handler) handleDeliveryStatus(notification *models.Notification,
 		return nil
 	} 
 	
+	if errors.IsUnavailableError(deliveryErr) {
+		notification.DeliveryStatus = models.DeliveryStatus{
+			NotificationStatus: models.Pending,
+			Error:             deliveryErr.Error(),
+		}
+		if err := handler.repo.UpdateNotificationStatus(notification.ID, notification.DeliveryStatus); err != nil {
+			return errors.NewRetriableError("failed to update notification status", err)
+		}
+		log.Printf("Recipient unavailable, notification kept pending: ID=%v, User=%s",
+			notification.ID, notification.UserID)
+		return nil
+	}
+
 	if errors.IsRetriableError(deliveryErr) {
 		notification.DeliveryStatus = models.DeliveryStatus{
 			NotificationStatus: models.Pending,
@@ -118,21 +330,143 @@ func (handler *Handler) unmarshalMessage(data []byte) (*models.Notification, err
 	if message.UserID.String() == "00000000-0000-0000-0000-000000000000" {
 		return nil, errors.NewValidationError("userID is required", nil)
 	}
-	if message.Subject == "" {
-		return nil, errors.NewValidationError("subject is required", nil)
+	if err := validateChannels(&message); err != nil {
+		return nil, err
 	}
-	if message.Body == "" {
-		return nil, errors.NewValidationError("body is required", nil)
+	if err := handler.validateAttachments(&message); err != nil {
+		return nil, err
+	}
+	if message.TemplateID == "" {
+		if message.Subject == "" {
+			return nil, errors.NewValidationError("subject is required", nil)
+		}
+		if message.Body == "" {
+			return nil, errors.NewValidationError("body is required", nil)
+		}
 	}
 	
 	notification := message.ToNotification()
+	if err := handler.applyFallbackPolicy(notification); err != nil {
+		return nil, err
+	}
+	handler.schedule(notification)
+	if err := handler.applyExpiry(&message, notification); err != nil {
+		return nil, err
+	}
+	if err := handler.renderTemplate(notification); err != nil {
+		return nil, err
+	}
 	return notification, nil
 }
 
-func (handler *Handler) GetUnreadNotifications(userId string) ([]models.Notification, error) {
-	notifications, err := handler.repo.GetUnreadNotifications(userId)
+func validateChannels(message *models.NotificationMessage) error {
+	if len(message.Channels) == 0 {
+		return nil
+	}
+	if message.Type != "" {
+		return errors.NewValidationError("type and channels are mutually exclusive", nil)
+	}
+
+	seen := make(map[models.NotificationType]bool, len(message.Channels))
+	for _, override := range message.Channels {
+		if override.Type == "" {
+			return errors.NewValidationError("channel type is required", nil)
+		}
+		if seen[override.Type] {
+			return errors.NewValidationError(fmt.Sprintf("channel %s is listed twice", override.Type), nil)
+		}
+		seen[override.Type] = true
+	}
+	return nil
+}
+
+func (handler *Handler) renderTemplate(notification *models.Notification) error {
+	if notification.Template == nil {
+		return nil
+	}
+	if handler.renderer == nil {
+		return errors.NewProcessingError("templates are not configured", nil)
+	}
+
+	rendered, err := handler.renderer.Render(notification.Template.ID, notification.Template.Version, notification.Template.Data)
+	if err != nil {
+		return err
+	}
+
+	notification.Template.Version = rendered.Version
+	notification.Subject = rendered.Subject
+	notification.Body = rendered.TextBody
+	if notification.MailInfo != nil {
+		notification.MailInfo.HTMLBody = rendered.HTMLBody
+	} else if notification.Body == "" {
+		notification.Body = rendered.HTMLBody
+	}
+	return nil
+}
+
+func (handler *Handler) GetUnreadNotifications(userId uuid.UUID) ([]models.Notification, error) {
+	notifications, err := handler.repo.GetUnreadNotifications(userId, handler.clock.Now())
 	if err != nil {
 		return nil, errors.NewProcessingError("failed to get unread notifications", err)
 	}
 	return notifications, nil
+}
+
+func (handler *Handler) MarkNotificationsRead(userId uuid.UUID, notificationIds []primitive.ObjectID) (int64, error) {
+	if len(notificationIds) == 0 {
+		return 0, errors.NewValidationError("at least one notification id is required", nil)
+	}
+	if err := handler.repo.MarkNotificationsReceived(userId, notificationIds, handler.clock.Now()); err != nil {
+		return 0, errors.NewProcessingError("failed to mark notifications as read", err)
+	}
+	return handler.countUnread(userId)
+}
+
+func (handler *Handler) MarkAllNotificationsRead(userId uuid.UUID) (int64, error) {
+	if err := handler.repo.MarkAllNotificationsReceived(userId, handler.clock.Now()); err != nil {
+		return 0, errors.NewProcessingError("failed to mark notifications as read", err)
+	}
+	return handler.countUnread(userId)
+}
+
+func (handler *Handler) countUnread(userId uuid.UUID) (int64, error) {
+	count, err := handler.repo.CountUnreadNotifications(userId, handler.clock.Now())
+	if err != nil {
+		return 0, errors.NewProcessingError("failed to count unread notifications", err)
+	}
+	return count, nil
+}
+
+func (handler *Handler) GetNotifications(filter repository.NotificationFilter) ([]models.Notification, error) {
+	// History keeps notifications of channels that are no longer registered
+	if filter.Type != "" && !filter.Type.IsValid() {
+		return nil, errors.NewValidationError(fmt.Sprintf("unknown type: %s", filter.Type), nil)
+	}
+	notifications, err := handler.repo.FindNotifications(filter)
+	if err != nil {
+		return nil, errors.NewProcessingError("failed to get notifications", err)
+	}
+	return notifications, nil
+}
+
+func (handler *Handler) GetChannelNotifications(notificationId primitive.ObjectID) ([]models.Notification, error) {
+	if _, err := handler.GetNotification(notificationId); err != nil {
+		return nil, err
+	}
+	children, err := handler.repo.GetChildNotifications(notificationId)
+	if err != nil {
+		return nil, errors.NewProcessingError("failed to get channel notifications", err)
+	}
+	return children, nil
+}
+
+func (handler *Handler) GetNotification(notificationId primitive.ObjectID) (*models.Notification, error) {
+	notification, err := handler.repo.GetNotificationByID(notificationId)
+	if err != nil {
+		return nil, errors.NewProcessingError("failed to get notification", err)
+	}
+	if notification == nil {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("notification %s not found", notificationId.Hex()), nil)
+	}
+	return notification, nil
 }
\ No newline at end of file
@@ -0,0 +1,554 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"notificationservice/internal/clock"
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+	"notificationservice/internal/repository"
+	"notificationservice/internal/templates"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// stubChannel returns errs in order, then succeeds, and records every
+// notification it was asked to deliver.
+type stubChannel struct {
+	errs      []error
+	delivered []*models.Notification
+}
+
+func (channel *stubChannel) Deliver(notification *models.Notification) error {
+	delivered := *notification
+	channel.delivered = append(channel.delivered, &delivered)
+	if len(channel.errs) == 0 {
+		return nil
+	}
+	err := channel.errs[0]
+	channel.errs = channel.errs[1:]
+	return err
+}
+
+type testHandler struct {
+	*Handler
+	repo        *repository.MemoryRepository
+	preferences *repository.MemoryPreferenceRepository
+	channels    map[models.NotificationType]*stubChannel
+	now         time.Time
+}
+
+func newTestHandler(t *testing.T) *testHandler {
+	t.Helper()
+	test := &testHandler{
+		repo:        repository.NewMemoryRepository(),
+		preferences: repository.NewMemoryPreferenceRepository(),
+		channels:    make(map[models.NotificationType]*stubChannel),
+		now:         time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+	registry := NewChannelRegistry()
+	for _, notificationType := range []models.NotificationType{models.EmailNotification, models.InAppNotification, models.SMSNotification} {
+		channel := &stubChannel{}
+		test.channels[notificationType] = channel
+		if err := registry.Register(Channel{Type: notificationType, Handler: channel}); err != nil {
+			t.Fatalf("register %s: %v", notificationType, err)
+		}
+	}
+	test.Handler = NewHandler(test.repo, nil, nil, test.preferences, registry, nil)
+	test.SetClock(clock.Func(func() time.Time { return test.now }))
+	return test
+}
+
+func newMessage(userId uuid.UUID, notificationType models.NotificationType) models.NotificationMessage {
+	return models.NotificationMessage{
+		UserID:     userId,
+		ExternalID: uuid.New(),
+		Subject:    "Subject",
+		Body:       "Body",
+		Type:       notificationType,
+		MailInfo:   &models.MailDetails{To: models.AddressList{{Address: "jane@example.com"}}},
+	}
+}
+
+func (test *testHandler) process(t *testing.T, message models.NotificationMessage) error {
+	t.Helper()
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("marshal message: %v", err)
+	}
+	return test.ProcessMessage(data)
+}
+
+func (test *testHandler) notifications(t *testing.T, userId uuid.UUID) []models.Notification {
+	t.Helper()
+	notifications, err := test.repo.FindNotifications(repository.NotificationFilter{UserID: userId})
+	if err != nil {
+		t.Fatalf("find notifications: %v", err)
+	}
+	return notifications
+}
+
+func (test *testHandler) only(t *testing.T, userId uuid.UUID) models.Notification {
+	t.Helper()
+	notifications := test.notifications(t, userId)
+	if len(notifications) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(notifications))
+	}
+	return notifications[0]
+}
+
+func TestProcessMessageDeliversAndMarksSent(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+
+	if err := test.process(t, newMessage(userId, models.EmailNotification)); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	if got := len(test.channels[models.EmailNotification].delivered); got != 1 {
+		t.Fatalf("delivered %d times, want 1", got)
+	}
+	if status := test.only(t, userId).DeliveryStatus.NotificationStatus; status != models.Sent {
+		t.Errorf("status = %s, want Sent", status)
+	}
+}
+
+func TestProcessMessageRejectsInvalidMessages(t *testing.T) {
+	test := newTestHandler(t)
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"invalid JSON", `{`},
+		{"missing user", `{"externalId": "` + uuid.NewString() + `", "subject": "s", "body": "b", "type": "Mail"}`},
+		{"missing subject", `{"userId": "` + uuid.NewString() + `", "body": "b", "type": "Mail"}`},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := test.ProcessMessage([]byte(testCase.data))
+			if !errors.IsValidationError(err) {
+				t.Errorf("error = %v, want a validation error", err)
+			}
+		})
+	}
+}
+
+func TestProcessMessageRejectsTemplatesMissingData(t *testing.T) {
+	test := newTestHandler(t)
+	test.renderer = templates.NewRenderer(templateStore{"welcome": {
+		ID:       "welcome",
+		Version:  1,
+		Subject:  "Welcome, {{.name}}",
+		TextBody: "Hello {{.name}}",
+	}})
+	userId := uuid.New()
+
+	message := newMessage(userId, models.EmailNotification)
+	message.Subject, message.Body = "", ""
+	message.TemplateID = "welcome"
+	message.TemplateData = map[string]interface{}{"greeting": "Hi"}
+	err := test.process(t, message)
+
+	// Rejected rather than retried, so the consumer dead-letters it
+	if !errors.IsValidationError(err) {
+		t.Errorf("error = %v, want a validation error", err)
+	}
+	if got := len(test.notifications(t, userId)); got != 0 {
+		t.Errorf("stored %d notifications, want none", got)
+	}
+
+	message.TemplateData["name"] = "Jane"
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+	if sent := test.channels[models.EmailNotification].delivered; len(sent) != 1 || sent[0].Subject != "Welcome, Jane" {
+		t.Errorf("delivered %+v, want the rendered welcome", sent)
+	}
+}
+
+func TestProcessMessageDeduplicatesByExternalID(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	mail := test.channels[models.EmailNotification]
+	mail.errs = []error{errors.NewRetriableError("server busy", nil)}
+
+	message := newMessage(userId, models.EmailNotification)
+	if err := test.process(t, message); !errors.IsRetriableError(err) {
+		t.Fatalf("first attempt: error = %v, want retriable", err)
+	}
+	// The retry carries the same externalId and must reuse the stored record
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+
+	if got := len(mail.delivered); got != 2 {
+		t.Errorf("delivered %d times, want 2", got)
+	}
+	notification := test.only(t, userId)
+	if notification.DeliveryStatus.NotificationStatus != models.Sent {
+		t.Errorf("status = %s, want Sent", notification.DeliveryStatus.NotificationStatus)
+	}
+
+	// A different message is a new notification
+	if err := test.process(t, newMessage(userId, models.EmailNotification)); err != nil {
+		t.Fatalf("second message: %v", err)
+	}
+	if got := len(test.notifications(t, userId)); got != 2 {
+		t.Errorf("got %d notifications, want 2", got)
+	}
+}
+
+func TestProcessMessageDoesNotRedeliverSuppressedReplays(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	if _, err := test.SetPreference(&models.Preference{UserID: userId, Category: models.DefaultCategory, OptOut: true}); err != nil {
+		t.Fatalf("SetPreference: %v", err)
+	}
+
+	message := newMessage(userId, models.EmailNotification)
+	for i := 0; i < 2; i++ {
+		if err := test.process(t, message); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+
+	if status := test.only(t, userId).DeliveryStatus.NotificationStatus; status != models.Suppressed {
+		t.Errorf("status = %s, want Suppressed", status)
+	}
+	if got := len(test.channels[models.EmailNotification].delivered); got != 0 {
+		t.Errorf("delivered %d times, want 0", got)
+	}
+}
+
+func TestProcessMessageLeavesDeferredReplaysToReleaseDue(t *testing.T) {
+	test := newTestHandler(t)
+	test.now = time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)
+	test.SetQuietHours(map[string]*models.QuietHours{
+		"marketing": {Start: "22:00", End: "07:00"},
+	})
+	userId := uuid.New()
+
+	message := newMessage(userId, models.EmailNotification)
+	message.Category = "marketing"
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	// The replay arrives after the quiet hours, before ReleaseDue has run
+	test.now = time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	mail := test.channels[models.EmailNotification]
+	if len(mail.delivered) != 0 {
+		t.Fatalf("replay delivered %d times, want 0", len(mail.delivered))
+	}
+	if status := test.only(t, userId).DeliveryStatus.NotificationStatus; status != models.Deferred {
+		t.Fatalf("status = %s, want Deferred", status)
+	}
+
+	if err := test.ReleaseDue(); err != nil {
+		t.Fatalf("ReleaseDue: %v", err)
+	}
+	if len(mail.delivered) != 1 {
+		t.Errorf("delivered %d times, want 1", len(mail.delivered))
+	}
+}
+
+func TestProcessMessageDeliveryStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		deliveryErr   error
+		wantStatus    models.NotificationStatus
+		wantRetriable bool
+		wantErr       bool
+	}{
+		{"delivered", nil, models.Sent, false, false},
+		{"retriable failure", errors.NewRetriableError("timeout", nil), models.Pending, true, true},
+		{"rejected recipient", errors.NewValidationError("no such mailbox", nil), models.Failed, false, true},
+		{"provider misconfigured", errors.NewProcessingError("bad credentials", nil), models.Failed, false, true},
+		{"recipient offline", errors.NewUnavailableError("not connected", nil), models.Pending, false, false},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			test := newTestHandler(t)
+			userId := uuid.New()
+			if testCase.deliveryErr != nil {
+				test.channels[models.EmailNotification].errs = []error{testCase.deliveryErr}
+			}
+
+			err := test.process(t, newMessage(userId, models.EmailNotification))
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("error = %v, want error %v", err, testCase.wantErr)
+			}
+			if errors.IsRetriableError(err) != testCase.wantRetriable {
+				t.Errorf("retriable = %v, want %v", errors.IsRetriableError(err), testCase.wantRetriable)
+			}
+
+			notification := test.only(t, userId)
+			if notification.DeliveryStatus.NotificationStatus != testCase.wantStatus {
+				t.Errorf("status = %s, want %s", notification.DeliveryStatus.NotificationStatus, testCase.wantStatus)
+			}
+			if testCase.deliveryErr != nil && notification.DeliveryStatus.Error == "" {
+				t.Error("delivery error was not recorded")
+			}
+		})
+	}
+}
+
+// parent returns the multi-channel notification the user's channel
+// notifications belong to. History only lists the channel notifications.
+func (test *testHandler) parent(t *testing.T, userId uuid.UUID) *models.Notification {
+	t.Helper()
+	for _, notification := range test.notifications(t, userId) {
+		if notification.ParentID == nil {
+			continue
+		}
+		parent, err := test.GetNotification(*notification.ParentID)
+		if err != nil {
+			t.Fatalf("GetNotification: %v", err)
+		}
+		return parent
+	}
+	t.Fatal("no channel notification was stored")
+	return nil
+}
+
+func TestProcessMessageFansOutToChannels(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+
+	message := newMessage(userId, "")
+	message.Channels = []models.ChannelOverride{
+		{Type: models.EmailNotification, Subject: "Your weekly summary"},
+		{Type: models.InAppNotification, Body: "Tap to see your summary"},
+		{Type: models.SMSNotification, Body: "Summary ready", SMSInfo: &models.SMSDetails{PhoneNumber: "+15551234567"}},
+	}
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	want := map[models.NotificationType]struct{ subject, body string }{
+		models.EmailNotification: {"Your weekly summary", "Body"},
+		models.InAppNotification: {"Subject", "Tap to see your summary"},
+		models.SMSNotification:   {"Subject", "Summary ready"},
+	}
+	for notificationType, content := range want {
+		delivered := test.channels[notificationType].delivered
+		if len(delivered) != 1 {
+			t.Errorf("%s delivered %d times, want 1", notificationType, len(delivered))
+			continue
+		}
+		if delivered[0].Subject != content.subject || delivered[0].Body != content.body {
+			t.Errorf("%s delivered %q / %q, want %q / %q", notificationType, delivered[0].Subject, delivered[0].Body, content.subject, content.body)
+		}
+	}
+	if got := test.channels[models.SMSNotification].delivered[0].SMSInfo.PhoneNumber; got != "+15551234567" {
+		t.Errorf("SMS sent to %s, want the override's number", got)
+	}
+
+	parent := test.parent(t, userId)
+	if parent.DeliveryStatus.NotificationStatus != models.Sent {
+		t.Errorf("parent = %s, want Sent", parent.DeliveryStatus.NotificationStatus)
+	}
+	children := 0
+	for _, notification := range test.notifications(t, userId) {
+		if notification.ParentID != nil && *notification.ParentID == parent.ID {
+			children++
+		}
+	}
+	if children != 3 {
+		t.Errorf("stored %d channel notifications, want 3", children)
+	}
+}
+
+func TestProcessMessageAggregatesChannelResults(t *testing.T) {
+	tests := []struct {
+		name          string
+		mailErr       error
+		smsErr        error
+		wantStatus    models.NotificationStatus
+		wantRetriable bool
+		wantErr       bool
+	}{
+		{"all sent", nil, nil, models.Sent, false, false},
+		{"one rejected", nil, errors.NewValidationError("invalid phone number", nil), models.PartiallySent, false, false},
+		{"all rejected", errors.NewValidationError("no such mailbox", nil), errors.NewValidationError("invalid phone number", nil), models.Failed, false, true},
+		{"one to retry", nil, errors.NewRetriableError("gateway timeout", nil), models.Pending, true, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			test := newTestHandler(t)
+			userId := uuid.New()
+			if testCase.mailErr != nil {
+				test.channels[models.EmailNotification].errs = []error{testCase.mailErr}
+			}
+			if testCase.smsErr != nil {
+				test.channels[models.SMSNotification].errs = []error{testCase.smsErr}
+			}
+
+			message := newMessage(userId, "")
+			message.Channels = []models.ChannelOverride{
+				{Type: models.EmailNotification},
+				{Type: models.SMSNotification, SMSInfo: &models.SMSDetails{PhoneNumber: "+15551234567"}},
+			}
+			err := test.process(t, message)
+			if (err != nil) != testCase.wantErr || errors.IsRetriableError(err) != testCase.wantRetriable {
+				t.Fatalf("error = %v, want error %t, retriable %t", err, testCase.wantErr, testCase.wantRetriable)
+			}
+			if status := test.parent(t, userId).DeliveryStatus.NotificationStatus; status != testCase.wantStatus {
+				t.Fatalf("parent = %s, want %s", status, testCase.wantStatus)
+			}
+			if !testCase.wantRetriable {
+				return
+			}
+
+			// The retry only delivers the channel that did not go out
+			if err := test.process(t, message); err != nil {
+				t.Fatalf("retry: %v", err)
+			}
+			if status := test.parent(t, userId).DeliveryStatus.NotificationStatus; status != models.Sent {
+				t.Errorf("parent after the retry = %s, want Sent", status)
+			}
+			if mail, sms := len(test.channels[models.EmailNotification].delivered), len(test.channels[models.SMSNotification].delivered); mail != 1 || sms != 2 {
+				t.Errorf("delivered %d emails and %d SMS, want 1 and 2", mail, sms)
+			}
+		})
+	}
+}
+
+func TestUnreadCounts(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	for _, notificationType := range []models.NotificationType{models.InAppNotification, models.InAppNotification, models.InAppNotification, models.EmailNotification} {
+		if err := test.process(t, newMessage(userId, notificationType)); err != nil {
+			t.Fatalf("ProcessMessage: %v", err)
+		}
+	}
+	// Another user's notifications do not count
+	if err := test.process(t, newMessage(uuid.New(), models.InAppNotification)); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	unread, err := test.GetUnreadNotifications(userId)
+	if err != nil {
+		t.Fatalf("GetUnreadNotifications: %v", err)
+	}
+	if len(unread) != 3 {
+		t.Fatalf("got %d unread, want the 3 in-app notifications", len(unread))
+	}
+
+	count, err := test.MarkNotificationsRead(userId, []primitive.ObjectID{unread[0].ID})
+	if err != nil {
+		t.Fatalf("MarkNotificationsRead: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("unread count after marking one = %d, want 2", count)
+	}
+	// Marking again is a no-op
+	if count, err = test.MarkNotificationsRead(userId, []primitive.ObjectID{unread[0].ID}); err != nil || count != 2 {
+		t.Errorf("unread count after marking again = %d (%v), want 2", count, err)
+	}
+
+	// Expired notifications drop out of the count
+	expiresAt := test.now.Add(time.Minute)
+	message := newMessage(userId, models.InAppNotification)
+	message.ExpiresAt = &expiresAt
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+	if count, _ = test.countUnread(userId); count != 3 {
+		t.Errorf("unread count = %d, want 3", count)
+	}
+	test.now = expiresAt
+	if count, _ = test.countUnread(userId); count != 2 {
+		t.Errorf("unread count after expiry = %d, want 2", count)
+	}
+
+	if count, err = test.MarkAllNotificationsRead(userId); err != nil || count != 0 {
+		t.Errorf("unread count after marking all = %d (%v), want 0", count, err)
+	}
+}
+
+func TestGetNotificationsFiltersByKnownTypes(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	if err := test.process(t, newMessage(userId, models.EmailNotification)); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	notifications, err := test.GetNotifications(repository.NotificationFilter{UserID: userId, Type: models.EmailNotification})
+	if err != nil || len(notifications) != 1 {
+		t.Errorf("Mail: got %d notifications, %v, want 1", len(notifications), err)
+	}
+	// No push channel is registered here, but push history can still be listed
+	notifications, err = test.GetNotifications(repository.NotificationFilter{UserID: userId, Type: models.PushNotification})
+	if err != nil || len(notifications) != 0 {
+		t.Errorf("Push: got %d notifications, %v, want none", len(notifications), err)
+	}
+	if _, err := test.GetNotifications(repository.NotificationFilter{UserID: userId, Type: "Fax"}); !errors.IsValidationError(err) {
+		t.Errorf("Fax: error = %v, want a validation error", err)
+	}
+}
+
+func TestReplayedPayloadReplacesFailedNotification(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	mail := test.channels[models.EmailNotification]
+	mail.errs = []error{errors.NewValidationError("invalid recipient", nil)}
+
+	message := newMessage(userId, models.EmailNotification)
+	message.MailInfo = &models.MailDetails{To: models.AddressList{{Address: "jane@example"}}}
+	if err := test.process(t, message); !errors.IsValidationError(err) {
+		t.Fatalf("first delivery: error = %v, want a validation error", err)
+	}
+
+	// The payload is corrected before it is replayed from the dead letter queue
+	message.MailInfo = &models.MailDetails{To: models.AddressList{{Address: "jane@example.com"}}}
+	message.Subject = "Corrected"
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+
+	notification := test.only(t, userId)
+	if notification.DeliveryStatus.NotificationStatus != models.Sent || notification.Subject != "Corrected" {
+		t.Errorf("notification = %s %q, want the corrected one Sent", notification.DeliveryStatus.NotificationStatus, notification.Subject)
+	}
+	if got := mail.delivered[1].MailInfo.To[0].Address; got != "jane@example.com" {
+		t.Errorf("delivered to %s, want the corrected address", got)
+	}
+}
+
+func TestReplayedPayloadRebuildsFailedChannels(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	test.channels[models.SMSNotification].errs = []error{errors.NewValidationError("invalid phone number", nil)}
+	test.channels[models.InAppNotification].errs = []error{errors.NewValidationError("user deactivated", nil)}
+
+	message := newMessage(userId, "")
+	message.Channels = []models.ChannelOverride{
+		{Type: models.InAppNotification},
+		{Type: models.SMSNotification, SMSInfo: &models.SMSDetails{PhoneNumber: "12"}},
+	}
+	if err := test.process(t, message); !errors.IsValidationError(err) {
+		t.Fatalf("first delivery: error = %v, want a validation error", err)
+	}
+
+	message.Channels[1].SMSInfo = &models.SMSDetails{PhoneNumber: "+15551234567"}
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+
+	sms := test.channels[models.SMSNotification].delivered
+	if len(sms) != 2 || sms[1].SMSInfo.PhoneNumber != "+15551234567" {
+		t.Errorf("SMS deliveries = %d, want the corrected number on the second", len(sms))
+	}
+	for _, notification := range test.notifications(t, userId) {
+		if notification.DeliveryStatus.NotificationStatus != models.Sent {
+			t.Errorf("%s channel = %s, want Sent", notification.Type, notification.DeliveryStatus.NotificationStatus)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SetLockedCategories sets the categories users cannot change their
+// preferences for; their notifications go out on every channel.
+func (handler *Handler) SetLockedCategories(categories []string) {
+	handler.lockedCategories = make(map[string]bool, len(categories))
+	for _, category := range categories {
+		handler.lockedCategories[category] = true
+	}
+}
+
+func (handler *Handler) GetPreferences(userId uuid.UUID) ([]models.Preference, error) {
+	stored, err := handler.preferences.GetPreferences(userId)
+	if err != nil {
+		return nil, errors.NewProcessingError("failed to get preferences", err)
+	}
+
+	preferences := make([]models.Preference, 0, len(stored)+len(handler.lockedCategories))
+	for _, preference := range stored {
+		if !handler.lockedCategories[preference.Category] {
+			preferences = append(preferences, preference)
+		}
+	}
+	for category := range handler.lockedCategories {
+		preferences = append(preferences, lockedPreference(userId, category))
+	}
+	sort.Slice(preferences, func(i, j int) bool {
+		return preferences[i].Category < preferences[j].Category
+	})
+	return preferences, nil
+}
+
+func (handler *Handler) GetPreference(userId uuid.UUID, category string) (*models.Preference, error) {
+	if handler.lockedCategories[category] {
+		preference := lockedPreference(userId, category)
+		return &preference, nil
+	}
+	preference, err := handler.preferences.GetPreference(userId, category)
+	if err != nil {
+		return nil, errors.NewProcessingError("failed to get preference", err)
+	}
+	if preference == nil {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("no preference for category %s", category), nil)
+	}
+	return preference, nil
+}
+
+// SetPreference stores the preference for its user and category, replacing
+// any earlier one.
+func (handler *Handler) SetPreference(preference *models.Preference) (*models.Preference, error) {
+	if err := handler.checkMutable(preference.Category); err != nil {
+		return nil, err
+	}
+	for channel := range preference.Channels {
+		if _, ok := handler.channels.Lookup(channel); !ok {
+			return nil, errors.NewValidationError(fmt.Sprintf("unknown channel: %s", channel), nil)
+		}
+	}
+	if preference.QuietHours != nil {
+		if err := preference.QuietHours.Validate(); err != nil {
+			return nil, errors.NewValidationError("invalid quiet hours", err)
+		}
+	}
+	if preference.Digest != "" && !preference.Digest.IsValid() {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid digest frequency: %s", preference.Digest), nil)
+	}
+
+	if err := handler.preferences.SavePreference(preference); err != nil {
+		return nil, errors.NewProcessingError("failed to save preference", err)
+	}
+	return preference, nil
+}
+
+func (handler *Handler) DeletePreference(userId uuid.UUID, category string) error {
+	if err := handler.checkMutable(category); err != nil {
+		return err
+	}
+	deleted, err := handler.preferences.DeletePreference(userId, category)
+	if err != nil {
+		return errors.NewProcessingError("failed to delete preference", err)
+	}
+	if !deleted {
+		return errors.NewNotFoundError(fmt.Sprintf("no preference for category %s", category), nil)
+	}
+	return nil
+}
+
+func (handler *Handler) checkMutable(category string) error {
+	if category == "" {
+		return errors.NewValidationError("category is required", nil)
+	}
+	if handler.lockedCategories[category] {
+		return errors.NewValidationError(fmt.Sprintf("preferences for category %s cannot be changed", category), nil)
+	}
+	return nil
+}
+
+func lockedPreference(userId uuid.UUID, category string) models.Preference {
+	return models.Preference{UserID: userId, Category: category, Locked: true}
+}
+
+// userPreferences loads the user's preference for the notification's category
+// and their DefaultCategory preference. Locked categories ignore both.
+func (handler *Handler) userPreferences(notification *models.Notification) (specific, general *models.Preference, err error) {
+	category := notification.Category
+	if handler.preferences == nil || handler.lockedCategories[category] {
+		return nil, nil, nil
+	}
+
+	if category != "" && category != models.DefaultCategory {
+		if specific, err = handler.preferences.GetPreference(notification.UserID, category); err != nil {
+			return nil, nil, errors.NewRetriableError("failed to load preferences", err)
+		}
+	}
+	if general, err = handler.preferences.GetPreference(notification.UserID, models.DefaultCategory); err != nil {
+		return nil, nil, errors.NewRetriableError("failed to load preferences", err)
+	}
+	return specific, general, nil
+}
+
+// suppressionReason tells why the user does not want the notification on its
+// channel, or returns "" when it should be delivered. Notifications without
+// a category, or whose category the user has no preference for, follow the
+// user's DefaultCategory preference.
+func suppressionReason(notification *models.Notification, specific, general *models.Preference) string {
+	preference := specific
+	if preference == nil {
+		preference = general
+	}
+	if preference == nil || preference.Allows(notification.Type) {
+		return ""
+	}
+
+	if preference.OptOut {
+		return fmt.Sprintf("user opted out of %s notifications", preference.Category)
+	}
+	return fmt.Sprintf("user turned off %s for %s notifications", notification.Type, preference.Category)
+}
+
+func (handler *Handler) suppress(notification *models.Notification, reason string) error {
+	notification.DeliveryStatus = models.DeliveryStatus{
+		NotificationStatus: models.Suppressed,
+		Reason:             reason,
+	}
+	if err := handler.repo.UpdateNotificationStatus(notification.ID, notification.DeliveryStatus); err != nil {
+		return errors.NewRetriableError("failed to update notification status", err)
+	}
+	log.Printf("Notification suppressed: ID=%v, User=%s: %s", notification.ID, notification.UserID, reason)
+	return nil
+}
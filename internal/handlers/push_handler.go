@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+	"notificationservice/internal/push"
+	"notificationservice/internal/repository"
+)
+
+type PushHandler struct {
+	devices   repository.DeviceRepository
+	providers map[models.Platform]push.Provider
+}
+
+func NewPushHandler(devices repository.DeviceRepository, providers map[models.Platform]push.Provider) IHandler {
+	return &PushHandler{devices: devices, providers: providers}
+}
+
+func PushChannel(devices repository.DeviceRepository, providers map[models.Platform]push.Provider) Channel {
+	return Channel{
+		Type:    models.PushNotification,
+		Handler: NewPushHandler(devices, providers),
+	}
+}
+
+// Deliver sends to every registered device of the user and succeeds if at
+// least one device accepted the notification. Tokens the provider rejects as
+// invalid are removed.
+func (h *PushHandler) Deliver(notification *models.Notification) error {
+	devices, err := h.devices.GetDevices(notification.UserID)
+	if err != nil {
+		return errors.NewRetriableError("failed to load devices", err)
+	}
+	if len(devices) == 0 {
+		return errors.NewUnavailableError("user has no registered devices", nil)
+	}
+
+	message := buildPushMessage(notification)
+	var delivered int
+	var invalidTokens []string
+	var lastErr error
+	for _, device := range devices {
+		err := h.send(device, message)
+		switch {
+		case err == nil:
+			delivered++
+		case push.IsInvalidToken(err):
+			invalidTokens = append(invalidTokens, device.Token)
+		case lastErr == nil || !errors.IsRetriableError(lastErr):
+			// A retriable failure wins so the notification is tried again
+			lastErr = err
+		}
+	}
+
+	if len(invalidTokens) > 0 {
+		if err := h.devices.DeleteDevicesByToken(invalidTokens); err != nil {
+			log.Printf("Failed to prune %d invalid device tokens: %v", len(invalidTokens), err)
+		} else {
+			log.Printf("Pruned %d invalid device tokens for user %s", len(invalidTokens), notification.UserID)
+		}
+	}
+
+	if delivered > 0 {
+		return nil
+	}
+	if lastErr == nil {
+		return errors.NewUnavailableError("user has no valid devices", nil)
+	}
+	return lastErr
+}
+
+func (h *PushHandler) send(device models.Device, message *push.Message) error {
+	provider, ok := h.providers[device.Platform]
+	if !ok {
+		return errors.NewProcessingError(fmt.Sprintf("no push provider configured for %s", device.Platform), nil)
+	}
+	return provider.Send(device.Token, message)
+}
+
+func buildPushMessage(notification *models.Notification) *push.Message {
+	message := &push.Message{Title: notification.Subject, Body: notification.Body}
+	if notification.PushInfo != nil {
+		message.Data = notification.PushInfo.Data
+		message.Badge = notification.PushInfo.Badge
+		message.Sound = notification.PushInfo.Sound
+	}
+	return message
+}
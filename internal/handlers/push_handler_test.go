@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"sort"
+	"testing"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+	"notificationservice/internal/push"
+	"notificationservice/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// stubPushProvider fails the tokens listed in errs and records the rest.
+type stubPushProvider struct {
+	errs map[string]error
+	sent []string
+}
+
+func (provider *stubPushProvider) Send(token string, message *push.Message) error {
+	if err, failed := provider.errs[token]; failed {
+		return err
+	}
+	provider.sent = append(provider.sent, token)
+	return nil
+}
+
+func TestPushHandlerPrunesInvalidTokens(t *testing.T) {
+	invalid := errors.NewValidationError("FCM returned 404 Not Found (UNREGISTERED)", push.ErrInvalidToken)
+	tests := []struct {
+		name        string
+		errs        map[string]error
+		wantType    errors.ErrorType
+		wantDevices []string
+	}{
+		{
+			name:        "delivered to the remaining devices",
+			errs:        map[string]error{"android-old": invalid},
+			wantDevices: []string{"android-new", "ios"},
+		},
+		{
+			name:        "no valid device left",
+			errs:        map[string]error{"android-old": invalid, "android-new": invalid, "ios": invalid},
+			wantType:    errors.UnavailableError,
+			wantDevices: []string{},
+		},
+		{
+			name: "retriable failure is retried",
+			errs: map[string]error{
+				"android-old": invalid,
+				"android-new": errors.NewValidationError("message too big", nil),
+				"ios":         errors.NewRetriableError("APNs returned 503", nil),
+			},
+			wantType:    errors.RetriableError,
+			wantDevices: []string{"android-new", "ios"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			devices := repository.NewMemoryDeviceRepository()
+			userId := uuid.New()
+			for _, device := range []models.Device{
+				{UserID: userId, Token: "android-old", Platform: models.AndroidPlatform},
+				{UserID: userId, Token: "android-new", Platform: models.AndroidPlatform},
+				{UserID: userId, Token: "ios", Platform: models.IOSPlatform},
+			} {
+				if err := devices.SaveDevice(&device); err != nil {
+					t.Fatalf("SaveDevice: %v", err)
+				}
+			}
+			provider := &stubPushProvider{errs: test.errs}
+			handler := NewPushHandler(devices, map[models.Platform]push.Provider{
+				models.AndroidPlatform: provider,
+				models.IOSPlatform:     provider,
+			})
+
+			err := handler.Deliver(&models.Notification{UserID: userId, Subject: "Title", Body: "Body"})
+			if got := errors.GetErrorType(err); got != test.wantType {
+				t.Errorf("error type = %q, want %q (%v)", got, test.wantType, err)
+			}
+
+			remaining, _ := devices.GetDevices(userId)
+			tokens := []string{}
+			for _, device := range remaining {
+				tokens = append(tokens, device.Token)
+			}
+			sort.Strings(tokens)
+			if len(tokens) != len(test.wantDevices) {
+				t.Fatalf("devices = %v, want %v", tokens, test.wantDevices)
+			}
+			for i := range tokens {
+				if tokens[i] != test.wantDevices[i] {
+					t.Errorf("devices = %v, want %v", tokens, test.wantDevices)
+				}
+			}
+		})
+	}
+}
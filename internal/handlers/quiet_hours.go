@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"notificationservice/internal/clock"
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+)
+
+// SetQuietHours sets the quiet hours applied by category to users who have
+// none of their own.
+func (handler *Handler) SetQuietHours(defaults map[string]*models.QuietHours) {
+	handler.quietHours = defaults
+}
+
+// SetClock replaces the wall clock quiet hours and schedules are evaluated
+// against.
+func (handler *Handler) SetClock(clock clock.Clock) {
+	handler.clock = clock
+}
+
+// deliveryCheck is what the user's preferences say about delivering a
+// notification on its channel now: suppressed tells why it must not be sent,
+// digest which digest it goes into, deferUntil when the quiet hours it falls
+// in end.
+type deliveryCheck struct {
+	suppressed string
+	digest     models.DigestFrequency
+	deferUntil *time.Time
+}
+
+func (handler *Handler) checkPreferences(notification *models.Notification) (deliveryCheck, error) {
+	specific, general, err := handler.userPreferences(notification)
+	if err != nil {
+		return deliveryCheck{}, err
+	}
+	if reason := suppressionReason(notification, specific, general); reason != "" {
+		return deliveryCheck{suppressed: reason}, nil
+	}
+	if notification.Urgent {
+		return deliveryCheck{}, nil
+	}
+	if frequency := handler.digestFrequency(notification, specific, general); frequency.Batches() {
+		// The digest itself observes quiet hours when it is sent
+		return deliveryCheck{digest: frequency}, nil
+	}
+
+	quietHours := handler.quietHoursFor(notification.Category, specific, general)
+	if quietHours == nil || !quietHours.Covers(notification.Type) {
+		return deliveryCheck{}, nil
+	}
+	end, inside, err := quietHours.WindowEnd(handler.clock.Now())
+	if err != nil {
+		log.Printf("Ignoring invalid quiet hours for user %s: %v", notification.UserID, err)
+		return deliveryCheck{}, nil
+	}
+	if !inside {
+		return deliveryCheck{}, nil
+	}
+	return deliveryCheck{deferUntil: &end}, nil
+}
+
+// quietHoursFor prefers the user's quiet hours for the category, then their
+// general ones, then the category's default.
+func (handler *Handler) quietHoursFor(category string, specific, general *models.Preference) *models.QuietHours {
+	for _, preference := range []*models.Preference{specific, general} {
+		if preference != nil && preference.QuietHours != nil {
+			return preference.QuietHours
+		}
+	}
+	return handler.quietHours[category]
+}
+
+func (handler *Handler) deferDelivery(notification *models.Notification, until time.Time) error {
+	notification.DeliveryStatus = models.DeliveryStatus{
+		NotificationStatus: models.Deferred,
+		Reason:             fmt.Sprintf("quiet hours until %s", until.Format(time.RFC3339)),
+	}
+	notification.DeliverAt = &until
+	if err := handler.repo.DeferNotification(notification); err != nil {
+		return errors.NewRetriableError("failed to update notification status", err)
+	}
+	log.Printf("Notification deferred: ID=%v, User=%s, until %s", notification.ID, notification.UserID, until.Format(time.RFC3339))
+	return nil
+}
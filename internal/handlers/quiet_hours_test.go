@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestQuietHoursDeferUntilWindowEnd(t *testing.T) {
+	tests := []struct {
+		name        string
+		urgent      bool
+		channel     models.NotificationType
+		wantStatus  models.NotificationStatus
+		wantDeliver bool
+	}{
+		{"non-urgent is deferred", false, models.EmailNotification, models.Deferred, false},
+		{"urgent bypasses quiet hours", true, models.EmailNotification, models.Sent, true},
+		{"uncovered channel is delivered", false, models.SMSNotification, models.Sent, true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			test := newTestHandler(t)
+			test.now = time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)
+			userId := uuid.New()
+			quietHours := &models.QuietHours{Start: "22:00", End: "07:00", Channels: []models.NotificationType{models.EmailNotification}}
+			if _, err := test.SetPreference(&models.Preference{UserID: userId, Category: models.DefaultCategory, QuietHours: quietHours}); err != nil {
+				t.Fatalf("SetPreference: %v", err)
+			}
+
+			message := newMessage(userId, testCase.channel)
+			message.Urgent = testCase.urgent
+			if err := test.process(t, message); err != nil {
+				t.Fatalf("ProcessMessage: %v", err)
+			}
+
+			notification := test.only(t, userId)
+			if notification.DeliveryStatus.NotificationStatus != testCase.wantStatus {
+				t.Errorf("status = %s, want %s", notification.DeliveryStatus.NotificationStatus, testCase.wantStatus)
+			}
+			if delivered := len(test.channels[testCase.channel].delivered) == 1; delivered != testCase.wantDeliver {
+				t.Errorf("delivered = %v, want %v", delivered, testCase.wantDeliver)
+			}
+			if testCase.wantStatus == models.Deferred {
+				wantDeliverAt := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+				if notification.DeliverAt == nil || !notification.DeliverAt.Equal(wantDeliverAt) {
+					t.Errorf("deliverAt = %v, want %s", notification.DeliverAt, wantDeliverAt)
+				}
+			}
+		})
+	}
+}
+
+func TestDeferredNotificationIsReleasedAfterQuietHours(t *testing.T) {
+	test := newTestHandler(t)
+	test.now = time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)
+	test.SetQuietHours(map[string]*models.QuietHours{
+		"marketing": {Start: "22:00", End: "07:00", Timezone: "Europe/Berlin"},
+	})
+	userId := uuid.New()
+
+	message := newMessage(userId, models.EmailNotification)
+	message.Category = "marketing"
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+	if status := test.only(t, userId).DeliveryStatus.NotificationStatus; status != models.Deferred {
+		t.Fatalf("status = %s, want Deferred", status)
+	}
+
+	// 07:00 in Berlin is 05:00 UTC until summer time ends on October 25
+	test.now = time.Date(2026, 10, 19, 4, 59, 0, 0, time.UTC)
+	if err := test.ReleaseDue(); err != nil {
+		t.Fatalf("ReleaseDue: %v", err)
+	}
+	mail := test.channels[models.EmailNotification]
+	if len(mail.delivered) != 0 {
+		t.Fatal("released before the quiet hours ended")
+	}
+
+	test.now = time.Date(2026, 10, 19, 5, 0, 0, 0, time.UTC)
+	if err := test.ReleaseDue(); err != nil {
+		t.Fatalf("ReleaseDue: %v", err)
+	}
+	if len(mail.delivered) != 1 {
+		t.Fatalf("delivered %d times after the quiet hours, want 1", len(mail.delivered))
+	}
+	notification := test.only(t, userId)
+	if notification.DeliveryStatus.NotificationStatus != models.Sent {
+		t.Errorf("status = %s, want Sent", notification.DeliveryStatus.NotificationStatus)
+	}
+
+	// Nothing is left to release
+	if err := test.ReleaseDue(); err != nil || len(mail.delivered) != 1 {
+		t.Errorf("second release delivered %d times (%v), want 1", len(mail.delivered), err)
+	}
+}
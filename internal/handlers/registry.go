@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+)
+
+// Capabilities describe what a channel can do with a notification, so callers
+// can pick a channel without knowing its implementation.
+type Capabilities struct {
+	HTML         bool
+	Attachments  bool
+	ReadReceipts bool
+	// RequiresPresence is set when delivery only succeeds while the recipient
+	// is connected, e.g. in-app notifications over WebSocket.
+	RequiresPresence bool
+}
+
+type ChannelConfig struct {
+	// Disabled channels stay registered so their notifications fail with a
+	// clear error instead of being reported as an unknown type.
+	Disabled bool
+}
+
+type Channel struct {
+	Type         models.NotificationType
+	Handler      IHandler
+	Capabilities Capabilities
+	Config       ChannelConfig
+}
+
+type ChannelRegistry struct {
+	mutex    sync.RWMutex
+	channels map[models.NotificationType]Channel
+}
+
+func NewChannelRegistry() *ChannelRegistry {
+	return &ChannelRegistry{channels: make(map[models.NotificationType]Channel)}
+}
+
+func (registry *ChannelRegistry) Register(channels ...Channel) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for _, channel := range channels {
+		if channel.Type == "" {
+			return fmt.Errorf("channel type is required")
+		}
+		if channel.Handler == nil {
+			return fmt.Errorf("channel %s has no handler", channel.Type)
+		}
+		if _, exists := registry.channels[channel.Type]; exists {
+			return fmt.Errorf("channel %s is already registered", channel.Type)
+		}
+		registry.channels[channel.Type] = channel
+	}
+	return nil
+}
+
+func (registry *ChannelRegistry) Lookup(notificationType models.NotificationType) (Channel, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	channel, ok := registry.channels[notificationType]
+	return channel, ok
+}
+
+func (registry *ChannelRegistry) Channels() []Channel {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	channels := make([]Channel, 0, len(registry.channels))
+	for _, channel := range registry.channels {
+		channels = append(channels, channel)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Type < channels[j].Type })
+	return channels
+}
+
+func (registry *ChannelRegistry) Deliver(notification *models.Notification) error {
+	channel, ok := registry.Lookup(notification.Type)
+	if !ok {
+		return errors.NewValidationError(
+			fmt.Sprintf("unknown notification type: %s", notification.Type),
+			nil,
+		)
+	}
+	if channel.Config.Disabled {
+		return errors.NewProcessingError(fmt.Sprintf("channel %s is disabled", channel.Type), nil)
+	}
+	return channel.Handler.Deliver(notification)
+}
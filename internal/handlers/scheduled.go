@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// releaseLease keeps other instances off a released notification while it
+	// is being delivered.
+	releaseLease      = 5 * time.Minute
+	releaseRetryDelay = time.Minute
+	maxReleaseRetries = 5
+)
+
+// schedule holds a new notification back when its sendAt is still ahead.
+func (handler *Handler) schedule(notification *models.Notification) {
+	if notification.DeliverAt == nil || !notification.DeliverAt.After(handler.clock.Now()) {
+		return
+	}
+	notification.DeliveryStatus = models.DeliveryStatus{NotificationStatus: models.Scheduled}
+}
+
+// CancelScheduled cancels a scheduled message by its externalId. Once a
+// notification is being dispatched it can no longer be cancelled.
+func (handler *Handler) CancelScheduled(externalId uuid.UUID) (int64, error) {
+	cancelled, err := handler.repo.CancelScheduled(externalId)
+	if err != nil {
+		return 0, errors.NewProcessingError("failed to cancel notification", err)
+	}
+	if cancelled == 0 {
+		return 0, errors.NewNotFoundError(fmt.Sprintf("no scheduled notification with externalId %s is awaiting dispatch", externalId), nil)
+	}
+	log.Printf("Scheduled notification cancelled: ExternalID=%s", externalId)
+	return cancelled, nil
+}
+
+// ReleaseDue delivers every scheduled notification whose sendAt has come,
+// every deferred one whose quiet hours have ended and every due digest.
+func (handler *Handler) ReleaseDue() error {
+	for {
+		notification, err := handler.repo.ClaimDueNotification(handler.clock.Now(), releaseLease)
+		if err != nil {
+			return err
+		}
+		if notification == nil {
+			return nil
+		}
+		if err := handler.release(notification); err != nil {
+			log.Printf("%s delivery failed: ID=%v: %v", notification.DeliveryStatus.NotificationStatus, notification.ID, err)
+		}
+	}
+}
+
+func (handler *Handler) release(notification *models.Notification) error {
+	held := notification.DeliveryStatus.NotificationStatus
+
+	var deliveryErr error
+	switch {
+	case notification.Digest != nil:
+		deliveryErr = handler.deliverDigest(notification)
+	case notification.IsMultiChannel():
+		deliveryErr = handler.deliverChannels(notification)
+	case notification.Fallback != nil:
+		return handler.deliverWithFallback(notification, true)
+	default:
+		deliveryErr = handler.deliverNotification(notification)
+	}
+
+	if errors.IsRetriableError(deliveryErr) {
+		notification.ReleaseAttempts++
+		if notification.ReleaseAttempts >= maxReleaseRetries {
+			if err := handler.giveUpRelease(notification, deliveryErr); err != nil {
+				return err
+			}
+		} else {
+			// No message is left to redeliver it, so it is held back again
+			// for a later release
+			retryAt := handler.clock.Now().Add(releaseRetryDelay)
+			notification.DeliveryStatus = models.DeliveryStatus{
+				NotificationStatus: held,
+				Error:              deliveryErr.Error(),
+			}
+			notification.DeliverAt = &retryAt
+			if err := handler.repo.DeferNotification(notification); err != nil {
+				log.Printf("Failed to update retry status: %v", err)
+			}
+		}
+	}
+	if notification.ParentID != nil {
+		if err := handler.refreshParentStatus(*notification.ParentID); err != nil {
+			return err
+		}
+	}
+	return deliveryErr
+}
+
+// giveUpRelease marks a released notification failed once it has failed
+// retriably maxReleaseRetries times; for a multi-channel message that
+// includes the channels still pending.
+func (handler *Handler) giveUpRelease(notification *models.Notification, deliveryErr error) error {
+	failed := models.DeliveryStatus{NotificationStatus: models.Failed, Error: deliveryErr.Error()}
+	if notification.IsMultiChannel() {
+		children, err := handler.repo.GetChildNotifications(notification.ID)
+		if err != nil {
+			return errors.NewRetriableError("database query failed", err)
+		}
+		for _, child := range children {
+			if child.DeliveryStatus.NotificationStatus != models.Pending {
+				continue
+			}
+			if err := handler.repo.UpdateNotificationStatus(child.ID, failed); err != nil {
+				return errors.NewRetriableError("failed to update notification status", err)
+			}
+		}
+		if err := handler.refreshParentStatus(notification.ID); err != nil {
+			return err
+		}
+	} else {
+		notification.DeliveryStatus = failed
+		if err := handler.repo.DeferNotification(notification); err != nil {
+			return errors.NewRetriableError("failed to update notification status", err)
+		}
+		if notification.Digest != nil {
+			if err := handler.failDigestItems(notification, deliveryErr); err != nil {
+				return err
+			}
+		}
+	}
+	log.Printf("Giving up on notification after %d release attempts: ID=%v, User=%s",
+		notification.ReleaseAttempts, notification.ID, notification.UserID)
+	return nil
+}
+
+// refreshParentStatus derives a multi-channel parent's status again after one
+// of its channels was delivered outside of deliverChannels.
+func (handler *Handler) refreshParentStatus(parentId primitive.ObjectID) error {
+	children, err := handler.repo.GetChildNotifications(parentId)
+	if err != nil {
+		return errors.NewRetriableError("database query failed", err)
+	}
+	pointers := make([]*models.Notification, len(children))
+	for i := range children {
+		pointers[i] = &children[i]
+	}
+
+	status := models.DeliveryStatus{NotificationStatus: models.AggregateStatus(pointers)}
+	if err := handler.repo.UpdateNotificationStatus(parentId, status); err != nil {
+		return errors.NewRetriableError("failed to update notification status", err)
+	}
+	return nil
+}
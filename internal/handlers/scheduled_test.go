@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestReleaseGivesUpAfterRepeatedRetriableFailures(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	mail := test.channels[models.EmailNotification]
+	for i := 0; i < maxReleaseRetries+1; i++ {
+		mail.errs = append(mail.errs, errors.NewRetriableError("server busy", nil))
+	}
+
+	message := newMessage(userId, models.EmailNotification)
+	sendAt := test.now.Add(time.Hour)
+	message.SendAt = &sendAt
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	test.now = sendAt
+	for attempt := 1; attempt <= maxReleaseRetries; attempt++ {
+		if err := test.ReleaseDue(); err != nil {
+			t.Fatalf("ReleaseDue: %v", err)
+		}
+		notification := test.only(t, userId)
+		if notification.ReleaseAttempts != attempt {
+			t.Errorf("attempt %d: releaseAttempts = %d", attempt, notification.ReleaseAttempts)
+		}
+		wantStatus := models.Scheduled
+		if attempt == maxReleaseRetries {
+			wantStatus = models.Failed
+		}
+		if notification.DeliveryStatus.NotificationStatus != wantStatus {
+			t.Fatalf("attempt %d: status = %s, want %s", attempt, notification.DeliveryStatus.NotificationStatus, wantStatus)
+		}
+		test.now = test.now.Add(releaseRetryDelay)
+	}
+
+	// A failed notification is not released again
+	test.now = test.now.Add(time.Hour)
+	if err := test.ReleaseDue(); err != nil {
+		t.Fatalf("ReleaseDue: %v", err)
+	}
+	if got := len(mail.delivered); got != maxReleaseRetries {
+		t.Errorf("delivered %d times, want %d", got, maxReleaseRetries)
+	}
+}
+
+func TestReleaseGivesUpOnPendingChannels(t *testing.T) {
+	test := newTestHandler(t)
+	userId := uuid.New()
+	mail := test.channels[models.EmailNotification]
+	for i := 0; i < maxReleaseRetries; i++ {
+		mail.errs = append(mail.errs, errors.NewRetriableError("server busy", nil))
+	}
+
+	message := newMessage(userId, "")
+	message.Channels = []models.ChannelOverride{{Type: models.EmailNotification}, {Type: models.InAppNotification}}
+	sendAt := test.now.Add(time.Hour)
+	message.SendAt = &sendAt
+	if err := test.process(t, message); err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	test.now = sendAt
+	for attempt := 1; attempt <= maxReleaseRetries; attempt++ {
+		if err := test.ReleaseDue(); err != nil {
+			t.Fatalf("ReleaseDue: %v", err)
+		}
+		test.now = test.now.Add(releaseRetryDelay)
+	}
+
+	for _, notification := range test.notifications(t, userId) {
+		want := models.PartiallySent
+		switch {
+		case notification.ParentID == nil:
+		case notification.Type == models.EmailNotification:
+			want = models.Failed
+		default:
+			want = models.Sent
+		}
+		if notification.DeliveryStatus.NotificationStatus != want {
+			t.Errorf("%s notification status = %s, want %s", notification.Type, notification.DeliveryStatus.NotificationStatus, want)
+		}
+	}
+	if got := len(test.channels[models.InAppNotification].delivered); got != 1 {
+		t.Errorf("in-app delivered %d times, want 1", got)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+	"notificationservice/internal/sms"
+)
+
+type SMSOptions struct {
+	MaxSegments int
+}
+
+type SMSHandler struct {
+	provider sms.Provider
+	options  SMSOptions
+}
+
+func NewSMSHandler(provider sms.Provider, options SMSOptions) IHandler {
+	if options.MaxSegments <= 0 {
+		options.MaxSegments = sms.DefaultMaxSegments
+	}
+	return &SMSHandler{provider: provider, options: options}
+}
+
+func SMSChannel(provider sms.Provider, options SMSOptions) Channel {
+	return Channel{
+		Type:    models.SMSNotification,
+		Handler: NewSMSHandler(provider, options),
+	}
+}
+
+func (h *SMSHandler) Deliver(notification *models.Notification) error {
+	message, err := h.buildMessage(notification)
+	if err != nil {
+		return err
+	}
+	return h.provider.Send(message)
+}
+
+func (h *SMSHandler) buildMessage(notification *models.Notification) (*sms.Message, error) {
+	if notification.SMSInfo == nil {
+		return nil, errors.NewValidationError("smsInfo is required for SMS notifications", nil)
+	}
+	if err := sms.ValidatePhoneNumber(notification.SMSInfo.PhoneNumber); err != nil {
+		return nil, errors.NewValidationError("invalid phone number", err)
+	}
+	if notification.Body == "" {
+		return nil, errors.NewValidationError("body is required for SMS notifications", nil)
+	}
+
+	segmentation := sms.Segment(notification.Body)
+	if segmentation.Segments > h.options.MaxSegments {
+		return nil, errors.NewValidationError(fmt.Sprintf("SMS needs %d %s segments, at most %d are allowed",
+			segmentation.Segments, segmentation.Encoding, h.options.MaxSegments), nil)
+	}
+
+	return &sms.Message{
+		To:       notification.SMSInfo.PhoneNumber,
+		Body:     notification.Body,
+		Encoding: segmentation.Encoding,
+		Segments: segmentation.Segments,
+	}, nil
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+	"notificationservice/internal/outbound"
+	"notificationservice/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookIDHeader        = "X-Webhook-Id"
+
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+type WebhookOptions struct {
+	Timeout time.Duration
+	// Policy is checked again on every delivery, as a registered host may
+	// since resolve to another address.
+	Policy outbound.Policy
+}
+
+type WebhookHandler struct {
+	webhooks      repository.WebhookRepository
+	notifications repository.NotificationRepository
+	policy        outbound.Policy
+	client        *http.Client
+}
+
+func NewWebhookHandler(webhooks repository.WebhookRepository, notifications repository.NotificationRepository, options WebhookOptions) IHandler {
+	if options.Timeout <= 0 {
+		options.Timeout = defaultWebhookTimeout
+	}
+	return &WebhookHandler{
+		webhooks:      webhooks,
+		notifications: notifications,
+		policy:        options.Policy,
+		client: &http.Client{
+			Timeout:   options.Timeout,
+			Transport: options.Policy.Transport(),
+			// A redirect would resend the signed payload somewhere the owner
+			// did not register, so it is reported like any other 3xx.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func WebhookChannel(webhooks repository.WebhookRepository, notifications repository.NotificationRepository, options WebhookOptions) Channel {
+	return Channel{
+		Type:         models.WebhookNotification,
+		Handler:      NewWebhookHandler(webhooks, notifications, options),
+		Capabilities: Capabilities{HTML: true},
+	}
+}
+
+// SignWebhookPayload returns the value of the signature header: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret.
+func SignWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *WebhookHandler) Deliver(notification *models.Notification) error {
+	webhook, err := h.resolveWebhook(notification)
+	if err != nil {
+		return err
+	}
+	if _, err := h.policy.CheckURL(webhook.URL); err != nil {
+		return errors.NewValidationError("webhook URL is not allowed", err)
+	}
+
+	body := *notification
+	body.DeliveryAttempts = nil
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return errors.NewProcessingError("failed to serialize notification", err)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return errors.NewValidationError("invalid webhook URL", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookIDHeader, notification.ID.Hex())
+	request.Header.Set(WebhookTimestampHeader, timestamp)
+	request.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, payload))
+
+	attempt := models.DeliveryAttempt{Channel: models.WebhookNotification, AttemptedAt: time.Now()}
+	deliveryErr := h.post(request, &attempt)
+	attempt.LatencyMs = time.Since(attempt.AttemptedAt).Milliseconds()
+	if deliveryErr != nil {
+		attempt.Error = deliveryErr.Error()
+	}
+	h.recordAttempt(notification, attempt)
+	return deliveryErr
+}
+
+func (h *WebhookHandler) post(request *http.Request, attempt *models.DeliveryAttempt) error {
+	response, err := h.client.Do(request)
+	if err != nil {
+		if outbound.IsForbidden(err) {
+			return errors.NewValidationError("webhook URL is not allowed", err)
+		}
+		return errors.NewRetriableError("webhook request failed", err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	attempt.StatusCode = response.StatusCode
+	description := fmt.Sprintf("webhook returned %s", response.Status)
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return nil
+	case response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests:
+		return errors.NewRetriableError(description, nil)
+	}
+	return errors.NewValidationError(description, nil)
+}
+
+// resolveWebhook prefers an endpoint registered for the user over the one of
+// the user's tenant.
+func (h *WebhookHandler) resolveWebhook(notification *models.Notification) (*models.Webhook, error) {
+	webhook, err := h.webhooks.GetWebhook(notification.UserID, "")
+	if err != nil {
+		return nil, errors.NewRetriableError("failed to load webhook", err)
+	}
+	if webhook == nil && notification.TenantID != "" {
+		if webhook, err = h.webhooks.GetWebhook(uuid.Nil, notification.TenantID); err != nil {
+			return nil, errors.NewRetriableError("failed to load webhook", err)
+		}
+	}
+	if webhook == nil {
+		return nil, errors.NewValidationError("no webhook registered for user or tenant", nil)
+	}
+	return webhook, nil
+}
+
+func (h *WebhookHandler) recordAttempt(notification *models.Notification, attempt models.DeliveryAttempt) {
+	notification.DeliveryAttempts = append(notification.DeliveryAttempts, attempt)
+	if err := h.notifications.AddDeliveryAttempt(notification.ID, attempt); err != nil {
+		log.Printf("Failed to record delivery attempt: ID=%v: %v", notification.ID, err)
+	}
+}
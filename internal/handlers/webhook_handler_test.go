@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+	"notificationservice/internal/outbound"
+	"notificationservice/internal/repository"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSetWebhookRejectsInternalEndpoints(t *testing.T) {
+	test := newTestHandler(t)
+	test.webhooks = repository.NewMemoryWebhookRepository()
+
+	for _, endpoint := range []string{
+		"http://hooks.example.com/notify",
+		"ftp://hooks.example.com/notify",
+		"https://localhost/notify",
+		"https://127.0.0.1:8080/notify",
+		"https://10.0.0.5/notify",
+		"https://192.168.1.20/notify",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[fe80::1]/notify",
+		"/relative",
+	} {
+		if _, err := test.SetWebhook(uuid.New(), "", endpoint, ""); !errors.IsValidationError(err) {
+			t.Errorf("SetWebhook(%q) error = %v, want a validation error", endpoint, err)
+		}
+	}
+
+	webhook, err := test.SetWebhook(uuid.New(), "", "https://93.184.216.34/notify", "")
+	if err != nil {
+		t.Fatalf("SetWebhook with a public address: %v", err)
+	}
+	if webhook.Secret == "" {
+		t.Error("no secret was generated")
+	}
+
+	// Development allows local receivers
+	test.SetWebhookPolicy(outbound.Policy{AllowHTTP: true, AllowPrivate: true})
+	if _, err := test.SetWebhook(uuid.New(), "", "http://localhost:8080/notify", ""); err != nil {
+		t.Errorf("SetWebhook in development: %v", err)
+	}
+}
+
+func TestWebhookDeliveryChecksTheEndpointAgain(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		requests++
+	}))
+	defer server.Close()
+
+	webhooks := repository.NewMemoryWebhookRepository()
+	userId := uuid.New()
+	// Registered before the policy applied, or by another instance
+	if err := webhooks.SaveWebhook(&models.Webhook{UserID: userId, URL: server.URL, Secret: "secret"}); err != nil {
+		t.Fatalf("SaveWebhook: %v", err)
+	}
+	notification := &models.Notification{ID: primitive.NewObjectID(), UserID: userId, Subject: "Subject", Body: "Body"}
+
+	handler := NewWebhookHandler(webhooks, repository.NewMemoryRepository(), WebhookOptions{Policy: outbound.Policy{AllowHTTP: true}})
+	if err := handler.Deliver(notification); !errors.IsValidationError(err) {
+		t.Errorf("error = %v, want a validation error", err)
+	}
+	if requests != 0 {
+		t.Errorf("%d requests reached the internal endpoint", requests)
+	}
+
+	handler = NewWebhookHandler(webhooks, repository.NewMemoryRepository(), WebhookOptions{Policy: outbound.Policy{AllowHTTP: true, AllowPrivate: true}})
+	if err := handler.Deliver(notification); err != nil {
+		t.Fatalf("Deliver with private addresses allowed: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+	"notificationservice/internal/outbound"
+
+	"github.com/google/uuid"
+)
+
+// SetWebhookPolicy sets which webhook URLs may be registered.
+func (handler *Handler) SetWebhookPolicy(policy outbound.Policy) {
+	handler.webhookPolicy = policy
+}
+
+// SetWebhook registers or replaces the webhook of a user (tenantId empty) or
+// a tenant (userId uuid.Nil). A secret is generated when none is given; it is
+// only returned here, never by GetWebhook.
+func (handler *Handler) SetWebhook(userId uuid.UUID, tenantId string, endpoint string, secret string) (*models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := handler.webhookPolicy.CheckDestination(ctx, endpoint); err != nil {
+		return nil, errors.NewValidationError("url is not an allowed webhook endpoint", err)
+	}
+	if secret == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, errors.NewProcessingError("failed to generate webhook secret", err)
+		}
+		secret = hex.EncodeToString(random)
+	}
+
+	webhook := &models.Webhook{UserID: userId, TenantID: tenantId, URL: endpoint, Secret: secret}
+	if err := handler.webhooks.SaveWebhook(webhook); err != nil {
+		return nil, errors.NewProcessingError("failed to save webhook", err)
+	}
+	return webhook, nil
+}
+
+func (handler *Handler) GetWebhook(userId uuid.UUID, tenantId string) (*models.Webhook, error) {
+	webhook, err := handler.webhooks.GetWebhook(userId, tenantId)
+	if err != nil {
+		return nil, errors.NewProcessingError("failed to get webhook", err)
+	}
+	if webhook == nil {
+		return nil, errors.NewNotFoundError("webhook not found", nil)
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (handler *Handler) DeleteWebhook(userId uuid.UUID, tenantId string) error {
+	deleted, err := handler.webhooks.DeleteWebhook(userId, tenantId)
+	if err != nil {
+		return errors.NewProcessingError("failed to delete webhook", err)
+	}
+	if !deleted {
+		return errors.NewNotFoundError("webhook not found", nil)
+	}
+	return nil
+}
@@ -1,18 +1,36 @@
 package handlers
 
 import (
-	"fmt"
+	"encoding/json"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/hub"
 	"notificationservice/internal/models"
 )
 
-type WebSocketHandler struct{}
+type WebSocketHandler struct {
+	hub *hub.Hub
+}
 
-func NewWebSocketHandler() IHandler {
-	return &WebSocketHandler{}
+func NewWebSocketHandler(hub *hub.Hub) IHandler {
+	return &WebSocketHandler{hub: hub}
+}
+
+func InAppChannel(hub *hub.Hub) Channel {
+	return Channel{
+		Type:         models.InAppNotification,
+		Handler:      NewWebSocketHandler(hub),
+		Capabilities: Capabilities{ReadReceipts: true, RequiresPresence: true},
+	}
 }
 
 func (h *WebSocketHandler) Deliver(notification *models.Notification) error {
-	// TODO: implement WebSocket push logic
-	fmt.Println("Sending WebSocket notification to user", notification.UserID)
+	payload, err := json.Marshal(hub.Event{Event: hub.NotificationEvent, Notification: notification})
+	if err != nil {
+		return errors.NewProcessingError("failed to serialize notification", err)
+	}
+	if h.hub.Send(notification.UserID, payload) == 0 {
+		return errors.NewUnavailableError("user has no active websocket connection", nil)
+	}
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,101 @@
+package hub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+	sendBufferSize = 32
+)
+
+type Client struct {
+	hub       *Hub
+	userID    uuid.UUID
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newClient(hub *Hub, userID uuid.UUID, conn *websocket.Conn) *Client {
+	return &Client{
+		hub:    hub,
+		userID: userID,
+		conn:   conn,
+		send:   make(chan []byte, sendBufferSize),
+		done:   make(chan struct{}),
+	}
+}
+
+func (client *Client) enqueue(payload []byte) bool {
+	select {
+	case <-client.done:
+		return false
+	default:
+	}
+
+	select {
+	case client.send <- payload:
+		return true
+	default:
+		return false
+	}
+}
+
+func (client *Client) close() {
+	client.closeOnce.Do(func() {
+		close(client.done)
+		client.conn.Close()
+	})
+}
+
+func (client *Client) readPump() {
+	defer client.hub.unregister(client)
+
+	client.conn.SetReadLimit(maxMessageSize)
+	client.conn.SetReadDeadline(time.Now().Add(pongWait))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := client.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		client.hub.handleClientMessage(client, data)
+	}
+}
+
+func (client *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.hub.unregister(client)
+	}()
+
+	for {
+		select {
+		case <-client.done:
+			return
+		case payload := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := client.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
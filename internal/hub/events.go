@@ -0,0 +1,103 @@
+package hub
+
+import (
+	"encoding/json"
+	"log"
+
+	"notificationservice/internal/errors"
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	NotificationEvent = "notification"
+	UnreadCountEvent  = "unreadCount"
+	ErrorEvent        = "error"
+
+	MarkReadAction    = "markRead"
+	MarkAllReadAction = "markAllRead"
+)
+
+type Event struct {
+	Event        string               `json:"event"`
+	Notification *models.Notification `json:"notification,omitempty"`
+	UnreadCount  *int64               `json:"unreadCount,omitempty"`
+	Message      string               `json:"message,omitempty"`
+}
+
+type ClientMessage struct {
+	Action string   `json:"action"`
+	IDs    []string `json:"ids,omitempty"`
+}
+
+type ReadHandler interface {
+	MarkNotificationsRead(userId uuid.UUID, notificationIds []primitive.ObjectID) (int64, error)
+	MarkAllNotificationsRead(userId uuid.UUID) (int64, error)
+}
+
+func (hub *Hub) SetReadHandler(readHandler ReadHandler) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	hub.readHandler = readHandler
+}
+
+func (hub *Hub) handleClientMessage(client *Client, data []byte) {
+	var message ClientMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		hub.reply(client, Event{Event: ErrorEvent, Message: "invalid JSON message"})
+		return
+	}
+
+	hub.mutex.RLock()
+	readHandler := hub.readHandler
+	hub.mutex.RUnlock()
+	if readHandler == nil {
+		hub.reply(client, Event{Event: ErrorEvent, Message: "read receipts are not supported"})
+		return
+	}
+
+	var unreadCount int64
+	var err error
+	switch message.Action {
+	case MarkReadAction:
+		var notificationIds []primitive.ObjectID
+		notificationIds, err = models.ParseNotificationIDs(message.IDs)
+		if err == nil {
+			unreadCount, err = readHandler.MarkNotificationsRead(client.userID, notificationIds)
+		}
+	case MarkAllReadAction:
+		unreadCount, err = readHandler.MarkAllNotificationsRead(client.userID)
+	default:
+		err = errors.NewValidationError("unknown action: "+message.Action, nil)
+	}
+	if err != nil {
+		log.Printf("Websocket action %q failed for user %s: %v", message.Action, client.userID, err)
+		hub.reply(client, Event{Event: ErrorEvent, Message: errors.GetErrorDescription(err)})
+		return
+	}
+
+	// Every open tab of the user should clear its badge, not only the one that asked
+	hub.Broadcast(client.userID, Event{Event: UnreadCountEvent, UnreadCount: &unreadCount})
+}
+
+func (hub *Hub) Broadcast(userID uuid.UUID, event Event) int {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to serialize websocket event: %v", err)
+		return 0
+	}
+	return hub.Send(userID, payload)
+}
+
+func (hub *Hub) reply(client *Client, event Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to serialize websocket event: %v", err)
+		return
+	}
+	if !client.enqueue(payload) {
+		hub.unregister(client)
+	}
+}
@@ -0,0 +1,117 @@
+package hub
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"notificationservice/internal/auth"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+type Hub struct {
+	mutex         sync.RWMutex
+	clients       map[uuid.UUID]map[*Client]struct{}
+	readHandler   ReadHandler
+	authenticator auth.Authenticator
+	upgrader      websocket.Upgrader
+}
+
+func NewHub(authenticator auth.Authenticator) *Hub {
+	return &Hub{
+		clients:       make(map[uuid.UUID]map[*Client]struct{}),
+		authenticator: authenticator,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+}
+
+func (hub *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := hub.authenticator.Authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := hub.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade websocket connection: %v", err)
+		return
+	}
+
+	client := newClient(hub, userID, conn)
+	hub.register(client)
+
+	go client.writePump()
+	go client.readPump()
+}
+
+func (hub *Hub) Send(userID uuid.UUID, payload []byte) int {
+	hub.mutex.RLock()
+	clients := make([]*Client, 0, len(hub.clients[userID]))
+	for client := range hub.clients[userID] {
+		clients = append(clients, client)
+	}
+	hub.mutex.RUnlock()
+
+	delivered := 0
+	for _, client := range clients {
+		if client.enqueue(payload) {
+			delivered++
+		} else {
+			log.Printf("Websocket send buffer full for user %s, dropping connection", userID)
+			hub.unregister(client)
+		}
+	}
+	return delivered
+}
+
+func (hub *Hub) IsOnline(userID uuid.UUID) bool {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	return len(hub.clients[userID]) > 0
+}
+
+func (hub *Hub) Close() {
+	hub.mutex.Lock()
+	clients := hub.clients
+	hub.clients = make(map[uuid.UUID]map[*Client]struct{})
+	hub.mutex.Unlock()
+
+	for _, userClients := range clients {
+		for client := range userClients {
+			client.close()
+		}
+	}
+}
+
+func (hub *Hub) register(client *Client) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	if hub.clients[client.userID] == nil {
+		hub.clients[client.userID] = make(map[*Client]struct{})
+	}
+	hub.clients[client.userID][client] = struct{}{}
+	log.Printf("Websocket connected: User=%s, Connections=%d", client.userID, len(hub.clients[client.userID]))
+}
+
+func (hub *Hub) unregister(client *Client) {
+	hub.mutex.Lock()
+	if userClients, ok := hub.clients[client.userID]; ok {
+		if _, ok := userClients[client]; ok {
+			delete(userClients, client)
+			if len(userClients) == 0 {
+				delete(hub.clients, client.userID)
+			}
+			log.Printf("Websocket disconnected: User=%s", client.userID)
+		}
+	}
+	hub.mutex.Unlock()
+
+	client.close()
+}
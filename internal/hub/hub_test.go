@@ -0,0 +1,55 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"notificationservice/internal/auth"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// stubAuthenticator knows the users of the access tokens it maps.
+type stubAuthenticator map[string]uuid.UUID
+
+func (authenticator stubAuthenticator) Authenticate(r *http.Request) (uuid.UUID, error) {
+	userID, ok := authenticator[r.URL.Query().Get("access_token")]
+	if !ok {
+		return uuid.Nil, auth.ErrUnauthenticated
+	}
+	return userID, nil
+}
+
+func TestServeHTTPConnectsTheTokenUser(t *testing.T) {
+	userID := uuid.New()
+	hub := NewHub(stubAuthenticator{"valid-token": userID})
+	defer hub.Close()
+	server := httptest.NewServer(hub)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// The userId query parameter no longer identifies the user
+	_, response, err := websocket.DefaultDialer.Dial(url+"?userId="+uuid.NewString(), nil)
+	if err == nil || response == nil || response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated upgrade: err = %v, want 401", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?access_token=valid-token", nil)
+	if err != nil {
+		t.Fatalf("authenticated upgrade: %v", err)
+	}
+	defer conn.Close()
+
+	// The hub registers the client just after the upgrade response
+	deadline := time.Now().Add(time.Second)
+	for !hub.IsOnline(userID) {
+		if time.Now().After(deadline) {
+			t.Fatal("connection was not registered for the token user")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
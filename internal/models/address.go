@@ -0,0 +1,92 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Address is an email address with an optional display name. In JSON it may
+// be given as an object or as a string like "Jane Doe <jane@example.com>".
+type Address struct {
+	Name    string `bson:"name,omitempty" json:"name,omitempty"`
+	Address string `bson:"address" json:"address"`
+}
+
+func ParseAddress(value string) Address {
+	parsed, err := mail.ParseAddress(value)
+	if err != nil {
+		// Kept verbatim so validation can report it instead of the JSON decoder
+		return Address{Address: value}
+	}
+	return Address{Name: parsed.Name, Address: parsed.Address}
+}
+
+func (address Address) Validate() (*mail.Address, error) {
+	parsed, err := mail.ParseAddress(address.Address)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Name != "" {
+		return nil, fmt.Errorf("address %q must not contain a display name", address.Address)
+	}
+	parsed.Name = address.Name
+	return parsed, nil
+}
+
+func (address *Address) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*address = ParseAddress(value)
+		return nil
+	}
+
+	type plain Address
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*address = Address(decoded)
+	return nil
+}
+
+type AddressList []Address
+
+func (list *AddressList) UnmarshalJSON(data []byte) error {
+	var addresses []Address
+	if err := json.Unmarshal(data, &addresses); err == nil {
+		*list = addresses
+		return nil
+	}
+
+	var address Address
+	if err := json.Unmarshal(data, &address); err != nil {
+		return err
+	}
+	*list = AddressList{address}
+	return nil
+}
+
+// UnmarshalBSONValue also accepts the single string that "to" used to be
+// stored as, so older notifications can still be read and retried.
+func (list *AddressList) UnmarshalBSONValue(valueType bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: valueType, Value: data}
+	switch valueType {
+	case bsontype.String:
+		*list = AddressList{ParseAddress(raw.StringValue())}
+		return nil
+	case bsontype.Null, bsontype.Undefined:
+		*list = nil
+		return nil
+	}
+
+	var addresses []Address
+	if err := raw.Unmarshal(&addresses); err != nil {
+		return err
+	}
+	*list = addresses
+	return nil
+}
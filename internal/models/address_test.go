@@ -0,0 +1,61 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAddressListReadsLegacyBSON(t *testing.T) {
+	tests := []struct {
+		name string
+		to   interface{}
+		want AddressList
+	}{
+		{"legacy string", "jane@example.com", AddressList{{Address: "jane@example.com"}}},
+		{"legacy string with a display name", "Jane Doe <jane@example.com>", AddressList{{Name: "Jane Doe", Address: "jane@example.com"}}},
+		{"legacy string with an encoded name", "=?utf-8?q?Ren=C3=A9e?= <renee@example.com>", AddressList{{Name: "Renée", Address: "renee@example.com"}}},
+		{"legacy invalid string kept for validation", "not an address", AddressList{{Address: "not an address"}}},
+		{
+			"list",
+			bson.A{bson.D{{Key: "name", Value: "Jane Doe"}, {Key: "address", Value: "jane@example.com"}}, bson.D{{Key: "address", Value: "john@example.com"}}},
+			AddressList{{Name: "Jane Doe", Address: "jane@example.com"}, {Address: "john@example.com"}},
+		},
+		{"null", nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := bson.Marshal(bson.D{{Key: "to", Value: test.to}})
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			var details MailDetails
+			if err := bson.Unmarshal(data, &details); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if len(details.To) != len(test.want) {
+				t.Fatalf("to = %+v, want %+v", details.To, test.want)
+			}
+			for i := range test.want {
+				if details.To[i] != test.want[i] {
+					t.Errorf("to[%d] = %+v, want %+v", i, details.To[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAddressListBSONRoundTrip(t *testing.T) {
+	details := MailDetails{To: AddressList{{Name: "Zoë Bäcker", Address: "zoe@example.com"}}}
+	data, err := bson.Marshal(details)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded MailDetails
+	if err := bson.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(decoded.To) != 1 || decoded.To[0] != details.To[0] {
+		t.Errorf("to = %+v, want %+v", decoded.To, details.To)
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Platform string
+
+const (
+	AndroidPlatform Platform = "android"
+	IOSPlatform     Platform = "ios"
+)
+
+func (platform Platform) IsValid() bool {
+	switch platform {
+	case AndroidPlatform, IOSPlatform:
+		return true
+	}
+	return false
+}
+
+// Device is a push token registered by one of a user's apps. A token belongs
+// to a single user; registering it again moves it to the new user.
+type Device struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    uuid.UUID          `bson:"userId" json:"userId"`
+	Token     string             `bson:"token" json:"token"`
+	Platform  Platform           `bson:"platform" json:"platform"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DigestFrequency chooses how email of a digest category reaches a user:
+// one by one, or collected into an hourly or daily summary.
+type DigestFrequency string
+
+const (
+	DigestImmediate DigestFrequency = "immediate"
+	DigestHourly    DigestFrequency = "hourly"
+	DigestDaily     DigestFrequency = "daily"
+)
+
+func (frequency DigestFrequency) IsValid() bool {
+	switch frequency {
+	case DigestImmediate, DigestHourly, DigestDaily:
+		return true
+	}
+	return false
+}
+
+// Batches reports whether notifications are held for a digest.
+func (frequency DigestFrequency) Batches() bool {
+	return frequency == DigestHourly || frequency == DigestDaily
+}
+
+// NextDue returns when a digest started at now is sent: at the next full hour,
+// or at the next dailyAt ("HH:MM", UTC).
+func (frequency DigestFrequency) NextDue(now time.Time, dailyAt string) (time.Time, error) {
+	switch frequency {
+	case DigestHourly:
+		return now.UTC().Truncate(time.Hour).Add(time.Hour), nil
+	case DigestDaily:
+		minute, err := parseTimeOfDay(dailyAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid daily digest time: %w", err)
+		}
+		utc := now.UTC()
+		due := time.Date(utc.Year(), utc.Month(), utc.Day(), 0, minute, 0, 0, time.UTC)
+		if !due.After(utc) {
+			due = due.AddDate(0, 0, 1)
+		}
+		return due, nil
+	}
+	return time.Time{}, fmt.Errorf("%s notifications are not batched", frequency)
+}
+
+// DigestInfo marks the summary email a digest is sent as. The notifications
+// it carries point at it through their DigestID.
+type DigestInfo struct {
+	Frequency DigestFrequency `bson:"frequency" json:"frequency"`
+	Count     int             `bson:"count,omitempty" json:"count,omitempty"`
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration written as "15m" in JSON. BSON keeps the
+// nanosecond count.
+type Duration time.Duration
+
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
+
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like \"15m\": %w", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*duration = Duration(parsed)
+	return nil
+}
+
+// FallbackStep is one channel of a fallback chain. The chain moves on when
+// the channel is unavailable or fails, and, with UnreadAfter, when the
+// notification is still unread that long after delivery.
+type FallbackStep struct {
+	Type        NotificationType `bson:"type" json:"type"`
+	UnreadAfter Duration         `bson:"unreadAfter,omitempty" json:"unreadAfter,omitempty"`
+}
+
+func ValidateFallbackSteps(steps []FallbackStep) error {
+	for i, step := range steps {
+		if step.Type == "" {
+			return fmt.Errorf("fallback step %d has no type", i+1)
+		}
+		if step.UnreadAfter < 0 {
+			return fmt.Errorf("fallback step %d has a negative unreadAfter", i+1)
+		}
+	}
+	return nil
+}
+
+type FallbackReason string
+
+const (
+	FallbackDelivered   FallbackReason = "delivered"
+	FallbackReceived    FallbackReason = "received"
+	FallbackUnread      FallbackReason = "unread"
+	FallbackUnavailable FallbackReason = "unavailable"
+	FallbackFailed      FallbackReason = "failed"
+	FallbackSuppressed  FallbackReason = "suppressed"
+	FallbackExhausted   FallbackReason = "exhausted"
+)
+
+type FallbackDecision struct {
+	At      time.Time        `bson:"at" json:"at"`
+	Channel NotificationType `bson:"channel" json:"channel"`
+	Reason  FallbackReason   `bson:"reason" json:"reason"`
+	Next    NotificationType `bson:"next,omitempty" json:"next,omitempty"`
+	Detail  string           `bson:"detail,omitempty" json:"detail,omitempty"`
+}
+
+// FallbackState tracks a notification through its fallback chain. CheckAt is
+// when the scheduler next looks at it: to check whether a delivered
+// notification was read, or to retry a pending one.
+type FallbackState struct {
+	Steps        []FallbackStep     `bson:"steps" json:"steps"`
+	Step         int                `bson:"step" json:"step"`
+	Attempts     int                `bson:"attempts,omitempty" json:"-"`
+	CheckAt      *time.Time         `bson:"checkAt,omitempty" json:"checkAt,omitempty"`
+	DeliveredVia NotificationType   `bson:"deliveredVia,omitempty" json:"deliveredVia,omitempty"`
+	Decisions    []FallbackDecision `bson:"decisions,omitempty" json:"decisions,omitempty"`
+}
+
+func NewFallbackState(steps []FallbackStep) *FallbackState {
+	if len(steps) == 0 {
+		return nil
+	}
+	return &FallbackState{Steps: steps}
+}
+
+func (state *FallbackState) Current() FallbackStep {
+	return state.Steps[state.Step]
+}
+
+func (state *FallbackState) HasNext() bool {
+	return state.Step+1 < len(state.Steps)
+}
+
+// Record notes a decision about the current step, made at now.
+func (state *FallbackState) Record(now time.Time, reason FallbackReason, detail string) {
+	state.Decisions = append(state.Decisions, FallbackDecision{
+		At:      now,
+		Channel: state.Current().Type,
+		Reason:  reason,
+		Detail:  detail,
+	})
+}
+
+// Advance records why the current step was given up and moves to the next.
+func (state *FallbackState) Advance(now time.Time, reason FallbackReason, detail string) {
+	state.Record(now, reason, detail)
+	state.Step++
+	state.Attempts = 0
+	state.Decisions[len(state.Decisions)-1].Next = state.Current().Type
+}
@@ -1,8 +1,11 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
+	"notificationservice/internal/errors"
+
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -12,23 +15,65 @@ type NotificationType string
 const (
     EmailNotification     NotificationType = "Mail"
     InAppNotification NotificationType = "InApp"
+    SMSNotification   NotificationType = "SMS"
+    PushNotification  NotificationType = "Push"
+    WebhookNotification NotificationType = "Webhook"
+    ChatNotification    NotificationType = "Chat"
 )
 
+func (notificationType NotificationType) IsValid() bool {
+    switch notificationType {
+    case EmailNotification, InAppNotification, SMSNotification, PushNotification, WebhookNotification, ChatNotification:
+        return true
+    }
+    return false
+}
+
 type NotificationStatus string
 
 const (
     Pending NotificationStatus = "Pending"
     Sent    NotificationStatus = "Sent"
     Failed  NotificationStatus = "Failed"
+    PartiallySent NotificationStatus = "PartiallySent"
+    Suppressed    NotificationStatus = "Suppressed"
+    Deferred      NotificationStatus = "Deferred"
+    Scheduled     NotificationStatus = "Scheduled"
+    Cancelled     NotificationStatus = "Cancelled"
+    Expired       NotificationStatus = "Expired"
+    Batched       NotificationStatus = "Batched"
+    Digested      NotificationStatus = "Digested"
 )
 
+func (status NotificationStatus) IsValid() bool {
+    switch status {
+    case Pending, Sent, Failed, PartiallySent, Suppressed, Deferred, Scheduled, Cancelled, Expired, Batched, Digested:
+        return true
+    }
+    return false
+}
+
 type NotificationMessage struct {
-	UserID     uuid.UUID        `json:"userId"`
-	ExternalID uuid.UUID        `json:"externalId"`
-	Subject    string           `json:"subject"`
-	Body       string           `json:"body"`
-	Type       NotificationType `json:"type"`
-	MailInfo   *MailDetails     `json:"mailInfo,omitempty"`
+	UserID          uuid.UUID              `json:"userId"`
+	ExternalID      uuid.UUID              `json:"externalId"`
+	TenantID        string                 `json:"tenantId,omitempty"`
+	Subject         string                 `json:"subject"`
+	Body            string                 `json:"body"`
+	Type            NotificationType       `json:"type"`
+	MailInfo        *MailDetails           `json:"mailInfo,omitempty"`
+	SMSInfo         *SMSDetails            `json:"smsInfo,omitempty"`
+	PushInfo        *PushDetails           `json:"pushInfo,omitempty"`
+	ChatInfo        *ChatDetails           `json:"chatInfo,omitempty"`
+	TemplateID      string                 `json:"templateId,omitempty"`
+	TemplateVersion int                    `json:"templateVersion,omitempty"`
+	TemplateData    map[string]interface{} `json:"templateData,omitempty"`
+	Channels        []ChannelOverride      `json:"channels,omitempty"`
+	Category        string                 `json:"category,omitempty"`
+	Fallback        []FallbackStep         `json:"fallback,omitempty"`
+	Urgent          bool                   `json:"urgent,omitempty"`
+	SendAt          *time.Time             `json:"sendAt,omitempty"`
+	ExpiresAt       *time.Time             `json:"expiresAt,omitempty"`
+	TTL             Duration               `json:"ttl,omitempty"`
 }
 
 func (msg *NotificationMessage) ToNotification() *Notification {
@@ -36,10 +81,21 @@ func (msg *NotificationMessage) ToNotification() *Notification {
 	return &Notification{
 		UserID:     msg.UserID,
 		ExternalID: msg.ExternalID,
+		TenantID:   msg.TenantID,
 		Subject:    msg.Subject,
 		Body:       msg.Body,
 		Type:       msg.Type,
 		MailInfo:   msg.MailInfo,
+		SMSInfo:    msg.SMSInfo,
+		PushInfo:   msg.PushInfo,
+		ChatInfo:   msg.ChatInfo,
+		Template:   msg.templateReference(),
+		Channels:   msg.Channels,
+		Category:   msg.Category,
+		Fallback:   NewFallbackState(msg.Fallback),
+		Urgent:     msg.Urgent,
+		DeliverAt:  msg.SendAt,
+		ExpiresAt:  msg.ExpiresAt,
 		DeliveryStatus: DeliveryStatus{
 			NotificationStatus: Pending,
 			UpdatedAt:  now,
@@ -48,27 +104,227 @@ func (msg *NotificationMessage) ToNotification() *Notification {
 	}
 }
 
+func (msg *NotificationMessage) templateReference() *TemplateReference {
+	if msg.TemplateID == "" {
+		return nil
+	}
+	return &TemplateReference{
+		ID:      msg.TemplateID,
+		Version: msg.TemplateVersion,
+		Data:    msg.TemplateData,
+	}
+}
+
+// ChannelOverride selects one channel of a multi-channel message. Set fields
+// replace the message's own subject, body or channel details for that channel.
+type ChannelOverride struct {
+	Type     NotificationType `bson:"type" json:"type"`
+	Subject  string           `bson:"subject,omitempty" json:"subject,omitempty"`
+	Body     string           `bson:"body,omitempty" json:"body,omitempty"`
+	MailInfo *MailDetails     `bson:"mailInfo,omitempty" json:"mailInfo,omitempty"`
+	SMSInfo  *SMSDetails      `bson:"smsInfo,omitempty" json:"smsInfo,omitempty"`
+	PushInfo *PushDetails     `bson:"pushInfo,omitempty" json:"pushInfo,omitempty"`
+	ChatInfo *ChatDetails     `bson:"chatInfo,omitempty" json:"chatInfo,omitempty"`
+}
+
+type TemplateReference struct {
+	ID      string                 `bson:"id" json:"id"`
+	Version int                    `bson:"version" json:"version"`
+	Data    map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`
+}
+
 type DeliveryStatus struct {
     NotificationStatus     NotificationStatus `bson:"notificationStatus" json:"notificationStatus"`
     UpdatedAt  time.Time          `bson:"updatedAt" json:"-"`
     Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+    Reason     string             `bson:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// DeliveryAttempt records one call to an external endpoint, successful or
+// not.
+type DeliveryAttempt struct {
+	Channel     NotificationType `bson:"channel" json:"channel"`
+	AttemptedAt time.Time        `bson:"attemptedAt" json:"attemptedAt"`
+	StatusCode  int              `bson:"statusCode,omitempty" json:"statusCode,omitempty"`
+	LatencyMs   int64            `bson:"latencyMs" json:"latencyMs"`
+	Error       string           `bson:"error,omitempty" json:"error,omitempty"`
 }
 
 type MailDetails struct {
-	To      string   `bson:"to" json:"to"`
-	CC      []string `bson:"cc,omitempty" json:"cc,omitempty"`
-	BCC     []string `bson:"bcc,omitempty" json:"bcc,omitempty"`
+	From        *Address          `bson:"from,omitempty" json:"from,omitempty"`
+	Sender      *Address          `bson:"sender,omitempty" json:"sender,omitempty"`
+	To          AddressList       `bson:"to" json:"to"`
+	CC          AddressList       `bson:"cc,omitempty" json:"cc,omitempty"`
+	BCC         AddressList       `bson:"bcc,omitempty" json:"bcc,omitempty"`
+	ReplyTo     *Address          `bson:"replyTo,omitempty" json:"replyTo,omitempty"`
+	HTMLBody    string            `bson:"htmlBody,omitempty" json:"htmlBody,omitempty"`
+	TextBody    string            `bson:"textBody,omitempty" json:"textBody,omitempty"`
+	Headers     map[string]string `bson:"headers,omitempty" json:"headers,omitempty"`
+	Attachments []Attachment      `bson:"attachments,omitempty" json:"attachments,omitempty"`
+}
+
+type SMSDetails struct {
+	PhoneNumber string `bson:"phoneNumber" json:"phoneNumber"`
+}
+
+// PushDetails are optional; subject and body become the push title and text.
+type PushDetails struct {
+	Data  map[string]string `bson:"data,omitempty" json:"data,omitempty"`
+	Badge *int              `bson:"badge,omitempty" json:"badge,omitempty"`
+	Sound string            `bson:"sound,omitempty" json:"sound,omitempty"`
+}
+
+// ChatDetails name one of the configured chat webhooks; without it the one
+// named "default" is used.
+type ChatDetails struct {
+	Destination string `bson:"destination,omitempty" json:"destination,omitempty"`
+}
+
+// Attachment carries its content inline (base64 in JSON) or points at a URL
+// that is fetched when the email is sent. Inline attachments are referenced
+// from the HTML body as cid:<contentId>.
+//
+// Content is never part of the notification document: it is stored on its
+// own and referenced by ContentRef. Nor is it echoed back as JSON.
+type Attachment struct {
+	Filename    string              `bson:"filename" json:"filename"`
+	ContentType string              `bson:"contentType,omitempty" json:"contentType,omitempty"`
+	Content     []byte              `bson:"-" json:"content,omitempty"`
+	ContentRef  *primitive.ObjectID `bson:"contentRef,omitempty" json:"-"`
+	Size        int64               `bson:"size,omitempty" json:"size,omitempty"`
+	URL         string              `bson:"url,omitempty" json:"url,omitempty"`
+	// The BSON driver reads a key named "inline" as its inline flag
+	Inline    bool   `bson:"isInline,omitempty" json:"inline,omitempty"`
+	ContentID string `bson:"contentId,omitempty" json:"contentId,omitempty"`
+}
+
+func (attachment Attachment) MarshalJSON() ([]byte, error) {
+	type withoutContent Attachment
+	marshalled := withoutContent(attachment)
+	marshalled.Content = nil
+	return json.Marshal(marshalled)
 }
 
 type Notification struct {
-	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
-	UserID         uuid.UUID           `bson:"userId" json:"userId"`
-	ExternalID     uuid.UUID           `bson:"externalId" json:"externalId"`
-	Subject        string              `bson:"subject" json:"subject"`
-	Body           string              `bson:"body" json:"body"`
-	Type           NotificationType    `bson:"type" json:"type"`
-	DeliveryStatus DeliveryStatus      `bson:"deliveryStatus" json:"deliveryStatus"`
-	MailInfo       *MailDetails        `bson:"mailInfo,omitempty" json:"mailInfo,omitempty"`
-	CreatedAt      time.Time           `bson:"createdAt" json:"-"`
-	ReceivedAt     *time.Time          `bson:"receivedAt,omitempty" json:"-"`
-}
\ No newline at end of file
+	ID               primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID           uuid.UUID           `bson:"userId" json:"userId"`
+	ExternalID       uuid.UUID           `bson:"externalId" json:"externalId"`
+	TenantID         string              `bson:"tenantId,omitempty" json:"tenantId,omitempty"`
+	Subject          string              `bson:"subject" json:"subject"`
+	Body             string              `bson:"body" json:"body"`
+	Type             NotificationType    `bson:"type" json:"type"`
+	ParentID         *primitive.ObjectID `bson:"parentId,omitempty" json:"parentId,omitempty"`
+	Channels         []ChannelOverride   `bson:"channels,omitempty" json:"channels,omitempty"`
+	Category         string              `bson:"category,omitempty" json:"category,omitempty"`
+	Fallback         *FallbackState      `bson:"fallback,omitempty" json:"fallback,omitempty"`
+	Urgent           bool                `bson:"urgent,omitempty" json:"urgent,omitempty"`
+	DeliveryStatus   DeliveryStatus      `bson:"deliveryStatus" json:"deliveryStatus"`
+	DeliverAt        *time.Time          `bson:"deliverAt,omitempty" json:"deliverAt,omitempty"`
+	DispatchedAt     *time.Time          `bson:"dispatchedAt,omitempty" json:"dispatchedAt,omitempty"`
+	ReleaseAttempts  int                 `bson:"releaseAttempts,omitempty" json:"releaseAttempts,omitempty"`
+	ExpiresAt        *time.Time          `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	Digest           *DigestInfo         `bson:"digest,omitempty" json:"digest,omitempty"`
+	DigestID         *primitive.ObjectID `bson:"digestId,omitempty" json:"digestId,omitempty"`
+	DeliveryAttempts []DeliveryAttempt   `bson:"deliveryAttempts,omitempty" json:"deliveryAttempts,omitempty"`
+	MailInfo         *MailDetails        `bson:"mailInfo,omitempty" json:"mailInfo,omitempty"`
+	SMSInfo          *SMSDetails         `bson:"smsInfo,omitempty" json:"smsInfo,omitempty"`
+	PushInfo         *PushDetails        `bson:"pushInfo,omitempty" json:"pushInfo,omitempty"`
+	ChatInfo         *ChatDetails        `bson:"chatInfo,omitempty" json:"chatInfo,omitempty"`
+	Template         *TemplateReference  `bson:"template,omitempty" json:"template,omitempty"`
+	CreatedAt        time.Time           `bson:"createdAt" json:"createdAt"`
+	ReceivedAt       *time.Time          `bson:"receivedAt,omitempty" json:"receivedAt,omitempty"`
+}
+
+// IsMultiChannel reports whether the notification is the parent record of a
+// multi-channel message; each channel is delivered as a child notification.
+func (notification *Notification) IsMultiChannel() bool {
+	return len(notification.Channels) > 0 && notification.ParentID == nil
+}
+
+// NewChannelNotification builds the child notification delivered on one
+// channel of a multi-channel parent. Subject and body overrides are left to
+// the caller, as they have to win over a rendered template.
+func (notification *Notification) NewChannelNotification(override ChannelOverride) *Notification {
+	child := &Notification{
+		UserID:     notification.UserID,
+		ExternalID: notification.ExternalID,
+		TenantID:   notification.TenantID,
+		Category:   notification.Category,
+		Urgent:     notification.Urgent,
+		ExpiresAt:  notification.ExpiresAt,
+		Subject:    notification.Subject,
+		Body:       notification.Body,
+		Type:       override.Type,
+		ParentID:   &notification.ID,
+		MailInfo:   notification.MailInfo,
+		SMSInfo:    notification.SMSInfo,
+		PushInfo:   notification.PushInfo,
+		ChatInfo:   notification.ChatInfo,
+		Template:   notification.Template,
+	}
+	if override.MailInfo != nil {
+		child.MailInfo = override.MailInfo
+	}
+	if override.SMSInfo != nil {
+		child.SMSInfo = override.SMSInfo
+	}
+	if override.PushInfo != nil {
+		child.PushInfo = override.PushInfo
+	}
+	if override.ChatInfo != nil {
+		child.ChatInfo = override.ChatInfo
+	}
+	if child.MailInfo != nil {
+		// Rendering a template fills in the HTML body, which must not leak
+		// into the parent or its other children
+		mailInfo := *child.MailInfo
+		child.MailInfo = &mailInfo
+	}
+	return child
+}
+
+// AggregateStatus derives the status of a multi-channel parent: pending while
+// any channel is, otherwise sent, failed or partially sent. A channel handed to
+// a digest counts as sent. Suppressed and expired channels do not count unless
+// no channel was attempted at all.
+func AggregateStatus(children []*Notification) NotificationStatus {
+	var sent, failed, expired int
+	for _, child := range children {
+		switch child.DeliveryStatus.NotificationStatus {
+		case Sent, Digested:
+			sent++
+		case Failed:
+			failed++
+		case Expired:
+			expired++
+		case Suppressed:
+		default:
+			return Pending
+		}
+	}
+	switch {
+	case sent == 0 && failed == 0 && expired > 0:
+		return Expired
+	case sent == 0 && failed == 0:
+		return Suppressed
+	case failed == 0:
+		return Sent
+	case sent == 0:
+		return Failed
+	}
+	return PartiallySent
+}
+
+// ParseNotificationIDs parses the hex IDs clients send to refer to
+// notifications.
+func ParseNotificationIDs(values []string) ([]primitive.ObjectID, error) {
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return nil, errors.NewValidationError("invalid notification id: "+value, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import "testing"
+
+func TestAggregateStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		children []NotificationStatus
+		want     NotificationStatus
+	}{
+		{"all sent", []NotificationStatus{Sent, Sent}, Sent},
+		{"sent and digested", []NotificationStatus{Sent, Digested}, Sent},
+		{"sent and suppressed", []NotificationStatus{Sent, Suppressed}, Sent},
+		{"sent and failed", []NotificationStatus{Sent, Failed}, PartiallySent},
+		{"digested and failed", []NotificationStatus{Digested, Failed}, PartiallySent},
+		{"all failed", []NotificationStatus{Failed, Failed}, Failed},
+		{"failed and suppressed", []NotificationStatus{Failed, Suppressed}, Failed},
+		{"failed and expired", []NotificationStatus{Failed, Expired}, Failed},
+		{"one pending", []NotificationStatus{Sent, Pending}, Pending},
+		{"one deferred", []NotificationStatus{Failed, Deferred}, Pending},
+		{"one batched", []NotificationStatus{Sent, Batched}, Pending},
+		{"all suppressed", []NotificationStatus{Suppressed, Suppressed}, Suppressed},
+		{"expired and suppressed", []NotificationStatus{Expired, Suppressed}, Expired},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			children := make([]*Notification, len(test.children))
+			for i, status := range test.children {
+				children[i] = &Notification{DeliveryStatus: DeliveryStatus{NotificationStatus: status}}
+			}
+			if got := AggregateStatus(children); got != test.want {
+				t.Errorf("AggregateStatus(%v) = %s, want %s", test.children, got, test.want)
+			}
+		})
+	}
+}
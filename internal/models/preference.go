@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultCategory holds the preference applied to categories, and to
+// uncategorised notifications, the user has no preference of their own for.
+const DefaultCategory = "default"
+
+// Preference is a user's choice for one notification category. OptOut turns
+// the whole category off; otherwise Channels turns single channels off, and
+// channels it does not list stay on. QuietHours defer non-urgent delivery.
+// Digest picks immediate or digest delivery for email of a digest category.
+// Locked categories are set by the service and cannot be changed by the
+// user.
+type Preference struct {
+	ID         primitive.ObjectID        `bson:"_id,omitempty" json:"-"`
+	UserID     uuid.UUID                 `bson:"userId" json:"userId"`
+	Category   string                    `bson:"category" json:"category"`
+	OptOut     bool                      `bson:"optOut" json:"optOut"`
+	Channels   map[NotificationType]bool `bson:"channels,omitempty" json:"channels,omitempty"`
+	QuietHours *QuietHours               `bson:"quietHours,omitempty" json:"quietHours,omitempty"`
+	Digest     DigestFrequency           `bson:"digest,omitempty" json:"digest,omitempty"`
+	Locked     bool                      `bson:"-" json:"locked,omitempty"`
+	CreatedAt  *time.Time                `bson:"createdAt,omitempty" json:"createdAt,omitempty"`
+	UpdatedAt  *time.Time                `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+func (preference *Preference) Allows(notificationType NotificationType) bool {
+	if preference.OptOut {
+		return false
+	}
+	enabled, listed := preference.Channels[notificationType]
+	return !listed || enabled
+}
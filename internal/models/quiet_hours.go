@@ -0,0 +1,84 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuietHours is a daily window, given as "HH:MM" in an IANA timezone (UTC when
+// empty), during which notifications are deferred to the window's end. A window whose end
+// is before its start spans midnight. Without Channels it covers all of them.
+type QuietHours struct {
+	Start    string             `bson:"start" json:"start"`
+	End      string             `bson:"end" json:"end"`
+	Timezone string             `bson:"timezone" json:"timezone"`
+	Channels []NotificationType `bson:"channels,omitempty" json:"channels,omitempty"`
+}
+
+func (quietHours *QuietHours) Validate() error {
+	if _, err := parseTimeOfDay(quietHours.Start); err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+	if _, err := parseTimeOfDay(quietHours.End); err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+	if _, err := time.LoadLocation(quietHours.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	return nil
+}
+
+func (quietHours *QuietHours) Covers(notificationType NotificationType) bool {
+	if len(quietHours.Channels) == 0 {
+		return true
+	}
+	for _, channel := range quietHours.Channels {
+		if channel == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// WindowEnd returns the end of the window now falls in, or false when now is
+// outside the window.
+func (quietHours *QuietHours) WindowEnd(now time.Time) (time.Time, bool, error) {
+	start, err := parseTimeOfDay(quietHours.Start)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	end, err := parseTimeOfDay(quietHours.End)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	location, err := time.LoadLocation(quietHours.Timezone)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	var days int
+	switch {
+	case start == end:
+		return time.Time{}, false, nil
+	case start < end && minute >= start && minute < end:
+	case start > end && minute >= start:
+		days = 1
+	case start > end && minute < end:
+	default:
+		return time.Time{}, false, nil
+	}
+
+	year, month, day := local.Date()
+	return time.Date(year, month, day+days, end/60, end%60, 0, 0, location), true, nil
+}
+
+// parseTimeOfDay turns "HH:MM" into minutes since midnight.
+func parseTimeOfDay(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
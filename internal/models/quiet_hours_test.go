@@ -0,0 +1,122 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursWindowEnd(t *testing.T) {
+	utc := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("parse %s: %v", value, err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name       string
+		quietHours QuietHours
+		now        time.Time
+		wantInside bool
+		wantEnd    time.Time
+	}{
+		{
+			name:       "inside a same-day window",
+			quietHours: QuietHours{Start: "12:00", End: "14:00"},
+			now:        utc("2026-10-18T13:15:00Z"),
+			wantInside: true,
+			wantEnd:    utc("2026-10-18T14:00:00Z"),
+		},
+		{
+			name:       "at the end of a same-day window",
+			quietHours: QuietHours{Start: "12:00", End: "14:00"},
+			now:        utc("2026-10-18T14:00:00Z"),
+		},
+		{
+			name:       "spanning midnight, before midnight",
+			quietHours: QuietHours{Start: "22:00", End: "07:00"},
+			now:        utc("2026-10-18T23:30:00Z"),
+			wantInside: true,
+			wantEnd:    utc("2026-10-19T07:00:00Z"),
+		},
+		{
+			name:       "spanning midnight, after midnight",
+			quietHours: QuietHours{Start: "22:00", End: "07:00"},
+			now:        utc("2026-10-19T03:00:00Z"),
+			wantInside: true,
+			wantEnd:    utc("2026-10-19T07:00:00Z"),
+		},
+		{
+			name:       "spanning midnight, outside",
+			quietHours: QuietHours{Start: "22:00", End: "07:00"},
+			now:        utc("2026-10-18T12:00:00Z"),
+		},
+		{
+			name:       "start equals end is an empty window",
+			quietHours: QuietHours{Start: "09:00", End: "09:00"},
+			now:        utc("2026-10-18T09:00:00Z"),
+		},
+		{
+			name:       "in the user's timezone",
+			quietHours: QuietHours{Start: "22:00", End: "07:00", Timezone: "Europe/Berlin"},
+			// 22:30 in Berlin (CEST)
+			now:        utc("2026-06-01T20:30:00Z"),
+			wantInside: true,
+			wantEnd:    utc("2026-06-02T05:00:00Z"),
+		},
+		{
+			name:       "window ending after the spring forward",
+			quietHours: QuietHours{Start: "22:00", End: "07:00", Timezone: "America/New_York"},
+			// 23:00 EST on March 7; clocks go forward at 02:00, so the
+			// night is an hour shorter
+			now:        utc("2026-03-08T04:00:00Z"),
+			wantInside: true,
+			wantEnd:    utc("2026-03-08T11:00:00Z"),
+		},
+		{
+			name:       "window ending after the fall back",
+			quietHours: QuietHours{Start: "22:00", End: "07:00", Timezone: "America/New_York"},
+			// 23:00 EDT on October 31; clocks go back at 02:00, so the
+			// night is an hour longer
+			now:        utc("2026-11-01T03:00:00Z"),
+			wantInside: true,
+			wantEnd:    utc("2026-11-01T12:00:00Z"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			end, inside, err := test.quietHours.WindowEnd(test.now)
+			if err != nil {
+				t.Fatalf("WindowEnd: %v", err)
+			}
+			if inside != test.wantInside {
+				t.Fatalf("inside = %v, want %v", inside, test.wantInside)
+			}
+			if inside && !end.Equal(test.wantEnd) {
+				t.Errorf("end = %s, want %s", end.UTC(), test.wantEnd)
+			}
+		})
+	}
+}
+
+func TestQuietHoursValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		quietHours QuietHours
+		wantErr    bool
+	}{
+		{"valid", QuietHours{Start: "22:00", End: "07:00", Timezone: "Europe/Berlin"}, false},
+		{"UTC by default", QuietHours{Start: "22:00", End: "07:00"}, false},
+		{"invalid start", QuietHours{Start: "25:00", End: "07:00"}, true},
+		{"invalid end", QuietHours{Start: "22:00", End: "7am"}, true},
+		{"unknown timezone", QuietHours{Start: "22:00", End: "07:00", Timezone: "Mars/Olympus"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.quietHours.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
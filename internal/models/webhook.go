@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook is an endpoint registered either for a single user or for a
+// tenant; exactly one of UserID and TenantID is set.
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    uuid.UUID          `bson:"userId" json:"-"`
+	TenantID  string             `bson:"tenantId" json:"-"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"secret,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
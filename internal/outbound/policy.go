@@ -0,0 +1,162 @@
+// Package outbound guards requests the service makes to URLs that callers
+// supply, such as attachment and webhook URLs, against reaching internal
+// hosts.
+package outbound
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbidden is wrapped into every error about a destination the policy
+// does not allow.
+var ErrForbidden = stderrors.New("destination not allowed")
+
+// Policy decides which destinations may be requested. The zero value allows
+// any public host over https.
+type Policy struct {
+	// AllowHTTP also permits plain http URLs.
+	AllowHTTP bool
+	// AllowedHosts restricts requests to these hosts; ".example.com" also
+	// matches its subdomains. Empty allows any host.
+	AllowedHosts []string
+	// AllowPrivate permits loopback, private and link-local addresses, for
+	// development and tests.
+	AllowPrivate bool
+}
+
+// CheckURL validates the scheme and host of a URL without resolving it.
+func (policy Policy) CheckURL(rawURL string) (*url.URL, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid URL: %v", ErrForbidden, err)
+	}
+	switch {
+	case parsed.Scheme == "https":
+	case parsed.Scheme == "http" && policy.AllowHTTP:
+	default:
+		return nil, fmt.Errorf("%w: scheme %q is not allowed", ErrForbidden, parsed.Scheme)
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "" {
+		return nil, fmt.Errorf("%w: URL has no host", ErrForbidden)
+	}
+	if parsed.User != nil {
+		return nil, fmt.Errorf("%w: URL must not carry credentials", ErrForbidden)
+	}
+	if !policy.hostAllowed(host) {
+		return nil, fmt.Errorf("%w: host %s is not allowed", ErrForbidden, host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return parsed, policy.CheckIP(ip)
+	}
+	if !policy.AllowPrivate && (host == "localhost" || strings.HasSuffix(host, ".localhost")) {
+		return nil, fmt.Errorf("%w: host %s is internal", ErrForbidden, host)
+	}
+	return parsed, nil
+}
+
+// CheckDestination validates a URL and every address its host resolves to.
+// The addresses may change before the request is made, so clients must still
+// dial through Transport.
+func (policy Policy) CheckDestination(ctx context.Context, rawURL string) (*url.URL, error) {
+	parsed, err := policy.CheckURL(rawURL)
+	if err != nil || policy.AllowPrivate || net.ParseIP(parsed.Hostname()) != nil {
+		return parsed, err
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve %s: %w", parsed.Hostname(), err)
+	}
+	for _, address := range addresses {
+		if err := policy.CheckIP(address.IP); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, nil
+}
+
+// CheckIP rejects loopback, private, link-local, multicast and unspecified
+// addresses unless the policy allows private destinations.
+func (policy Policy) CheckIP(ip net.IP) error {
+	if policy.AllowPrivate {
+		return nil
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: address %s is internal", ErrForbidden, ip)
+	}
+	return nil
+}
+
+// Carrier-grade NAT space, which net.IP.IsPrivate does not cover.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func (policy Policy) hostAllowed(host string) bool {
+	if len(policy.AllowedHosts) == 0 {
+		return true
+	}
+	for _, allowed := range policy.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, ".") && (host == allowed[1:] || strings.HasSuffix(host, allowed))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Transport checks the address of every connection it dials, after DNS
+// resolution, so a host cannot be rebound to an internal address between the
+// check and the request. It does not use a proxy, which would hide the
+// destination address.
+func (policy Policy) Transport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("%w: dialing unresolved address %s", ErrForbidden, address)
+			}
+			return policy.CheckIP(ip)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// Client returns an HTTP client that dials through Transport and applies
+// the policy to every redirect.
+func (policy Policy) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: policy.Transport(),
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return stderrors.New("stopped after 10 redirects")
+			}
+			_, err := policy.CheckURL(request.URL.String())
+			return err
+		},
+	}
+}
+
+// IsForbidden reports whether err is about a destination the policy rejects.
+func IsForbidden(err error) bool {
+	return stderrors.Is(err, ErrForbidden)
+}
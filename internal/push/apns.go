@@ -0,0 +1,200 @@
+package push
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"notificationservice/internal/errors"
+)
+
+const (
+	defaultAPNsEndpoint = "https://api.push.apple.com"
+	// APNs rejects provider tokens older than an hour and throttles
+	// refreshing them more often than every 20 minutes.
+	providerTokenLifetime = 50 * time.Minute
+)
+
+type APNsConfig struct {
+	Endpoint string
+	Topic    string
+	TeamID   string
+	KeyID    string
+	// PrivateKey is the PEM encoded .p8 signing key.
+	PrivateKey []byte
+	Timeout    time.Duration
+	TLSConfig  *tls.Config
+}
+
+// APNsProvider sends through the APNs HTTP/2 API with token based
+// authentication.
+type APNsProvider struct {
+	config APNsConfig
+	key    *ecdsa.PrivateKey
+	client *http.Client
+
+	mutex    sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+type apnsErrorResponse struct {
+	Reason string `json:"reason"`
+}
+
+func NewAPNsProvider(config APNsConfig) (*APNsProvider, error) {
+	if config.Endpoint == "" {
+		config.Endpoint = defaultAPNsEndpoint
+	}
+	key, err := parsePrivateKey(config.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &APNsProvider{
+		config: config,
+		key:    key,
+		client: newHTTP2Client(config.Timeout, config.TLSConfig),
+	}, nil
+}
+
+func (provider *APNsProvider) Send(token string, message *Message) error {
+	payload, err := json.Marshal(apnsPayload(message))
+	if err != nil {
+		return errors.NewProcessingError("failed to serialize push notification", err)
+	}
+	providerToken, err := provider.providerToken()
+	if err != nil {
+		return errors.NewProcessingError("failed to sign APNs provider token", err)
+	}
+
+	endpoint := strings.TrimSuffix(provider.config.Endpoint, "/") + "/3/device/" + url.PathEscape(token)
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return errors.NewProcessingError("failed to build APNs request", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "bearer "+providerToken)
+	request.Header.Set("Apns-Topic", provider.config.Topic)
+	request.Header.Set("Apns-Push-Type", "alert")
+	request.Header.Set("Apns-Priority", "10")
+
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return errors.NewRetriableError("APNs request failed", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusOK {
+		io.Copy(io.Discard, response.Body)
+		return nil
+	}
+	return provider.classifyResponse(response)
+}
+
+func (provider *APNsProvider) classifyResponse(response *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodyLen))
+	var decoded apnsErrorResponse
+	json.Unmarshal(body, &decoded)
+
+	description := fmt.Sprintf("APNs returned %s", response.Status)
+	if decoded.Reason != "" {
+		description += fmt.Sprintf(" (%s)", decoded.Reason)
+	}
+
+	switch {
+	case response.StatusCode == http.StatusGone ||
+		decoded.Reason == "BadDeviceToken" ||
+		decoded.Reason == "DeviceTokenNotForTopic":
+		return errors.NewValidationError(description, ErrInvalidToken)
+	case decoded.Reason == "ExpiredProviderToken":
+		provider.resetProviderToken()
+		return errors.NewRetriableError(description, nil)
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return errors.NewRetriableError(description, nil)
+	case response.StatusCode == http.StatusForbidden:
+		return errors.NewProcessingError(description, nil)
+	}
+	return errors.NewValidationError(description, nil)
+}
+
+func apnsPayload(message *Message) map[string]interface{} {
+	aps := map[string]interface{}{
+		"alert": map[string]string{"title": message.Title, "body": message.Body},
+	}
+	if message.Badge != nil {
+		aps["badge"] = *message.Badge
+	}
+	if message.Sound != "" {
+		aps["sound"] = message.Sound
+	}
+
+	payload := map[string]interface{}{}
+	for key, value := range message.Data {
+		payload[key] = value
+	}
+	payload["aps"] = aps
+	return payload
+}
+
+func (provider *APNsProvider) providerToken() (string, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.token != "" && time.Since(provider.issuedAt) < providerTokenLifetime {
+		return provider.token, nil
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": provider.config.KeyID})
+	claims, _ := json.Marshal(map[string]interface{}{"iss": provider.config.TeamID, "iat": now.Unix()})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, provider.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS wants the raw 64 byte r||s form rather than ASN.1
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	provider.token = unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	provider.issuedAt = now
+	return provider.token, nil
+}
+
+func (provider *APNsProvider) resetProviderToken() {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.token = ""
+}
+
+func parsePrivateKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("APNs key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APNs key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("APNs key must be an ECDSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,207 @@
+package push
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"notificationservice/internal/errors"
+)
+
+// newHTTP2Server starts a TLS server speaking HTTP/2, as APNs and FCM do, and
+// returns a client TLS configuration that trusts it.
+func newHTTP2Server(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *tls.Config) {
+	t.Helper()
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	return server, &tls.Config{RootCAs: roots}
+}
+
+func newAPNsKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// verifyProviderToken checks the ES256 signature of an APNs provider token
+// and returns its header and claims. It runs in server handlers, so it
+// reports rather than stops the test.
+func verifyProviderToken(t *testing.T, token string, key *ecdsa.PublicKey) (header, claims map[string]interface{}) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Errorf("provider token has %d parts, want 3", len(parts))
+		return nil, nil
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		t.Errorf("signature is not a raw 64 byte r||s value: %v", err)
+		return nil, nil
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		t.Error("provider token signature does not verify")
+	}
+
+	for i, target := range []*map[string]interface{}{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err == nil {
+			err = json.Unmarshal(data, target)
+		}
+		if err != nil {
+			t.Errorf("decode token part %d: %v", i, err)
+		}
+	}
+	return header, claims
+}
+
+func TestAPNsProviderSend(t *testing.T) {
+	key, pemKey := newAPNsKey(t)
+	var tokens []string
+	server, tlsConfig := newHTTP2Server(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("protocol = %s, want HTTP/2", r.Proto)
+		}
+		if r.URL.Path != "/3/device/device-token" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		for header, want := range map[string]string{
+			"Apns-Topic":     "com.example.app",
+			"Apns-Push-Type": "alert",
+			"Apns-Priority":  "10",
+			"Content-Type":   "application/json",
+		} {
+			if got := r.Header.Get(header); got != want {
+				t.Errorf("%s = %q, want %q", header, got, want)
+			}
+		}
+
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "bearer ")
+		if !found {
+			t.Errorf("Authorization = %q, want a bearer token", r.Header.Get("Authorization"))
+			return
+		}
+		tokens = append(tokens, token)
+		header, claims := verifyProviderToken(t, token, &key.PublicKey)
+		if header["alg"] != "ES256" || header["kid"] != "KEY123" {
+			t.Errorf("token header = %v", header)
+		}
+		if claims["iss"] != "TEAM123" || claims["iat"] == nil {
+			t.Errorf("token claims = %v", claims)
+		}
+
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+			return
+		}
+		aps, _ := payload["aps"].(map[string]interface{})
+		alert, _ := aps["alert"].(map[string]interface{})
+		if alert["title"] != "Title" || alert["body"] != "Body" {
+			t.Errorf("alert = %v", alert)
+		}
+		if aps["badge"] != float64(3) || aps["sound"] != "default" {
+			t.Errorf("aps = %v", aps)
+		}
+		if payload["orderId"] != "42" {
+			t.Errorf("custom data = %v", payload)
+		}
+	})
+
+	provider, err := NewAPNsProvider(APNsConfig{
+		Endpoint:   server.URL,
+		Topic:      "com.example.app",
+		TeamID:     "TEAM123",
+		KeyID:      "KEY123",
+		PrivateKey: pemKey,
+		TLSConfig:  tlsConfig,
+	})
+	if err != nil {
+		t.Fatalf("NewAPNsProvider: %v", err)
+	}
+	badge := 3
+	message := &Message{Title: "Title", Body: "Body", Data: map[string]string{"orderId": "42"}, Badge: &badge, Sound: "default"}
+	for i := 0; i < 2; i++ {
+		if err := provider.Send("device-token", message); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	// The provider token is reused rather than signed for every request
+	if len(tokens) != 2 || tokens[0] != tokens[1] {
+		t.Errorf("provider token was not reused")
+	}
+}
+
+func TestAPNsProviderErrorMapping(t *testing.T) {
+	_, pemKey := newAPNsKey(t)
+	tests := []struct {
+		name         string
+		status       int
+		reason       string
+		wantType     errors.ErrorType
+		wantInvalid  bool
+		wantNewToken bool
+	}{
+		{"unregistered", http.StatusGone, "Unregistered", errors.ValidationError, true, false},
+		{"bad device token", http.StatusBadRequest, "BadDeviceToken", errors.ValidationError, true, false},
+		{"wrong topic", http.StatusBadRequest, "DeviceTokenNotForTopic", errors.ValidationError, true, false},
+		{"bad payload", http.StatusBadRequest, "PayloadTooLarge", errors.ValidationError, false, false},
+		{"expired provider token", http.StatusForbidden, "ExpiredProviderToken", errors.RetriableError, false, true},
+		{"invalid provider token", http.StatusForbidden, "InvalidProviderToken", errors.ProcessingError, false, false},
+		{"throttled", http.StatusTooManyRequests, "TooManyRequests", errors.RetriableError, false, false},
+		{"unavailable", http.StatusServiceUnavailable, "ServiceUnavailable", errors.RetriableError, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, tlsConfig := newHTTP2Server(t, func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				w.WriteHeader(test.status)
+				json.NewEncoder(w).Encode(apnsErrorResponse{Reason: test.reason})
+			})
+			provider, err := NewAPNsProvider(APNsConfig{Endpoint: server.URL, PrivateKey: pemKey, TLSConfig: tlsConfig})
+			if err != nil {
+				t.Fatalf("NewAPNsProvider: %v", err)
+			}
+
+			err = provider.Send("device-token", &Message{Title: "Title"})
+			if got := errors.GetErrorType(err); got != test.wantType {
+				t.Errorf("error type = %q, want %q (%v)", got, test.wantType, err)
+			}
+			if IsInvalidToken(err) != test.wantInvalid {
+				t.Errorf("invalid token = %v, want %v", IsInvalidToken(err), test.wantInvalid)
+			}
+
+			// Tokens are only signed at second granularity, so compare the
+			// cached token rather than the header of a second request
+			cached := provider.token
+			if (cached == "") != test.wantNewToken {
+				t.Errorf("provider token reset = %v, want %v", cached == "", test.wantNewToken)
+			}
+		})
+	}
+}
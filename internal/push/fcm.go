@@ -0,0 +1,185 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"notificationservice/internal/errors"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	defaultFCMEndpoint = "https://fcm.googleapis.com"
+	fcmScope           = "https://www.googleapis.com/auth/firebase.messaging"
+)
+
+type FCMConfig struct {
+	Endpoint string
+	// ProjectID defaults to the project of the service account.
+	ProjectID string
+	// Credentials is the service account JSON key.
+	Credentials []byte
+	// TokenSource replaces Credentials when set, e.g. in tests.
+	TokenSource oauth2.TokenSource
+	Timeout     time.Duration
+	TLSConfig   *tls.Config
+}
+
+// FCMProvider sends through the FCM HTTP v1 API, authenticating with OAuth2
+// access tokens of a service account that are refreshed before they expire.
+type FCMProvider struct {
+	config FCMConfig
+	tokens oauth2.TokenSource
+	client *http.Client
+}
+
+type fcmRequest struct {
+	Message fcmMessage `json:"message"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      *fcmAndroid       `json:"android,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type fcmAndroid struct {
+	Notification fcmAndroidNotification `json:"notification"`
+}
+
+type fcmAndroidNotification struct {
+	Sound             string `json:"sound,omitempty"`
+	NotificationCount *int   `json:"notification_count,omitempty"`
+}
+
+type fcmErrorResponse struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func NewFCMProvider(config FCMConfig) (*FCMProvider, error) {
+	if config.Endpoint == "" {
+		config.Endpoint = defaultFCMEndpoint
+	}
+	tokens := config.TokenSource
+	if tokens == nil {
+		credentials, err := google.CredentialsFromJSON(context.Background(), config.Credentials, fcmScope)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FCM service account: %w", err)
+		}
+		if config.ProjectID == "" {
+			config.ProjectID = credentials.ProjectID
+		}
+		tokens = credentials.TokenSource
+	}
+	if config.ProjectID == "" {
+		return nil, fmt.Errorf("FCM project ID is not configured")
+	}
+	return &FCMProvider{
+		config: config,
+		tokens: oauth2.ReuseTokenSource(nil, tokens),
+		client: newHTTP2Client(config.Timeout, config.TLSConfig),
+	}, nil
+}
+
+func (provider *FCMProvider) Send(token string, message *Message) error {
+	request := fcmRequest{Message: fcmMessage{
+		Token:        token,
+		Notification: fcmNotification{Title: message.Title, Body: message.Body},
+		Data:         message.Data,
+	}}
+	if message.Sound != "" || message.Badge != nil {
+		request.Message.Android = &fcmAndroid{Notification: fcmAndroidNotification{
+			Sound:             message.Sound,
+			NotificationCount: message.Badge,
+		}}
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return errors.NewProcessingError("failed to serialize push notification", err)
+	}
+
+	endpoint := strings.TrimSuffix(provider.config.Endpoint, "/") +
+		"/v1/projects/" + url.PathEscape(provider.config.ProjectID) + "/messages:send"
+	httpRequest, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return errors.NewProcessingError("failed to build FCM request", err)
+	}
+	accessToken, err := provider.tokens.Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if stderrors.As(err, &retrieveErr) && retrieveErr.Response != nil &&
+			retrieveErr.Response.StatusCode < 500 && retrieveErr.Response.StatusCode != http.StatusTooManyRequests {
+			return errors.NewProcessingError("FCM service account was rejected", err)
+		}
+		return errors.NewRetriableError("failed to obtain FCM access token", err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	accessToken.SetAuthHeader(httpRequest)
+
+	response, err := provider.client.Do(httpRequest)
+	if err != nil {
+		return errors.NewRetriableError("FCM request failed", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusOK {
+		io.Copy(io.Discard, response.Body)
+		return nil
+	}
+	return classifyFCMResponse(response)
+}
+
+func classifyFCMResponse(response *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodyLen))
+	var decoded fcmErrorResponse
+	json.Unmarshal(body, &decoded)
+
+	reason := decoded.Error.Status
+	for _, detail := range decoded.Error.Details {
+		if detail.ErrorCode != "" {
+			reason = detail.ErrorCode
+		}
+	}
+	description := fmt.Sprintf("FCM returned %s", response.Status)
+	if reason != "" {
+		description += fmt.Sprintf(" (%s)", reason)
+	}
+	if decoded.Error.Message != "" {
+		description += ": " + decoded.Error.Message
+	}
+
+	switch {
+	// A 404 alone may mean a wrong project or endpoint; only UNREGISTERED
+	// says the token itself is gone
+	case reason == "UNREGISTERED":
+		return errors.NewValidationError(description, ErrInvalidToken)
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return errors.NewRetriableError(description, nil)
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		return errors.NewProcessingError(description, nil)
+	}
+	return errors.NewValidationError(description, nil)
+}
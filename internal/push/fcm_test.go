@@ -0,0 +1,198 @@
+package push
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"notificationservice/internal/errors"
+
+	"golang.org/x/oauth2"
+)
+
+// serviceAccount returns a service account key whose tokens are issued by
+// the given token endpoint.
+func serviceAccount(t *testing.T, tokenURL string) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "account-project",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "sender@account-project.iam.gserviceaccount.com",
+		"token_uri":      tokenURL,
+	})
+	if err != nil {
+		t.Fatalf("marshal service account: %v", err)
+	}
+	return data
+}
+
+func TestFCMProviderSend(t *testing.T) {
+	var received fcmRequest
+	server, tlsConfig := newHTTP2Server(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/my-project/messages:send" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer access-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		fmt.Fprint(w, `{"name": "projects/my-project/messages/1"}`)
+	})
+
+	provider, err := NewFCMProvider(FCMConfig{
+		Endpoint:    server.URL,
+		ProjectID:   "my-project",
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access-token"}),
+		TLSConfig:   tlsConfig,
+	})
+	if err != nil {
+		t.Fatalf("NewFCMProvider: %v", err)
+	}
+	badge := 2
+	err = provider.Send("device-token", &Message{Title: "Title", Body: "Body", Data: map[string]string{"orderId": "42"}, Badge: &badge, Sound: "default"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	message := received.Message
+	if message.Token != "device-token" || message.Notification.Title != "Title" || message.Notification.Body != "Body" {
+		t.Errorf("message = %+v", message)
+	}
+	if message.Data["orderId"] != "42" {
+		t.Errorf("data = %v", message.Data)
+	}
+	if message.Android == nil || message.Android.Notification.Sound != "default" ||
+		message.Android.Notification.NotificationCount == nil || *message.Android.Notification.NotificationCount != 2 {
+		t.Errorf("android = %+v", message.Android)
+	}
+}
+
+func TestFCMProviderRefreshesServiceAccountTokens(t *testing.T) {
+	var issued int
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse token request: %v", err)
+		}
+		if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.Form.Get("assertion") == "" {
+			t.Errorf("token request = %v", r.Form)
+		}
+		issued++
+		// The first token is already within the refresh margin
+		expiresIn := 3600
+		if issued == 1 {
+			expiresIn = 1
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": %d}`, issued, expiresIn)
+	}))
+	defer tokenServer.Close()
+
+	var authorizations []string
+	server, tlsConfig := newHTTP2Server(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.URL.Path != "/v1/projects/account-project/messages:send" {
+			t.Errorf("path = %s, want the service account's project", r.URL.Path)
+		}
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+	})
+
+	provider, err := NewFCMProvider(FCMConfig{
+		Endpoint:    server.URL,
+		Credentials: serviceAccount(t, tokenServer.URL),
+		TLSConfig:   tlsConfig,
+	})
+	if err != nil {
+		t.Fatalf("NewFCMProvider: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := provider.Send("device-token", &Message{Title: "Title"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	want := []string{"Bearer token-1", "Bearer token-2", "Bearer token-2"}
+	if fmt.Sprint(authorizations) != fmt.Sprint(want) {
+		t.Errorf("authorizations = %v, want %v", authorizations, want)
+	}
+}
+
+func TestFCMProviderRejectsInvalidServiceAccount(t *testing.T) {
+	if _, err := NewFCMProvider(FCMConfig{Credentials: []byte(`{"type": "service_account"`)}); err == nil {
+		t.Error("NewFCMProvider accepted malformed credentials")
+	}
+}
+
+func TestFCMProviderErrorMapping(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantType    errors.ErrorType
+		wantInvalid bool
+	}{
+		{
+			"unregistered token", http.StatusNotFound,
+			`{"error": {"status": "NOT_FOUND", "details": [{"errorCode": "UNREGISTERED"}]}}`,
+			errors.ValidationError, true,
+		},
+		{"bare not found", http.StatusNotFound, `Not Found`, errors.ValidationError, false},
+		{
+			"sender mismatch", http.StatusForbidden,
+			`{"error": {"status": "PERMISSION_DENIED", "details": [{"errorCode": "SENDER_ID_MISMATCH"}]}}`,
+			errors.ProcessingError, false,
+		},
+		{
+			"invalid argument", http.StatusBadRequest,
+			`{"error": {"status": "INVALID_ARGUMENT", "details": [{"errorCode": "INVALID_ARGUMENT"}]}}`,
+			errors.ValidationError, false,
+		},
+		{"quota exceeded", http.StatusTooManyRequests, `{"error": {"status": "RESOURCE_EXHAUSTED"}}`, errors.RetriableError, false},
+		{"unavailable", http.StatusServiceUnavailable, `{"error": {"status": "UNAVAILABLE"}}`, errors.RetriableError, false},
+		{"bad credentials", http.StatusUnauthorized, `{"error": {"status": "UNAUTHENTICATED"}}`, errors.ProcessingError, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, tlsConfig := newHTTP2Server(t, func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, test.body)
+			})
+			provider, err := NewFCMProvider(FCMConfig{
+				Endpoint:    server.URL,
+				ProjectID:   "my-project",
+				TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access-token"}),
+				TLSConfig:   tlsConfig,
+			})
+			if err != nil {
+				t.Fatalf("NewFCMProvider: %v", err)
+			}
+
+			err = provider.Send("device-token", &Message{Title: "Title"})
+			if got := errors.GetErrorType(err); got != test.wantType {
+				t.Errorf("error type = %q, want %q (%v)", got, test.wantType, err)
+			}
+			if IsInvalidToken(err) != test.wantInvalid {
+				t.Errorf("invalid token = %v, want %v", IsInvalidToken(err), test.wantInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package push
+
+import (
+	"crypto/tls"
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"notificationservice/internal/errors"
+)
+
+const (
+	defaultTimeout  = 10 * time.Second
+	maxErrorBodyLen = 4096
+)
+
+// ErrInvalidToken is wrapped into the error a provider returns when the
+// device token is no longer accepted and should be removed.
+var ErrInvalidToken = stderrors.New("device token is no longer valid")
+
+type Provider interface {
+	Send(token string, message *Message) error
+}
+
+type Message struct {
+	Title string
+	Body  string
+	Data  map[string]string
+	Badge *int
+	Sound string
+}
+
+func IsInvalidToken(err error) bool {
+	if notifErr, ok := err.(*errors.NotificationError); ok {
+		return notifErr.OriginalErr == ErrInvalidToken
+	}
+	return false
+}
+
+// newHTTP2Client forces HTTP/2, which APNs requires, even when a custom TLS
+// configuration is supplied (as tests do for a local stub).
+func newHTTP2Client(timeout time.Duration, tlsConfig *tls.Config) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
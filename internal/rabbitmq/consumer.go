@@ -1,12 +1,15 @@
 package rabbitmq
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"notificationservice/internal/errors"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -15,13 +18,25 @@ type MessageHandler interface {
 }
 
 type Consumer struct {
-    uri              string
-    queueName        string
-    exchangeName     string
-    routingKey       string
-    deadLetterConfig *DeadLetterConfig
-    connection       *amqp.Connection
-    channel          *amqp.Channel
+    uri               string
+    queueName         string
+    exchangeName      string
+    routingKey        string
+    consumerTag       string
+    deadLetterConfig  *DeadLetterConfig
+    retryConfig       RetryConfig
+    reconnectConfig   ReconnectConfig
+    onConnectionEvent func(ConnectionEvent)
+    prefetchCount     int
+    workerCount       int
+    jobs              chan amqp.Delivery
+    workers           sync.WaitGroup
+    handler           MessageHandler
+    mutex             sync.RWMutex
+    connection        *amqp.Connection
+    channel           *amqp.Channel
+    done              chan struct{}
+    closeOnce         sync.Once
 }
 
 type DeadLetterConfig struct {
@@ -31,33 +46,104 @@ type DeadLetterConfig struct {
 }
 
 type ConsumerOptions struct {
-    DeadLetterConfig *DeadLetterConfig
+    DeadLetterConfig  *DeadLetterConfig
+    RetryConfig       *RetryConfig
+    ReconnectConfig   *ReconnectConfig
+    OnConnectionEvent func(ConnectionEvent)
+    // PrefetchCount caps unacknowledged deliveries the broker pushes to us;
+    // defaults to WorkerCount so every worker has one message ready
+    PrefetchCount int
+    WorkerCount   int
 }
 
+const defaultWorkerCount = 10
+
 func NewConsumer(uri, queueName, exchangeName string, routingKey string, options *ConsumerOptions) *Consumer {
+    reconnectConfig := DefaultReconnectConfig()
+    if options.ReconnectConfig != nil {
+        reconnectConfig = *options.ReconnectConfig
+    }
+
+    retryConfig := DefaultRetryConfig()
+    if options.RetryConfig != nil {
+        if len(options.RetryConfig.Delays) > 0 {
+            retryConfig.Delays = options.RetryConfig.Delays
+        }
+        retryConfig.MaxAttempts = options.RetryConfig.MaxAttempts
+    }
+    if retryConfig.MaxAttempts <= 0 {
+        retryConfig.MaxAttempts = len(retryConfig.Delays)
+    }
+
+    workerCount := options.WorkerCount
+    if workerCount <= 0 {
+        workerCount = defaultWorkerCount
+    }
+    prefetchCount := options.PrefetchCount
+    if prefetchCount <= 0 {
+        prefetchCount = workerCount
+    }
+
     return &Consumer{
         uri:          uri,
         queueName:    queueName,
         exchangeName: exchangeName,
         routingKey: routingKey,
+        consumerTag:       fmt.Sprintf("%s-%s", queueName, uuid.NewString()),
         deadLetterConfig: options.DeadLetterConfig,
+        retryConfig:       retryConfig,
+        reconnectConfig:   reconnectConfig,
+        onConnectionEvent: options.OnConnectionEvent,
+        prefetchCount:     prefetchCount,
+        workerCount:       workerCount,
+        jobs:              make(chan amqp.Delivery),
+        done:              make(chan struct{}),
     }
 }
 
 func (consumer *Consumer) Connect() error {
+    connection, channel, err := consumer.dial()
+    if err != nil {
+        return err
+    }
+    consumer.setConnection(connection, channel)
+    return nil
+}
+
+func (consumer *Consumer) dial() (*amqp.Connection, *amqp.Channel, error) {
     connection, err := amqp.Dial(consumer.uri)
     if err != nil {
-        return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+        return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
     }
-    consumer.connection = connection
 
     channel, err := connection.Channel()
     if err != nil {
-        return fmt.Errorf("failed to open channel: %w", err)
+        connection.Close()
+        return nil, nil, fmt.Errorf("failed to open channel: %w", err)
+    }
+
+    if err := channel.Qos(consumer.prefetchCount, 0, false); err != nil {
+        connection.Close()
+        return nil, nil, fmt.Errorf("failed to set QoS: %w", err)
+    }
+
+    // Retried and dead-lettered messages are acked only once the broker has
+    // confirmed their copy
+    if err := channel.Confirm(false); err != nil {
+        connection.Close()
+        return nil, nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+    }
+
+    if err := consumer.declareTopology(channel); err != nil {
+        connection.Close()
+        return nil, nil, err
     }
-    consumer.channel = channel
 
-    err = consumer.channel.ExchangeDeclare(
+    return connection, channel, nil
+}
+
+func (consumer *Consumer) declareTopology(channel *amqp.Channel) error {
+    err := channel.ExchangeDeclare(
         consumer.exchangeName, // name
         "direct",       // type
         true,           // durable
@@ -71,7 +157,7 @@ func (consumer *Consumer) Connect() error {
     }
 
     if consumer.deadLetterConfig != nil {
-        err = consumer.setupDeadLetterExchange()
+        err = consumer.setupDeadLetterExchange(channel)
         if err != nil {
             return err
         }
@@ -83,7 +169,7 @@ func (consumer *Consumer) Connect() error {
         args["x-dead-letter-routing-key"] = consumer.deadLetterConfig.RoutingKey
     }
 
-    queue, err := consumer.channel.QueueDeclare(
+    queue, err := channel.QueueDeclare(
         consumer.queueName, // name
         true,        // durable
         false,       // auto-delete
@@ -95,7 +181,7 @@ func (consumer *Consumer) Connect() error {
         return fmt.Errorf("failed to declare queue: %w", err)
     }
 
-    err = consumer.channel.QueueBind(
+    err = channel.QueueBind(
         queue.Name,
         consumer.routingKey,
         consumer.exchangeName,
@@ -106,11 +192,11 @@ func (consumer *Consumer) Connect() error {
         return fmt.Errorf("failed to bind queue: %w", err)
     }
 
-    return nil
+    return consumer.declareRetryQueues(channel)
 }
 
-func (consumer *Consumer) setupDeadLetterExchange() error {
-    err := consumer.channel.ExchangeDeclare(
+func (consumer *Consumer) setupDeadLetterExchange(channel *amqp.Channel) error {
+    err := channel.ExchangeDeclare(
         consumer.deadLetterConfig.ExchangeName, // name
         "direct",                        // type
         true,                            // durable
@@ -123,7 +209,7 @@ func (consumer *Consumer) setupDeadLetterExchange() error {
         return fmt.Errorf("failed to declare dead letter exchange: %w", err)
     }
 
-    _, err = consumer.channel.QueueDeclare(
+    _, err = channel.QueueDeclare(
         consumer.deadLetterConfig.QueueName, // name
         true,                         // durable
         false,                        // auto-delete
@@ -135,7 +221,7 @@ func (consumer *Consumer) setupDeadLetterExchange() error {
         return fmt.Errorf("failed to declare dead letter queue: %w", err)
     }
 
-    err = consumer.channel.QueueBind(
+    err = channel.QueueBind(
         consumer.deadLetterConfig.QueueName,
         consumer.deadLetterConfig.RoutingKey,
         consumer.deadLetterConfig.ExchangeName,
@@ -150,13 +236,31 @@ func (consumer *Consumer) setupDeadLetterExchange() error {
 }
 
 func (c *Consumer) Start(handler MessageHandler) error {
-    if c.channel == nil {
+    channel := c.currentChannel()
+    if channel == nil {
         return fmt.Errorf("channel not initialized, call Connect() first")
     }
+    c.handler = handler
+
+    deliveries, err := c.consume(channel)
+    if err != nil {
+        return err
+    }
+
+    for i := 0; i < c.workerCount; i++ {
+        c.workers.Add(1)
+        go c.work()
+    }
+    go c.supervise(deliveries)
+
+    log.Printf("RabbitMQ consumer started successfully with %d workers, prefetch %d", c.workerCount, c.prefetchCount)
+    return nil
+}
 
-    msgs, err := c.channel.Consume(
-        c.queueName, // queue
-        "",          // consumer
+func (c *Consumer) consume(channel *amqp.Channel) (<-chan amqp.Delivery, error) {
+    msgs, err := channel.Consume(
+        c.queueName,   // queue
+        c.consumerTag, // consumer
         false,       // auto-ack
         false,       // exclusive
         true,        // no-local
@@ -164,88 +268,173 @@ func (c *Consumer) Start(handler MessageHandler) error {
         nil,         // args
     )
     if err != nil {
-        return fmt.Errorf("failed to register consumer: %w", err)
-    }
-
-    for msg := range msgs {
-        go func(msg amqp.Delivery) {
-            log.Printf("Received message: %s", string(msg.Body))
-            err := handler.ProcessMessage(msg.Body)
-            if err != nil {
-                log.Printf("Error processing message: %v", err)
-                
-                if errors.IsValidationError(err) {
-                    log.Printf("Validation error detected, sending to dead letter queue")
-                    
-                    errorDesc := errors.GetErrorDescription(err)
-                    c.moveToDeadLetter(msg.Body, string(errors.ValidationError), errorDesc)
-                    
-                    msg.Ack(false)
-                } else if errors.IsRetriableError(err) {
-                    log.Printf("Retriable error detected, requeueing message")
-                    msg.Nack(false, true)
-                } else {
-                    log.Printf("Processing error detected, sending to dead letter queue")
-                    
-                    errorDesc := errors.GetErrorDescription(err)
-                    c.moveToDeadLetter(msg.Body, string(errors.ProcessingError), errorDesc)
-                    
-                    msg.Ack(false)
-                }
-            } else {
-                log.Printf("Message processed successfully")
-                msg.Ack(false)
-            }
-        }(msg)
-    }
-
-    log.Println("RabbitMQ consumer started successfully")
-    return nil
+        return nil, fmt.Errorf("failed to register consumer: %w", err)
+    }
+    return msgs, nil
+}
+
+func (c *Consumer) work() {
+    defer c.workers.Done()
+
+    for {
+        select {
+        case msg := <-c.jobs:
+            c.handleDelivery(msg)
+        case <-c.done:
+            return
+        }
+    }
+}
+
+func (c *Consumer) handleDelivery(msg amqp.Delivery) {
+    log.Printf("Received message: %s", string(msg.Body))
+    err := c.handler.ProcessMessage(msg.Body)
+    if err != nil {
+        log.Printf("Error processing message: %v", err)
+        
+        if errors.IsValidationError(err) {
+            log.Printf("Validation error detected, sending to dead letter queue")
+            
+            errorDesc := errors.GetErrorDescription(err)
+            c.deadLetter(msg, string(errors.ValidationError), errorDesc)
+        } else if errors.IsRetriableError(err) {
+            c.retryLater(msg, err)
+        } else {
+            log.Printf("Processing error detected, sending to dead letter queue")
+            
+            errorDesc := errors.GetErrorDescription(err)
+            c.deadLetter(msg, string(errors.ProcessingError), errorDesc)
+        }
+    } else {
+        log.Printf("Message processed successfully")
+        msg.Ack(false)
+    }
 }
 
-func (c *Consumer) moveToDeadLetter(body []byte, errorType, errorMsg string) {
+// deadLetter moves a failed message to the dead letter queue and acks it,
+// or requeues it when the dead letter queue cannot take it.
+func (c *Consumer) deadLetter(msg amqp.Delivery, errorType, errorMsg string) {
+    if err := c.moveToDeadLetter(msg, errorType, errorMsg); err != nil {
+        log.Printf("Failed to publish to dead letter exchange, requeueing message: %v", err)
+        msg.Nack(false, true)
+        return
+    }
+    msg.Ack(false)
+}
+
+func (c *Consumer) moveToDeadLetter(msg amqp.Delivery, errorType, errorMsg string) error {
     if c.deadLetterConfig == nil {
         log.Println("Dead letter exchange not configured, discarding failed message")
-        return
+        return nil
     }
 
-    err := c.channel.Publish(
-        c.deadLetterConfig.ExchangeName,  // exchange
-        c.deadLetterConfig.RoutingKey,    // routing key
-        false,                            // mandatory
-        false,                            // immediate
-        amqp.Publishing{
-            ContentType: "application/json",
-            Body:        body,
-            Headers: amqp.Table{
-                "x-error-type":    errorType,
-                "x-error-message": errorMsg,
-                "x-timestamp":     time.Now().Unix(),
-            },
-        },
-    )
-    
+    channel, err := c.publishChannel(msg)
     if err != nil {
-        log.Printf("Failed to publish to dead letter exchange: %v", err)
-    } else {
-        log.Printf("Message published to dead letter exchange with error type: %s", errorType)
+        return err
+    }
+
+    err = publishConfirmed(channel, c.deadLetterConfig.ExchangeName, c.deadLetterConfig.RoutingKey, amqp.Publishing{
+        ContentType:  "application/json",
+        DeliveryMode: amqp.Persistent,
+        MessageId:    uuid.NewString(),
+        Body:         msg.Body,
+        Headers: copyHeaders(msg.Headers, amqp.Table{
+            ErrorTypeHeader:    errorType,
+            ErrorMessageHeader: errorMsg,
+            TimestampHeader:    time.Now().Unix(),
+        }),
+    })
+    if err != nil {
+        return err
+    }
+    log.Printf("Message published to dead letter exchange with error type: %s", errorType)
+    return nil
+}
+
+// Shutdown stops consumption and waits for in-flight messages to be acked or
+// nacked by their workers before closing the connection. Messages still being
+// processed when ctx expires stay unacked and are redelivered by the broker
+// once the channel closes.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+    if channel := c.currentChannel(); channel != nil {
+        if err := channel.Cancel(c.consumerTag, false); err != nil {
+            log.Printf("Error cancelling consumer %s: %v", c.consumerTag, err)
+        }
     }
+    c.stop()
+
+    finished := make(chan struct{})
+    go func() {
+        c.workers.Wait()
+        close(finished)
+    }()
+
+    var err error
+    select {
+    case <-finished:
+        log.Println("All in-flight messages completed")
+    case <-ctx.Done():
+        err = fmt.Errorf("timed out waiting for in-flight messages: %w", ctx.Err())
+    }
+
+    if closeErr := c.closeConnection(); err == nil {
+        err = closeErr
+    }
+    return err
 }
 
 func (c *Consumer) Close() error {
+    c.stop()
+    return c.closeConnection()
+}
+
+func (c *Consumer) stop() {
+    c.closeOnce.Do(func() {
+        close(c.done)
+    })
+}
+
+func (c *Consumer) isClosed() bool {
+    select {
+    case <-c.done:
+        return true
+    default:
+        return false
+    }
+}
+
+func (c *Consumer) currentChannel() *amqp.Channel {
+    c.mutex.RLock()
+    defer c.mutex.RUnlock()
+    return c.channel
+}
+
+func (c *Consumer) setConnection(connection *amqp.Connection, channel *amqp.Channel) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    c.connection = connection
+    c.channel = channel
+}
+
+func (c *Consumer) closeConnection() error {
+    c.mutex.Lock()
+    connection, channel := c.connection, c.channel
+    c.connection, c.channel = nil, nil
+    c.mutex.Unlock()
+
     var err error
 
-    if c.channel != nil {
-        if err = c.channel.Close(); err != nil {
+    if channel != nil && !channel.IsClosed() {
+        if err = channel.Close(); err != nil {
             log.Printf("Error closing channel: %v", err)
         }
     }
 
-    if c.connection != nil {
-        if err = c.connection.Close(); err != nil {
+    if connection != nil && !connection.IsClosed() {
+        if err = connection.Close(); err != nil {
             log.Printf("Error closing connection: %v", err)
         }
     }
 
     return err
-}
\ No newline at end of file
+}
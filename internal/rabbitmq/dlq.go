@@ -0,0 +1,249 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	ErrorTypeHeader    = "x-error-type"
+	ErrorMessageHeader = "x-error-message"
+	TimestampHeader    = "x-timestamp"
+	ReplayedAtHeader   = "x-replayed-at"
+)
+
+type DeadLetterMessage struct {
+	ID           string
+	ErrorType    string
+	ErrorMessage string
+	FailedAt     time.Time
+	Attempts     int
+	Body         []byte
+	delivery     amqp.Delivery
+}
+
+type DeadLetterFilter struct {
+	IDs       []string
+	ErrorType string
+	Contains  string
+	Since     time.Time
+}
+
+func (filter DeadLetterFilter) Matches(message DeadLetterMessage) bool {
+	if len(filter.IDs) > 0 {
+		found := false
+		for _, id := range filter.IDs {
+			if id == message.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.ErrorType != "" && filter.ErrorType != message.ErrorType {
+		return false
+	}
+	if filter.Contains != "" && !strings.Contains(string(message.Body), filter.Contains) &&
+		!strings.Contains(message.ErrorMessage, filter.Contains) {
+		return false
+	}
+	if !filter.Since.IsZero() && message.FailedAt.Before(filter.Since) {
+		return false
+	}
+	return true
+}
+
+// DeadLetterInspector reads the dead letter queue without consuming it.
+// Fetched messages stay unacknowledged until they are replayed, purged or
+// released; anything left over is returned to the queue on Close.
+type DeadLetterInspector struct {
+	uri              string
+	exchangeName     string
+	routingKey       string
+	deadLetterConfig DeadLetterConfig
+	connection       *amqp.Connection
+	channel          *amqp.Channel
+}
+
+func NewDeadLetterInspector(uri, exchangeName, routingKey string, deadLetterConfig DeadLetterConfig) *DeadLetterInspector {
+	return &DeadLetterInspector{
+		uri:              uri,
+		exchangeName:     exchangeName,
+		routingKey:       routingKey,
+		deadLetterConfig: deadLetterConfig,
+	}
+}
+
+func (inspector *DeadLetterInspector) Connect() error {
+	connection, err := amqp.Dial(inspector.uri)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	channel, err := connection.Channel()
+	if err != nil {
+		connection.Close()
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	// Replayed messages are removed only once the broker has confirmed the copy
+	if err := channel.Confirm(false); err != nil {
+		connection.Close()
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	inspector.connection = connection
+	inspector.channel = channel
+	return nil
+}
+
+// Fetch pulls every message currently in the dead letter queue and returns the
+// ones matching filter. Non-matching messages are released immediately.
+func (inspector *DeadLetterInspector) Fetch(filter DeadLetterFilter) ([]DeadLetterMessage, error) {
+	queue, err := inspector.channel.QueueDeclarePassive(
+		inspector.deadLetterConfig.QueueName, // name
+		true,                                 // durable
+		false,                                // auto-delete
+		false,                                // exclusive
+		false,                                // no-wait
+		nil,                                  // arguments
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect dead letter queue: %w", err)
+	}
+
+	var matched []DeadLetterMessage
+	var skipped []amqp.Delivery
+	for i := 0; i < queue.Messages; i++ {
+		delivery, ok, err := inspector.channel.Get(inspector.deadLetterConfig.QueueName, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		message := newDeadLetterMessage(delivery)
+		if filter.Matches(message) {
+			matched = append(matched, message)
+		} else {
+			skipped = append(skipped, delivery)
+		}
+	}
+
+	// Released only after the scan so the same messages are not read twice
+	for _, delivery := range skipped {
+		if err := delivery.Nack(false, true); err != nil {
+			return nil, fmt.Errorf("failed to release message: %w", err)
+		}
+	}
+	return matched, nil
+}
+
+// Replay publishes the message back to the main exchange, with body replacing
+// the original payload when non-nil, and removes it from the dead letter queue.
+func (inspector *DeadLetterInspector) Replay(message DeadLetterMessage, body []byte) error {
+	if body == nil {
+		body = message.Body
+	}
+
+	headers := make(amqp.Table, len(message.delivery.Headers))
+	for key, value := range message.delivery.Headers {
+		switch key {
+		case ErrorTypeHeader, ErrorMessageHeader, TimestampHeader, RetryAttemptHeader, LastErrorHeader:
+			continue
+		}
+		headers[key] = value
+	}
+	headers[ReplayedAtHeader] = time.Now().Unix()
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishConfirmTimeout)
+	defer cancel()
+	confirmation, err := inspector.channel.PublishWithDeferredConfirmWithContext(
+		ctx,
+		inspector.exchangeName, // exchange
+		inspector.routingKey,   // routing key
+		false,                  // mandatory
+		false,                  // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+			Headers:      headers,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to republish message %s: %w", message.ID, err)
+	}
+	if acked, err := confirmation.WaitContext(ctx); err != nil || !acked {
+		if err == nil {
+			err = fmt.Errorf("broker nacked the publish")
+		}
+		return fmt.Errorf("republishing message %s was not confirmed: %w", message.ID, err)
+	}
+	return message.delivery.Ack(false)
+}
+
+func (inspector *DeadLetterInspector) Purge(message DeadLetterMessage) error {
+	return message.delivery.Ack(false)
+}
+
+func (inspector *DeadLetterInspector) Release(message DeadLetterMessage) error {
+	return message.delivery.Nack(false, true)
+}
+
+func (inspector *DeadLetterInspector) Close() error {
+	var err error
+
+	if inspector.channel != nil {
+		err = inspector.channel.Close()
+	}
+	if inspector.connection != nil {
+		if closeErr := inspector.connection.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func newDeadLetterMessage(delivery amqp.Delivery) DeadLetterMessage {
+	message := DeadLetterMessage{
+		ID:       delivery.MessageId,
+		Attempts: retryAttempt(delivery.Headers),
+		Body:     delivery.Body,
+		delivery: delivery,
+	}
+	if message.ID == "" {
+		message.ID = fallbackMessageID(delivery)
+	}
+	if errorType, ok := delivery.Headers[ErrorTypeHeader].(string); ok {
+		message.ErrorType = errorType
+	}
+	if errorMessage, ok := delivery.Headers[ErrorMessageHeader].(string); ok {
+		message.ErrorMessage = errorMessage
+	}
+	switch timestamp := delivery.Headers[TimestampHeader].(type) {
+	case int64:
+		message.FailedAt = time.Unix(timestamp, 0)
+	case int32:
+		message.FailedAt = time.Unix(int64(timestamp), 0)
+	}
+	return message
+}
+
+// fallbackMessageID identifies messages published without a message ID.
+// Delivery tags change every time the queue is read, so the ID is derived
+// from the body and failure time instead and stays valid across runs.
+func fallbackMessageID(delivery amqp.Delivery) string {
+	hash := sha256.New()
+	hash.Write(delivery.Body)
+	fmt.Fprintf(hash, "|%v", delivery.Headers[TimestampHeader])
+	return "sha-" + hex.EncodeToString(hash.Sum(nil))[:16]
+}
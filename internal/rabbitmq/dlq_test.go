@@ -0,0 +1,32 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestDeadLetterMessageIDWithoutMessageID(t *testing.T) {
+	delivery := amqp.Delivery{
+		Body:        []byte(`{"type": "Mail"}`),
+		Headers:     amqp.Table{TimestampHeader: int64(1700000000)},
+		DeliveryTag: 1,
+	}
+	first := newDeadLetterMessage(delivery)
+
+	// Reading the queue again hands out a different delivery tag
+	delivery.DeliveryTag = 7
+	if again := newDeadLetterMessage(delivery); again.ID != first.ID {
+		t.Errorf("ID changed between reads: %s, %s", first.ID, again.ID)
+	}
+
+	delivery.Headers = amqp.Table{TimestampHeader: int64(1700000060)}
+	if other := newDeadLetterMessage(delivery); other.ID == first.ID {
+		t.Errorf("the same body failing at another time got the same ID %s", first.ID)
+	}
+
+	delivery.MessageId = "message-1"
+	if got := newDeadLetterMessage(delivery).ID; got != "message-1" {
+		t.Errorf("ID = %s, want the message ID", got)
+	}
+}
@@ -0,0 +1,187 @@
+package rabbitmq
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type ReconnectConfig struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// MaxAttempts of 0 keeps retrying until the consumer is closed
+	MaxAttempts int
+}
+
+func DefaultReconnectConfig() ReconnectConfig {
+	return ReconnectConfig{
+		InitialInterval: time.Second,
+		MaxInterval:     30 * time.Second,
+		Multiplier:      2,
+	}
+}
+
+type ConnectionEventType string
+
+const (
+	Disconnected    ConnectionEventType = "disconnected"
+	Reconnecting    ConnectionEventType = "reconnecting"
+	Reconnected     ConnectionEventType = "reconnected"
+	ReconnectFailed ConnectionEventType = "reconnect_failed"
+)
+
+type ConnectionEvent struct {
+	Type    ConnectionEventType
+	Attempt int
+	Delay   time.Duration
+	Err     error
+	Time    time.Time
+}
+
+func (c *Consumer) supervise(deliveries <-chan amqp.Delivery) {
+	for {
+		cause := c.watch(deliveries)
+		if c.isClosed() {
+			requeuePending(deliveries)
+			return
+		}
+
+		deliveries = c.reconnect(cause)
+		if deliveries == nil {
+			return
+		}
+	}
+}
+
+// watch dispatches deliveries until the broker connection, the channel or the
+// consumer itself goes away, and returns the reason.
+func (c *Consumer) watch(deliveries <-chan amqp.Delivery) error {
+	c.mutex.RLock()
+	connection, channel := c.connection, c.channel
+	c.mutex.RUnlock()
+	if connection == nil || channel == nil {
+		return stderrors.New("connection not available")
+	}
+
+	connectionClosed := connection.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+	cancelled := channel.NotifyCancel(make(chan string, 1))
+
+	for {
+		select {
+		case msg, ok := <-deliveries:
+			if !ok {
+				return stderrors.New("delivery channel closed")
+			}
+			select {
+			case c.jobs <- msg:
+			case <-c.done:
+				return nil
+			}
+		case amqpErr := <-connectionClosed:
+			return closeReason("connection closed", amqpErr)
+		case amqpErr := <-channelClosed:
+			return closeReason("channel closed", amqpErr)
+		case tag := <-cancelled:
+			return fmt.Errorf("consumer %q cancelled by broker", tag)
+		case <-c.done:
+			return nil
+		}
+	}
+}
+
+func (c *Consumer) reconnect(cause error) <-chan amqp.Delivery {
+	log.Printf("RabbitMQ consumer lost connection: %v", cause)
+	c.emit(ConnectionEvent{Type: Disconnected, Err: cause})
+	c.closeConnection()
+
+	delay := c.reconnectConfig.InitialInterval
+	for attempt := 1; ; attempt++ {
+		if c.reconnectConfig.MaxAttempts > 0 && attempt > c.reconnectConfig.MaxAttempts {
+			err := fmt.Errorf("giving up after %d reconnect attempts", c.reconnectConfig.MaxAttempts)
+			log.Printf("RabbitMQ consumer %v", err)
+			c.emit(ConnectionEvent{Type: ReconnectFailed, Attempt: attempt - 1, Err: err})
+			return nil
+		}
+
+		c.emit(ConnectionEvent{Type: Reconnecting, Attempt: attempt, Delay: delay})
+		select {
+		case <-c.done:
+			return nil
+		case <-time.After(delay):
+		}
+
+		deliveries, err := c.redial()
+		if err == nil {
+			log.Printf("RabbitMQ consumer reconnected after %d attempt(s)", attempt)
+			c.emit(ConnectionEvent{Type: Reconnected, Attempt: attempt})
+			return deliveries
+		}
+
+		log.Printf("RabbitMQ reconnect attempt %d failed: %v", attempt, err)
+		delay = c.nextDelay(delay)
+	}
+}
+
+func (c *Consumer) redial() (<-chan amqp.Delivery, error) {
+	connection, channel, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := c.consume(channel)
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	c.setConnection(connection, channel)
+	return deliveries, nil
+}
+
+func (c *Consumer) nextDelay(delay time.Duration) time.Duration {
+	next := time.Duration(float64(delay) * c.reconnectConfig.Multiplier)
+	if next <= 0 {
+		next = delay
+	}
+	if c.reconnectConfig.MaxInterval > 0 && next > c.reconnectConfig.MaxInterval {
+		next = c.reconnectConfig.MaxInterval
+	}
+	return next
+}
+
+func (c *Consumer) emit(event ConnectionEvent) {
+	if c.onConnectionEvent == nil {
+		return
+	}
+	event.Time = time.Now()
+	c.onConnectionEvent(event)
+}
+
+// requeuePending hands back deliveries that were prefetched but never reached
+// a worker, so another instance can pick them up without waiting for the
+// channel to close.
+func requeuePending(deliveries <-chan amqp.Delivery) {
+	for {
+		select {
+		case msg, ok := <-deliveries:
+			if !ok {
+				return
+			}
+			msg.Nack(false, true)
+		default:
+			return
+		}
+	}
+}
+
+func closeReason(description string, amqpErr *amqp.Error) error {
+	if amqpErr == nil {
+		return stderrors.New(description)
+	}
+	return fmt.Errorf("%s: %w", description, amqpErr)
+}
@@ -0,0 +1,119 @@
+package rabbitmq
+
+import (
+	stderrors "errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNextDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ReconnectConfig
+		delay  time.Duration
+		want   time.Duration
+	}{
+		{"doubles", DefaultReconnectConfig(), time.Second, 2 * time.Second},
+		{"capped at the maximum", DefaultReconnectConfig(), 20 * time.Second, 30 * time.Second},
+		{"stays at the maximum", DefaultReconnectConfig(), 30 * time.Second, 30 * time.Second},
+		{"no maximum", ReconnectConfig{Multiplier: 3}, time.Minute, 3 * time.Minute},
+		{"fractional multiplier", ReconnectConfig{Multiplier: 1.5, MaxInterval: time.Minute}, 10 * time.Second, 15 * time.Second},
+		{"no multiplier keeps the delay", ReconnectConfig{MaxInterval: time.Minute}, 10 * time.Second, 10 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			consumer := &Consumer{reconnectConfig: test.config}
+			if got := consumer.nextDelay(test.delay); got != test.want {
+				t.Errorf("nextDelay(%s) = %s, want %s", test.delay, got, test.want)
+			}
+		})
+	}
+}
+
+func TestEmitStampsEvents(t *testing.T) {
+	// Without a callback events are dropped
+	(&Consumer{}).emit(ConnectionEvent{Type: Disconnected})
+
+	var events []ConnectionEvent
+	consumer := &Consumer{onConnectionEvent: func(event ConnectionEvent) { events = append(events, event) }}
+	before := time.Now()
+	consumer.emit(ConnectionEvent{Type: Reconnecting, Attempt: 2, Delay: time.Second})
+
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Type != Reconnecting || event.Attempt != 2 || event.Delay != time.Second {
+		t.Errorf("event = %+v", event)
+	}
+	if event.Time.Before(before) || event.Time.After(time.Now()) {
+		t.Errorf("event time %s is not when it was emitted", event.Time)
+	}
+}
+
+// unreachableURI points at a local port nothing listens on.
+func unreachableURI(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return "amqp://guest:guest@" + address + "/"
+}
+
+func TestReconnectReportsEachAttempt(t *testing.T) {
+	var events []ConnectionEvent
+	consumer := NewConsumer(unreachableURI(t), "notifications", "", "", &ConsumerOptions{
+		ReconnectConfig:   &ReconnectConfig{InitialInterval: time.Millisecond, MaxInterval: 3 * time.Millisecond, Multiplier: 2, MaxAttempts: 3},
+		OnConnectionEvent: func(event ConnectionEvent) { events = append(events, event) },
+	})
+	cause := stderrors.New("connection closed")
+
+	if deliveries := consumer.reconnect(cause); deliveries != nil {
+		t.Fatal("reconnected to an unreachable broker")
+	}
+
+	want := []ConnectionEvent{
+		{Type: Disconnected, Err: cause},
+		{Type: Reconnecting, Attempt: 1, Delay: time.Millisecond},
+		{Type: Reconnecting, Attempt: 2, Delay: 2 * time.Millisecond},
+		{Type: Reconnecting, Attempt: 3, Delay: 3 * time.Millisecond},
+		{Type: ReconnectFailed, Attempt: 3},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, event := range events {
+		if event.Type != want[i].Type || event.Attempt != want[i].Attempt || event.Delay != want[i].Delay {
+			t.Errorf("event %d = %s attempt %d after %s, want %s attempt %d after %s",
+				i, event.Type, event.Attempt, event.Delay, want[i].Type, want[i].Attempt, want[i].Delay)
+		}
+		if event.Time.IsZero() {
+			t.Errorf("event %d has no time", i)
+		}
+	}
+	if events[0].Err != cause || events[4].Err == nil {
+		t.Errorf("errors = %v, %v, want the cause and why it gave up", events[0].Err, events[4].Err)
+	}
+}
+
+func TestReconnectStopsWhenClosed(t *testing.T) {
+	var events []ConnectionEvent
+	consumer := NewConsumer(unreachableURI(t), "notifications", "", "", &ConsumerOptions{
+		ReconnectConfig:   &ReconnectConfig{InitialInterval: time.Hour},
+		OnConnectionEvent: func(event ConnectionEvent) { events = append(events, event) },
+	})
+	close(consumer.done)
+
+	if deliveries := consumer.reconnect(stderrors.New("connection closed")); deliveries != nil {
+		t.Fatal("reconnected after the consumer was closed")
+	}
+	for _, event := range events {
+		if event.Type == ReconnectFailed {
+			t.Errorf("closing the consumer reported %+v", event)
+		}
+	}
+}
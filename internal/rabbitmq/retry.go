@@ -0,0 +1,208 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"notificationservice/internal/errors"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	RetryAttemptHeader = "x-retry-attempt"
+	LastErrorHeader    = "x-last-error"
+
+	RetriesExhausted = "retries_exhausted"
+
+	publishConfirmTimeout = 10 * time.Second
+)
+
+// RetryConfig describes the delay before each retry attempt. Messages wait in
+// a per-delay queue whose TTL dead-letters them back to the main exchange.
+// When MaxAttempts exceeds the schedule the last delay is reused.
+type RetryConfig struct {
+	Delays      []time.Duration
+	MaxAttempts int
+}
+
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		Delays: []time.Duration{
+			5 * time.Second,
+			30 * time.Second,
+			2 * time.Minute,
+			10 * time.Minute,
+			30 * time.Minute,
+		},
+	}
+}
+
+func (config RetryConfig) delay(attempt int) time.Duration {
+	index := attempt - 1
+	if index >= len(config.Delays) {
+		index = len(config.Delays) - 1
+	}
+	return config.Delays[index]
+}
+
+// delayAtLeast picks the shortest retry queue delay that covers both the
+// scheduled delay and minimum, e.g. a provider's Retry-After. Only declared
+// delays have a queue, so beyond the longest one the longest is used.
+func (config RetryConfig) delayAtLeast(attempt int, minimum time.Duration) time.Duration {
+	delay := config.delay(attempt)
+	if minimum <= delay {
+		return delay
+	}
+
+	longest, chosen := delay, time.Duration(0)
+	for _, candidate := range config.Delays {
+		if candidate >= minimum && (chosen == 0 || candidate < chosen) {
+			chosen = candidate
+		}
+		if candidate > longest {
+			longest = candidate
+		}
+	}
+	if chosen == 0 {
+		return longest
+	}
+	return chosen
+}
+
+func (c *Consumer) retryQueueName(delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%dms", c.queueName, delay.Milliseconds())
+}
+
+func (c *Consumer) declareRetryQueues(channel *amqp.Channel) error {
+	declared := make(map[time.Duration]bool)
+	for _, delay := range c.retryConfig.Delays {
+		if declared[delay] {
+			continue
+		}
+		declared[delay] = true
+
+		_, err := channel.QueueDeclare(
+			c.retryQueueName(delay), // name
+			true,                    // durable
+			false,                   // auto-delete
+			false,                   // exclusive
+			false,                   // no-wait
+			amqp.Table{
+				"x-message-ttl":             delay.Milliseconds(),
+				"x-dead-letter-exchange":    c.exchangeName,
+				"x-dead-letter-routing-key": c.routingKey,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare retry queue for %s: %w", delay, err)
+		}
+	}
+	return nil
+}
+
+func (c *Consumer) retryLater(msg amqp.Delivery, processErr error) {
+	attempt := retryAttempt(msg.Headers) + 1
+	errorDesc := errors.GetErrorDescription(processErr)
+
+	if attempt > c.retryConfig.MaxAttempts {
+		log.Printf("Retriable error after %d attempts, sending to dead letter queue", attempt-1)
+		c.deadLetter(msg, RetriesExhausted, errorDesc)
+		return
+	}
+
+	channel, err := c.publishChannel(msg)
+	if err != nil {
+		log.Printf("Cannot schedule retry, requeueing message: %v", err)
+		msg.Nack(false, true)
+		return
+	}
+
+	delay := c.retryConfig.delayAtLeast(attempt, errors.GetRetryAfter(processErr))
+	err = publishConfirmed(channel, "", c.retryQueueName(delay), amqp.Publishing{
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Body:         msg.Body,
+		Headers: copyHeaders(msg.Headers, amqp.Table{
+			RetryAttemptHeader: int32(attempt),
+			LastErrorHeader:    errorDesc,
+		}),
+	})
+	if err != nil {
+		log.Printf("Failed to schedule retry, requeueing message: %v", err)
+		msg.Nack(false, true)
+		return
+	}
+
+	log.Printf("Retriable error detected, retry %d/%d scheduled in %s", attempt, c.retryConfig.MaxAttempts, delay)
+	msg.Ack(false)
+}
+
+// publishChannel returns the channel to publish a copy of msg on. A delivery
+// from before a reconnect can no longer be acked and the broker redelivers it
+// anyway, so a copy would deliver the message twice.
+func (c *Consumer) publishChannel(msg amqp.Delivery) (*amqp.Channel, error) {
+	channel := c.currentChannel()
+	if channel == nil {
+		return nil, fmt.Errorf("channel not available")
+	}
+	if msg.Acknowledger != channel {
+		return nil, fmt.Errorf("message was delivered on a channel that has since closed")
+	}
+	return channel, nil
+}
+
+// publishConfirmed publishes and waits for the broker to confirm it has the
+// message, since acking the delivery it copies before then could lose it.
+func publishConfirmed(channel *amqp.Channel, exchange, routingKey string, publishing amqp.Publishing) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishConfirmTimeout)
+	defer cancel()
+
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(
+		ctx,
+		exchange,   // exchange
+		routingKey, // routing key
+		false,      // mandatory
+		false,      // immediate
+		publishing,
+	)
+	if err != nil {
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("publish was not confirmed: %w", err)
+	}
+	if !acked {
+		return fmt.Errorf("broker nacked the publish")
+	}
+	return nil
+}
+
+func retryAttempt(headers amqp.Table) int {
+	switch value := headers[RetryAttemptHeader].(type) {
+	case int:
+		return value
+	case int16:
+		return int(value)
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	default:
+		return 0
+	}
+}
+
+func copyHeaders(headers amqp.Table, overrides amqp.Table) amqp.Table {
+	copied := make(amqp.Table, len(headers)+len(overrides))
+	for key, value := range headers {
+		copied[key] = value
+	}
+	for key, value := range overrides {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,127 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"notificationservice/internal/errors"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestRetryDelayAtLeast(t *testing.T) {
+	config := DefaultRetryConfig()
+	tests := []struct {
+		name    string
+		attempt int
+		minimum time.Duration
+		want    time.Duration
+	}{
+		{"scheduled delay without Retry-After", 1, 0, 5 * time.Second},
+		{"scheduled delay covers Retry-After", 2, 10 * time.Second, 30 * time.Second},
+		{"Retry-After equal to a queue", 1, 30 * time.Second, 30 * time.Second},
+		{"shortest queue covering Retry-After", 1, 45 * time.Second, 2 * time.Minute},
+		{"never shorter than the schedule", 4, 45 * time.Second, 10 * time.Minute},
+		{"beyond the longest queue", 1, time.Hour, 30 * time.Minute},
+		{"attempts past the schedule reuse the last delay", 8, 0, 30 * time.Minute},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := config.delayAtLeast(test.attempt, test.minimum); got != test.want {
+				t.Errorf("delayAtLeast(%d, %s) = %s, want %s", test.attempt, test.minimum, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelayAtLeastPicksADeclaredQueue(t *testing.T) {
+	// Delays need not be sorted; the chosen delay must still have a queue
+	config := RetryConfig{Delays: []time.Duration{time.Minute, 10 * time.Second, 5 * time.Minute}}
+	consumer := &Consumer{queueName: "notifications", retryConfig: config}
+	declared := make(map[string]bool)
+	for _, delay := range config.Delays {
+		declared[consumer.retryQueueName(delay)] = true
+	}
+
+	for _, minimum := range []time.Duration{0, 5 * time.Second, 20 * time.Second, 2 * time.Minute, time.Hour} {
+		for attempt := 1; attempt <= 4; attempt++ {
+			delay := config.delayAtLeast(attempt, minimum)
+			if !declared[consumer.retryQueueName(delay)] {
+				t.Errorf("delayAtLeast(%d, %s) = %s, which has no retry queue", attempt, minimum, delay)
+			}
+		}
+	}
+	if got := config.delayAtLeast(2, 20*time.Second); got != time.Minute {
+		t.Errorf("delayAtLeast(2, 20s) = %s, want 1m", got)
+	}
+}
+
+func TestRetryAttemptHeader(t *testing.T) {
+	tests := []struct {
+		headers amqp.Table
+		want    int
+	}{
+		{nil, 0},
+		{amqp.Table{RetryAttemptHeader: int32(3)}, 3},
+		{amqp.Table{RetryAttemptHeader: int64(4)}, 4},
+		{amqp.Table{RetryAttemptHeader: "5"}, 0},
+	}
+	for _, test := range tests {
+		if got := retryAttempt(test.headers); got != test.want {
+			t.Errorf("retryAttempt(%v) = %d, want %d", test.headers, got, test.want)
+		}
+	}
+}
+
+// recordingAcknowledger stands in for the channel a delivery arrived on.
+type recordingAcknowledger struct {
+	acked, requeued int
+}
+
+func (acknowledger *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	acknowledger.acked++
+	return nil
+}
+
+func (acknowledger *recordingAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	if requeue {
+		acknowledger.requeued++
+	}
+	return nil
+}
+
+func (acknowledger *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	return acknowledger.Nack(tag, false, requeue)
+}
+
+func TestDeliveriesFromAClosedChannelAreNotCopied(t *testing.T) {
+	// The consumer has reconnected since the message was delivered. Publishing
+	// on the unconnected current channel would fail the test with a panic.
+	consumer := &Consumer{
+		queueName:        "notifications",
+		retryConfig:      RetryConfig{Delays: []time.Duration{time.Second}, MaxAttempts: 2},
+		deadLetterConfig: &DeadLetterConfig{ExchangeName: "dlx", RoutingKey: "failed"},
+		channel:          &amqp.Channel{},
+	}
+	tests := []struct {
+		name    string
+		attempt int32
+	}{
+		{"retry", 0},
+		{"retries exhausted", 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			acknowledger := &recordingAcknowledger{}
+			msg := amqp.Delivery{
+				Acknowledger: acknowledger,
+				Headers:      amqp.Table{RetryAttemptHeader: test.attempt},
+				Body:         []byte(`{}`),
+			}
+			consumer.retryLater(msg, errors.NewRetriableError("server busy", nil))
+			if acknowledger.acked != 0 || acknowledger.requeued != 1 {
+				t.Errorf("acked %d, requeued %d, want only a requeue", acknowledger.acked, acknowledger.requeued)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryAttachmentRepository mirrors MongoAttachmentRepository without a
+// database.
+type MemoryAttachmentRepository struct {
+	mutex    sync.RWMutex
+	contents map[primitive.ObjectID][]byte
+}
+
+func NewMemoryAttachmentRepository() *MemoryAttachmentRepository {
+	return &MemoryAttachmentRepository{contents: make(map[primitive.ObjectID][]byte)}
+}
+
+func (repository *MemoryAttachmentRepository) SaveAttachmentContent(content []byte) (primitive.ObjectID, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	id := primitive.NewObjectID()
+	repository.contents[id] = append([]byte(nil), content...)
+	return id, nil
+}
+
+func (repository *MemoryAttachmentRepository) GetAttachmentContent(id primitive.ObjectID) ([]byte, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+
+	content, exists := repository.contents[id]
+	if !exists {
+		return nil, nil
+	}
+	return append([]byte(nil), content...), nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryDeviceRepository mirrors MongoDeviceRepository without a database.
+type MemoryDeviceRepository struct {
+	mutex   sync.RWMutex
+	devices map[string]models.Device
+}
+
+func NewMemoryDeviceRepository() *MemoryDeviceRepository {
+	return &MemoryDeviceRepository{devices: make(map[string]models.Device)}
+}
+
+func (repository *MemoryDeviceRepository) SaveDevice(device *models.Device) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	now := time.Now()
+	stored, exists := repository.devices[device.Token]
+	if !exists {
+		stored = models.Device{ID: primitive.NewObjectID(), Token: device.Token, CreatedAt: now}
+	}
+	stored.UserID = device.UserID
+	stored.Platform = device.Platform
+	stored.UpdatedAt = now
+
+	repository.devices[device.Token] = stored
+	*device = stored
+	return nil
+}
+
+func (repository *MemoryDeviceRepository) GetDevices(userId uuid.UUID) ([]models.Device, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+
+	devices := []models.Device{}
+	for _, device := range repository.devices {
+		if device.UserID == userId {
+			devices = append(devices, device)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].CreatedAt.Before(devices[j].CreatedAt) })
+	return devices, nil
+}
+
+func (repository *MemoryDeviceRepository) DeleteDevice(userId uuid.UUID, token string) (bool, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	device, exists := repository.devices[token]
+	if !exists || device.UserID != userId {
+		return false, nil
+	}
+	delete(repository.devices, token)
+	return true, nil
+}
+
+func (repository *MemoryDeviceRepository) DeleteDevicesByToken(tokens []string) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	for _, token := range tokens {
+		delete(repository.devices, token)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type preferenceKey struct {
+	userId   uuid.UUID
+	category string
+}
+
+// MemoryPreferenceRepository mirrors MongoPreferenceRepository without a
+// database.
+type MemoryPreferenceRepository struct {
+	mutex       sync.RWMutex
+	preferences map[preferenceKey]models.Preference
+}
+
+func NewMemoryPreferenceRepository() *MemoryPreferenceRepository {
+	return &MemoryPreferenceRepository{preferences: make(map[preferenceKey]models.Preference)}
+}
+
+func (repository *MemoryPreferenceRepository) SavePreference(preference *models.Preference) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	now := time.Now()
+	key := preferenceKey{userId: preference.UserID, category: preference.Category}
+	stored, exists := repository.preferences[key]
+	if !exists {
+		stored = models.Preference{ID: primitive.NewObjectID(), UserID: preference.UserID, Category: preference.Category, CreatedAt: &now}
+	}
+	stored.OptOut = preference.OptOut
+	stored.Channels = make(map[models.NotificationType]bool, len(preference.Channels))
+	for channel, enabled := range preference.Channels {
+		stored.Channels[channel] = enabled
+	}
+	stored.QuietHours = preference.QuietHours
+	stored.Digest = preference.Digest
+	stored.UpdatedAt = &now
+
+	repository.preferences[key] = stored
+	*preference = stored
+	return nil
+}
+
+func (repository *MemoryPreferenceRepository) GetPreference(userId uuid.UUID, category string) (*models.Preference, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+
+	preference, exists := repository.preferences[preferenceKey{userId: userId, category: category}]
+	if !exists {
+		return nil, nil
+	}
+	return &preference, nil
+}
+
+func (repository *MemoryPreferenceRepository) GetPreferences(userId uuid.UUID) ([]models.Preference, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+
+	preferences := []models.Preference{}
+	for _, preference := range repository.preferences {
+		if preference.UserID == userId {
+			preferences = append(preferences, preference)
+		}
+	}
+	sort.Slice(preferences, func(i, j int) bool {
+		return preferences[i].Category < preferences[j].Category
+	})
+	return preferences, nil
+}
+
+func (repository *MemoryPreferenceRepository) DeletePreference(userId uuid.UUID, category string) (bool, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	key := preferenceKey{userId: userId, category: category}
+	if _, exists := repository.preferences[key]; !exists {
+		return false, nil
+	}
+	delete(repository.preferences, key)
+	return true, nil
+}
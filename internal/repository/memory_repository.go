@@ -0,0 +1,462 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryRepository mirrors MongoRepository without a database. Documents are
+// stored in their BSON form so callers see the same copy and time-precision
+// semantics as they would against Mongo.
+type MemoryRepository struct {
+	mutex         sync.RWMutex
+	notifications map[primitive.ObjectID][]byte
+	order         []primitive.ObjectID
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		notifications: make(map[primitive.ObjectID][]byte),
+	}
+}
+
+func (repository *MemoryRepository) SaveNotification(notification *models.Notification) error {
+	notification.ID = primitive.NewObjectID()
+	notification.CreatedAt = time.Now()
+	if notification.DeliveryStatus.NotificationStatus != models.Scheduled {
+		notification.DeliveryStatus.NotificationStatus = models.Pending
+	}
+	notification.DeliveryStatus.UpdatedAt = time.Now()
+
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if err := repository.store(notification); err != nil {
+		return err
+	}
+	repository.order = append(repository.order, notification.ID)
+	return nil
+}
+
+func (repository *MemoryRepository) GetUnreadNotifications(userId uuid.UUID, now time.Time) ([]models.Notification, error) {
+	return repository.find(func(notification *models.Notification) bool {
+		return notification.UserID == userId && notification.ReceivedAt == nil && isInbox(notification, now)
+	})
+}
+
+func (repository *MemoryRepository) CountUnreadNotifications(userId uuid.UUID, now time.Time) (int64, error) {
+	notifications, err := repository.GetUnreadNotifications(userId, now)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(notifications)), nil
+}
+
+func (repository *MemoryRepository) ReplaceNotification(notification *models.Notification) error {
+	notification.DeliveryStatus.UpdatedAt = time.Now()
+
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if _, ok := repository.notifications[notification.ID]; !ok {
+		return nil
+	}
+	return repository.store(notification)
+}
+
+func (repository *MemoryRepository) MarkNotificationsReceived(userId uuid.UUID, notificationIDs []primitive.ObjectID, now time.Time) error {
+	ids := make(map[primitive.ObjectID]struct{}, len(notificationIDs))
+	for _, id := range notificationIDs {
+		ids[id] = struct{}{}
+	}
+	return repository.markReceived(now, func(notification *models.Notification) bool {
+		_, ok := ids[notification.ID]
+		return ok && notification.UserID == userId
+	})
+}
+
+func (repository *MemoryRepository) MarkAllNotificationsReceived(userId uuid.UUID, now time.Time) error {
+	return repository.markReceived(now, func(notification *models.Notification) bool {
+		return notification.UserID == userId && isInbox(notification, now)
+	})
+}
+
+func (repository *MemoryRepository) FindNotifications(notificationFilter NotificationFilter) ([]models.Notification, error) {
+	notifications, err := repository.find(func(notification *models.Notification) bool {
+		if notification.UserID != notificationFilter.UserID || notification.IsMultiChannel() {
+			return false
+		}
+		if notificationFilter.Status != "" && notification.DeliveryStatus.NotificationStatus != notificationFilter.Status {
+			return false
+		}
+		if notificationFilter.Type != "" && notification.Type != notificationFilter.Type {
+			return false
+		}
+		if notificationFilter.CreatedFrom != nil && notification.CreatedAt.Before(*notificationFilter.CreatedFrom) {
+			return false
+		}
+		if notificationFilter.CreatedTo != nil && notification.CreatedAt.After(*notificationFilter.CreatedTo) {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+	})
+
+	if notificationFilter.Offset > 0 {
+		if notificationFilter.Offset >= int64(len(notifications)) {
+			return []models.Notification{}, nil
+		}
+		notifications = notifications[notificationFilter.Offset:]
+	}
+	if notificationFilter.Limit > 0 && notificationFilter.Limit < int64(len(notifications)) {
+		notifications = notifications[:notificationFilter.Limit]
+	}
+	return notifications, nil
+}
+
+func (repository *MemoryRepository) GetNotificationByID(notificationID primitive.ObjectID) (*models.Notification, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+
+	return repository.load(notificationID)
+}
+
+func (repository *MemoryRepository) GetChildNotifications(parentID primitive.ObjectID) ([]models.Notification, error) {
+	return repository.find(func(notification *models.Notification) bool {
+		return notification.ParentID != nil && *notification.ParentID == parentID
+	})
+}
+
+func (repository *MemoryRepository) GetUnsentNotifications(externalId uuid.UUID) (*models.Notification, error) {
+	notifications, err := repository.find(func(notification *models.Notification) bool {
+		status := notification.DeliveryStatus.NotificationStatus
+		return notification.ExternalID == externalId && notification.ParentID == nil &&
+			(status == models.Failed || status == models.Pending || status == models.Deferred ||
+				status == models.Scheduled || status == models.Cancelled || status == models.Batched ||
+				status == models.Suppressed || status == models.Expired)
+	})
+	if err != nil || len(notifications) == 0 {
+		return nil, err
+	}
+	return &notifications[0], nil
+}
+
+func (repository *MemoryRepository) UpdateNotificationStatus(notificationID primitive.ObjectID, status models.DeliveryStatus) error {
+	status.UpdatedAt = time.Now()
+
+	return repository.update(notificationID, func(notification *models.Notification) {
+		notification.DeliveryStatus = status
+	})
+}
+
+func (repository *MemoryRepository) AddDeliveryAttempt(notificationID primitive.ObjectID, attempt models.DeliveryAttempt) error {
+	return repository.update(notificationID, func(notification *models.Notification) {
+		notification.DeliveryAttempts = append(notification.DeliveryAttempts, attempt)
+	})
+}
+
+func (repository *MemoryRepository) UpdateFallbackState(notificationID primitive.ObjectID, notificationType models.NotificationType, status models.DeliveryStatus, state *models.FallbackState) error {
+	status.UpdatedAt = time.Now()
+
+	return repository.update(notificationID, func(notification *models.Notification) {
+		notification.Type = notificationType
+		notification.DeliveryStatus = status
+		notification.Fallback = state
+	})
+}
+
+func (repository *MemoryRepository) ClaimDueFallback(now time.Time, lease time.Duration) (*models.Notification, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	var due *models.Notification
+	for _, id := range repository.order {
+		notification, err := repository.load(id)
+		if err != nil {
+			return nil, err
+		}
+		if notification.Fallback == nil || notification.Fallback.CheckAt == nil || notification.Fallback.CheckAt.After(now) {
+			continue
+		}
+		if due == nil || notification.Fallback.CheckAt.Before(*due.Fallback.CheckAt) {
+			due = notification
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+
+	checkAt := now.Add(lease)
+	due.Fallback.CheckAt = &checkAt
+	if err := repository.store(due); err != nil {
+		return nil, err
+	}
+	return repository.load(due.ID)
+}
+
+func (repository *MemoryRepository) DeferNotification(notification *models.Notification) error {
+	notification.DeliveryStatus.UpdatedAt = time.Now()
+
+	return repository.update(notification.ID, func(stored *models.Notification) {
+		stored.Type = notification.Type
+		stored.DeliveryStatus = notification.DeliveryStatus
+		stored.DeliverAt = notification.DeliverAt
+		stored.Fallback = notification.Fallback
+		stored.ReleaseAttempts = notification.ReleaseAttempts
+	})
+}
+
+func (repository *MemoryRepository) ClaimDueNotification(now time.Time, lease time.Duration) (*models.Notification, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	var due *models.Notification
+	for _, id := range repository.order {
+		notification, err := repository.load(id)
+		if err != nil {
+			return nil, err
+		}
+		status := notification.DeliveryStatus.NotificationStatus
+		if (status != models.Deferred && status != models.Scheduled) || notification.DeliverAt == nil || notification.DeliverAt.After(now) {
+			continue
+		}
+		if due == nil || notification.DeliverAt.Before(*due.DeliverAt) {
+			due = notification
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+
+	deliverAt := now.Add(lease)
+	due.DeliverAt = &deliverAt
+	due.DispatchedAt = &now
+	if err := repository.store(due); err != nil {
+		return nil, err
+	}
+	return repository.load(due.ID)
+}
+
+func (repository *MemoryRepository) CancelScheduled(externalId uuid.UUID) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	var cancelled int64
+	for _, id := range repository.order {
+		notification, err := repository.load(id)
+		if err != nil {
+			return cancelled, err
+		}
+		if notification.ExternalID != externalId || notification.DeliveryStatus.NotificationStatus != models.Scheduled || notification.DispatchedAt != nil {
+			continue
+		}
+		notification.DeliveryStatus = models.DeliveryStatus{NotificationStatus: models.Cancelled, UpdatedAt: time.Now()}
+		if err := repository.store(notification); err != nil {
+			return cancelled, err
+		}
+		cancelled++
+	}
+	return cancelled, nil
+}
+
+func (repository *MemoryRepository) GetOpenDigest(userId uuid.UUID, frequency models.DigestFrequency) (*models.Notification, error) {
+	digests, err := repository.find(func(notification *models.Notification) bool {
+		return notification.UserID == userId && notification.Digest != nil && notification.Digest.Frequency == frequency &&
+			notification.DeliveryStatus.NotificationStatus == models.Scheduled && notification.DispatchedAt == nil
+	})
+	if err != nil || len(digests) == 0 {
+		return nil, err
+	}
+	sort.SliceStable(digests, func(i, j int) bool {
+		return digests[i].DeliverAt.Before(*digests[j].DeliverAt)
+	})
+	return &digests[0], nil
+}
+
+func (repository *MemoryRepository) AttachToDigest(notificationID, digestID primitive.ObjectID) error {
+	return repository.update(notificationID, func(notification *models.Notification) {
+		notification.DigestID = &digestID
+		notification.DeliveryStatus = models.DeliveryStatus{NotificationStatus: models.Batched, UpdatedAt: time.Now()}
+	})
+}
+
+func (repository *MemoryRepository) MoveToDigest(notificationID, from, to primitive.ObjectID) (bool, error) {
+	var moved bool
+	err := repository.update(notificationID, func(notification *models.Notification) {
+		if notification.DigestID == nil || *notification.DigestID != from ||
+			notification.DeliveryStatus.NotificationStatus != models.Batched {
+			return
+		}
+		notification.DigestID = &to
+		notification.DeliveryStatus.UpdatedAt = time.Now()
+		moved = true
+	})
+	return moved, err
+}
+
+func (repository *MemoryRepository) CollectDigestItems(digestID primitive.ObjectID) ([]models.Notification, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	items := []models.Notification{}
+	for _, id := range repository.order {
+		notification, err := repository.load(id)
+		if err != nil {
+			return nil, err
+		}
+		if notification.DigestID == nil || *notification.DigestID != digestID {
+			continue
+		}
+		switch notification.DeliveryStatus.NotificationStatus {
+		case models.Batched:
+			notification.DeliveryStatus = models.DeliveryStatus{NotificationStatus: models.Digested, UpdatedAt: time.Now()}
+			if err := repository.store(notification); err != nil {
+				return nil, err
+			}
+		case models.Digested:
+		default:
+			continue
+		}
+		items = append(items, *notification)
+	}
+	return items, nil
+}
+
+func (repository *MemoryRepository) FailDigestItems(digestID primitive.ObjectID, reason string) ([]models.Notification, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	items := []models.Notification{}
+	for _, id := range repository.order {
+		notification, err := repository.load(id)
+		if err != nil {
+			return nil, err
+		}
+		if notification.DigestID == nil || *notification.DigestID != digestID ||
+			notification.DeliveryStatus.NotificationStatus != models.Digested {
+			continue
+		}
+		notification.DeliveryStatus = models.DeliveryStatus{NotificationStatus: models.Failed, Error: reason, UpdatedAt: time.Now()}
+		if err := repository.store(notification); err != nil {
+			return nil, err
+		}
+		items = append(items, *notification)
+	}
+	return items, nil
+}
+
+func (repository *MemoryRepository) UpdateDigestContent(notification *models.Notification) error {
+	return repository.update(notification.ID, func(stored *models.Notification) {
+		stored.Subject = notification.Subject
+		stored.Body = notification.Body
+		stored.MailInfo = notification.MailInfo
+		stored.Template = notification.Template
+		stored.Digest = notification.Digest
+	})
+}
+
+// isInbox reports whether a notification belongs in the unread inbox: an
+// in-app notification that was shown and is still current.
+func isInbox(notification *models.Notification, now time.Time) bool {
+	return notification.Type == models.InAppNotification &&
+		!isHeldBack(notification.DeliveryStatus.NotificationStatus) && !isExpired(notification, now)
+}
+
+// isHeldBack reports whether a notification was not, or not yet, delivered
+// on purpose; such notifications are not unread.
+func isHeldBack(status models.NotificationStatus) bool {
+	switch status {
+	case models.Suppressed, models.Deferred, models.Scheduled, models.Cancelled, models.Expired, models.Batched:
+		return true
+	}
+	return false
+}
+
+func isExpired(notification *models.Notification, now time.Time) bool {
+	return notification.ExpiresAt != nil && !notification.ExpiresAt.After(now)
+}
+
+func (repository *MemoryRepository) markReceived(now time.Time, match func(*models.Notification) bool) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	for _, id := range repository.order {
+		notification, err := repository.load(id)
+		if err != nil {
+			return err
+		}
+		if notification.ReceivedAt != nil || !match(notification) {
+			continue
+		}
+		notification.ReceivedAt = &now
+		if err := repository.store(notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update applies change to the stored document; like Mongo's UpdateOne it is
+// not an error when no document matches.
+func (repository *MemoryRepository) update(notificationID primitive.ObjectID, change func(*models.Notification)) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	notification, err := repository.load(notificationID)
+	if err != nil || notification == nil {
+		return err
+	}
+	change(notification)
+	return repository.store(notification)
+}
+
+func (repository *MemoryRepository) find(match func(*models.Notification) bool) ([]models.Notification, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+
+	notifications := []models.Notification{}
+	for _, id := range repository.order {
+		notification, err := repository.load(id)
+		if err != nil {
+			return nil, err
+		}
+		if match(notification) {
+			notifications = append(notifications, *notification)
+		}
+	}
+	return notifications, nil
+}
+
+func (repository *MemoryRepository) load(notificationID primitive.ObjectID) (*models.Notification, error) {
+	data, ok := repository.notifications[notificationID]
+	if !ok {
+		return nil, nil
+	}
+	var notification models.Notification
+	if err := bson.Unmarshal(data, &notification); err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (repository *MemoryRepository) store(notification *models.Notification) error {
+	data, err := bson.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	repository.notifications[notification.ID] = data
+	return nil
+}
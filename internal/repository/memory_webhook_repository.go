@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type webhookOwner struct {
+	userId   uuid.UUID
+	tenantId string
+}
+
+// MemoryWebhookRepository mirrors MongoWebhookRepository without a database.
+type MemoryWebhookRepository struct {
+	mutex    sync.RWMutex
+	webhooks map[webhookOwner]models.Webhook
+}
+
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{webhooks: make(map[webhookOwner]models.Webhook)}
+}
+
+func (repository *MemoryWebhookRepository) SaveWebhook(webhook *models.Webhook) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	now := time.Now()
+	owner := webhookOwner{userId: webhook.UserID, tenantId: webhook.TenantID}
+	stored, exists := repository.webhooks[owner]
+	if !exists {
+		stored = models.Webhook{ID: primitive.NewObjectID(), UserID: webhook.UserID, TenantID: webhook.TenantID, CreatedAt: now}
+	}
+	stored.URL = webhook.URL
+	stored.Secret = webhook.Secret
+	stored.UpdatedAt = now
+
+	repository.webhooks[owner] = stored
+	*webhook = stored
+	return nil
+}
+
+func (repository *MemoryWebhookRepository) GetWebhook(userId uuid.UUID, tenantId string) (*models.Webhook, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+
+	webhook, exists := repository.webhooks[webhookOwner{userId: userId, tenantId: tenantId}]
+	if !exists {
+		return nil, nil
+	}
+	return &webhook, nil
+}
+
+func (repository *MemoryWebhookRepository) DeleteWebhook(userId uuid.UUID, tenantId string) (bool, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	owner := webhookOwner{userId: userId, tenantId: tenantId}
+	if _, exists := repository.webhooks[owner]; !exists {
+		return false, nil
+	}
+	delete(repository.webhooks, owner)
+	return true, nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoAttachmentRepository struct {
+	collection *mongo.Collection
+}
+
+type attachmentContent struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Content   []byte             `bson:"content"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+func NewMongoAttachmentRepository(database *mongo.Database) *MongoAttachmentRepository {
+	return &MongoAttachmentRepository{collection: database.Collection("attachments")}
+}
+
+// EnsureIndexes adds a TTL index that deletes attachment content retention
+// after it was stored, by when the email has been sent or given up on.
+func (repository *MongoAttachmentRepository) EnsureIndexes(retention time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repository.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "createdAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(retention / time.Second)),
+	})
+	return err
+}
+
+func (repository *MongoAttachmentRepository) SaveAttachmentContent(content []byte) (primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	document := attachmentContent{ID: primitive.NewObjectID(), Content: content, CreatedAt: time.Now()}
+	if _, err := repository.collection.InsertOne(ctx, document); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return document.ID, nil
+}
+
+func (repository *MongoAttachmentRepository) GetAttachmentContent(id primitive.ObjectID) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var document attachmentContent
+	err := repository.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&document)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return document.Content, nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDeviceRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDeviceRepository(database *mongo.Database) *MongoDeviceRepository {
+	return &MongoDeviceRepository{collection: database.Collection("devices")}
+}
+
+func (repository *MongoDeviceRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repository.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}},
+		},
+	})
+	return err
+}
+
+// SaveDevice upserts by token, so a token registered again, possibly by a
+// different user, replaces the earlier registration.
+func (repository *MongoDeviceRepository) SaveDevice(device *models.Device) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"userId":    device.UserID,
+			"platform":  device.Platform,
+			"updatedAt": now,
+		},
+		"$setOnInsert": bson.M{
+			"createdAt": now,
+		},
+	}
+	updateOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	return repository.collection.FindOneAndUpdate(ctx, bson.M{"token": device.Token}, update, updateOptions).Decode(device)
+}
+
+func (repository *MongoDeviceRepository) GetDevices(userId uuid.UUID) ([]models.Device, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := repository.collection.Find(ctx, bson.M{"userId": userId})
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []models.Device{}
+	if err = cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (repository *MongoDeviceRepository) DeleteDevice(userId uuid.UUID, token string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := repository.collection.DeleteOne(ctx, bson.M{"userId": userId, "token": token})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (repository *MongoDeviceRepository) DeleteDevicesByToken(tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := repository.collection.DeleteMany(ctx, bson.M{"token": bson.M{"$in": tokens}})
+	return err
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoPreferenceRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoPreferenceRepository(database *mongo.Database) *MongoPreferenceRepository {
+	return &MongoPreferenceRepository{collection: database.Collection("preferences")}
+}
+
+func (repository *MongoPreferenceRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repository.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "category", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (repository *MongoPreferenceRepository) SavePreference(preference *models.Preference) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"userId": preference.UserID, "category": preference.Category}
+	update := bson.M{
+		"$set": bson.M{
+			"optOut":     preference.OptOut,
+			"channels":   preference.Channels,
+			"quietHours": preference.QuietHours,
+			"digest":     preference.Digest,
+			"updatedAt":  now,
+		},
+		"$setOnInsert": bson.M{
+			"createdAt": now,
+		},
+	}
+	updateOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	return repository.collection.FindOneAndUpdate(ctx, filter, update, updateOptions).Decode(preference)
+}
+
+func (repository *MongoPreferenceRepository) GetPreference(userId uuid.UUID, category string) (*models.Preference, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var preference models.Preference
+	err := repository.collection.FindOne(ctx, bson.M{"userId": userId, "category": category}).Decode(&preference)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &preference, nil
+}
+
+func (repository *MongoPreferenceRepository) GetPreferences(userId uuid.UUID) ([]models.Preference, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "category", Value: 1}})
+	cursor, err := repository.collection.Find(ctx, bson.M{"userId": userId}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := []models.Preference{}
+	if err = cursor.All(ctx, &preferences); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+func (repository *MongoPreferenceRepository) DeletePreference(userId uuid.UUID, category string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := repository.collection.DeleteOne(ctx, bson.M{"userId": userId, "category": category})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
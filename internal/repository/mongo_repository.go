@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"notificationservice/internal/models"
@@ -13,6 +14,13 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// indexOptionsConflict is the server error for an index that exists with
+// different options.
+const indexOptionsConflict = 85
+
+// expiryIndexName is the default name of the index on expiresAt.
+const expiryIndexName = "expiresAt_1"
+
 type MongoRepository struct {
     client     *mongo.Client
     database   string
@@ -35,6 +43,106 @@ func NewMongoRepository(uri, database string) (*MongoRepository, error) {
     }, nil
 }
 
+func (repository *MongoRepository) Database() *mongo.Database {
+    return repository.client.Database(repository.database)
+}
+
+func (repository *MongoRepository) EnsureIndexes() error {
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    _, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {
+            Keys:    bson.D{{Key: "fallback.checkAt", Value: 1}},
+            Options: options.Index().SetSparse(true),
+        },
+        {
+            Keys:    bson.D{{Key: "deliverAt", Value: 1}},
+            Options: options.Index().SetSparse(true),
+        },
+        {
+            Keys:    bson.D{{Key: "digestId", Value: 1}},
+            Options: options.Index().SetSparse(true),
+        },
+    })
+    return err
+}
+
+// EnsureExpiryIndex adds a TTL index that deletes expired notifications once
+// they are retention past their expiresAt. Notifications that were delivered
+// or are still pending are kept, as are those without an expiresAt.
+func (repository *MongoRepository) EnsureExpiryIndex(retention time.Duration) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    database := repository.client.Database(repository.database)
+    indexes := database.Collection(repository.collection).Indexes()
+    keys := bson.D{{Key: "expiresAt", Value: 1}}
+    partialFilter := bson.M{"deliveryStatus.notificationStatus": models.Expired}
+    seconds := int32(retention / time.Second)
+    model := mongo.IndexModel{
+        Keys:    keys,
+        Options: options.Index().SetExpireAfterSeconds(seconds).SetPartialFilterExpression(partialFilter),
+    }
+
+    _, err := indexes.CreateOne(ctx, model)
+    var commandErr mongo.CommandError
+    if !errors.As(err, &commandErr) || commandErr.Code != indexOptionsConflict {
+        return err
+    }
+
+    existing, err := findIndex(ctx, indexes, expiryIndexName)
+    if err != nil {
+        return err
+    }
+    if !onlyExpires(existing) {
+        // An index from before the partial filter would delete delivered
+        // notifications too, and collMod cannot add a filter
+        if _, err := indexes.DropOne(ctx, expiryIndexName); err != nil {
+            return err
+        }
+        _, err = indexes.CreateOne(ctx, model)
+        return err
+    }
+
+    // The index exists with another retention; change it in place
+    command := bson.D{
+        {Key: "collMod", Value: repository.collection},
+        {Key: "index", Value: bson.D{{Key: "name", Value: expiryIndexName}, {Key: "expireAfterSeconds", Value: seconds}}},
+    }
+    return database.RunCommand(ctx, command).Err()
+}
+
+// onlyExpires reports whether an expiry index spec, as listed by the server,
+// is limited to expired notifications.
+func onlyExpires(spec bson.M) bool {
+    filter, _ := spec["partialFilterExpression"].(bson.M)
+    return len(filter) == 1 && filter["deliveryStatus.notificationStatus"] == string(models.Expired)
+}
+
+func findIndex(ctx context.Context, indexes mongo.IndexView, name string) (bson.M, error) {
+    cursor, err := indexes.List(ctx)
+    if err != nil {
+        return nil, err
+    }
+    var specs []bson.M
+    if err := cursor.All(ctx, &specs); err != nil {
+        return nil, err
+    }
+    for _, spec := range specs {
+        if spec["name"] == name {
+            return spec, nil
+        }
+    }
+    return nil, nil
+}
+
+func (repository *MongoRepository) Close(ctx context.Context) error {
+    return repository.client.Disconnect(ctx)
+}
+
 func (repository *MongoRepository) SaveNotification(notification *models.Notification) error {
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
@@ -43,32 +151,186 @@ func (repository *MongoRepository) SaveNotification(notification *models.Notific
 
     notification.ID = primitive.NewObjectID()
     notification.CreatedAt = time.Now()
-    notification.DeliveryStatus = models.DeliveryStatus{
-        NotificationStatus:    models.Pending,
-        UpdatedAt: time.Now(),
+    if notification.DeliveryStatus.NotificationStatus != models.Scheduled {
+        notification.DeliveryStatus.NotificationStatus = models.Pending
     }
+    notification.DeliveryStatus.UpdatedAt = time.Now()
 
     _, err := collection.InsertOne(ctx, notification)
     return err
 }
 
-func (repository *MongoRepository) GetUnreadNotifications(userId string) ([]models.Notification, error) {
+func (repository *MongoRepository) ReplaceNotification(notification *models.Notification) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    notification.DeliveryStatus.UpdatedAt = time.Now()
+    _, err := collection.ReplaceOne(ctx, bson.M{"_id": notification.ID}, notification)
+    return err
+}
+
+func (repository *MongoRepository) GetUnreadNotifications(userId uuid.UUID, now time.Time) ([]models.Notification, error) {
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
 
     collection := repository.client.Database(repository.database).Collection(repository.collection)
     
+    cursor, err := collection.Find(ctx, unreadFilter(userId, now))
+    if err != nil {
+        return nil, err
+    }
+
+    notifications := []models.Notification{}
+    if err = cursor.All(ctx, &notifications); err != nil {
+        return nil, err
+    }
+
+    return notifications, nil
+}
+
+func (repository *MongoRepository) CountUnreadNotifications(userId uuid.UUID, now time.Time) (int64, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    return collection.CountDocuments(ctx, unreadFilter(userId, now))
+}
+
+// unreadFilter matches the in-app notifications a user has not read yet.
+// Other channels have no inbox, and held back or expired notifications were
+// never shown.
+func unreadFilter(userId uuid.UUID, now time.Time) bson.M {
+    return bson.M{
+        "userId": userId,
+        "type": models.InAppNotification,
+        "receivedAt": bson.M{"$exists": false},
+        "deliveryStatus.notificationStatus": bson.M{"$nin": bson.A{models.Suppressed, models.Deferred, models.Scheduled, models.Cancelled, models.Expired, models.Batched}},
+        "$or": bson.A{
+            bson.M{"expiresAt": bson.M{"$exists": false}},
+            bson.M{"expiresAt": bson.M{"$gt": now}},
+        },
+    }
+}
+
+func (repository *MongoRepository) MarkNotificationsReceived(userId uuid.UUID, notificationIDs []primitive.ObjectID, now time.Time) error {
+    if len(notificationIDs) == 0 {
+        return nil
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
     filter := bson.M{
+        "_id": bson.M{"$in": notificationIDs},
         "userId": userId,
         "receivedAt": bson.M{"$exists": false},
     }
+    update := bson.M{
+        "$set": bson.M{"receivedAt": now},
+    }
+
+    _, err := collection.UpdateMany(ctx, filter, update)
+    return err
+}
+
+func (repository *MongoRepository) MarkAllNotificationsReceived(userId uuid.UUID, now time.Time) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    update := bson.M{
+        "$set": bson.M{"receivedAt": now},
+    }
+
+    _, err := collection.UpdateMany(ctx, unreadFilter(userId, now), update)
+    return err
+}
+
+func (repository *MongoRepository) FindNotifications(notificationFilter NotificationFilter) ([]models.Notification, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{
+        "userId": notificationFilter.UserID,
+        "channels": bson.M{"$exists": false},
+    }
+    if notificationFilter.Status != "" {
+        filter["deliveryStatus.notificationStatus"] = notificationFilter.Status
+    }
+    if notificationFilter.Type != "" {
+        filter["type"] = notificationFilter.Type
+    }
+    createdAt := bson.M{}
+    if notificationFilter.CreatedFrom != nil {
+        createdAt["$gte"] = *notificationFilter.CreatedFrom
+    }
+    if notificationFilter.CreatedTo != nil {
+        createdAt["$lte"] = *notificationFilter.CreatedTo
+    }
+    if len(createdAt) > 0 {
+        filter["createdAt"] = createdAt
+    }
+
+    findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+    if notificationFilter.Limit > 0 {
+        findOptions.SetLimit(notificationFilter.Limit)
+    }
+    if notificationFilter.Offset > 0 {
+        findOptions.SetSkip(notificationFilter.Offset)
+    }
 
-    cursor, err := collection.Find(ctx, filter)
+    cursor, err := collection.Find(ctx, filter, findOptions)
     if err != nil {
         return nil, err
     }
 
-    var notifications []models.Notification
+    notifications := []models.Notification{}
+    if err = cursor.All(ctx, &notifications); err != nil {
+        return nil, err
+    }
+
+    return notifications, nil
+}
+
+func (repository *MongoRepository) GetNotificationByID(notificationID primitive.ObjectID) (*models.Notification, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    var notification models.Notification
+    err := collection.FindOne(ctx, bson.M{"_id": notificationID}).Decode(&notification)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, nil
+        }
+        return nil, err
+    }
+
+    return &notification, nil
+}
+
+func (repository *MongoRepository) GetChildNotifications(parentID primitive.ObjectID) ([]models.Notification, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+    cursor, err := collection.Find(ctx, bson.M{"parentId": parentID}, findOptions)
+    if err != nil {
+        return nil, err
+    }
+
+    notifications := []models.Notification{}
     if err = cursor.All(ctx, &notifications); err != nil {
         return nil, err
     }
@@ -84,8 +346,9 @@ func (repository *MongoRepository) GetUnsentNotifications(externalId uuid.UUID)
 
     filter := bson.M{
         "externalId": externalId,
+        "parentId": bson.M{"$exists": false},
         "deliveryStatus.notificationStatus": bson.M{
-            "$in": bson.A{models.Failed, models.Pending},
+            "$in": bson.A{models.Failed, models.Pending, models.Deferred, models.Scheduled, models.Cancelled, models.Batched, models.Suppressed, models.Expired},
             },
     }
     var notification models.Notification
@@ -117,4 +380,310 @@ func (repository *MongoRepository) UpdateNotificationStatus(notificationID primi
 
     _, err := collection.UpdateOne(ctx, filter, update)
     return err
-}
\ No newline at end of file
+}
+
+func (repository *MongoRepository) AddDeliveryAttempt(notificationID primitive.ObjectID, attempt models.DeliveryAttempt) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{"_id": notificationID}
+    update := bson.M{
+        "$push": bson.M{
+            "deliveryAttempts": attempt,
+        },
+    }
+
+    _, err := collection.UpdateOne(ctx, filter, update)
+    return err
+}
+
+func (repository *MongoRepository) UpdateFallbackState(notificationID primitive.ObjectID, notificationType models.NotificationType, status models.DeliveryStatus, state *models.FallbackState) error {
+    status.UpdatedAt = time.Now()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{"_id": notificationID}
+    update := bson.M{
+        "$set": bson.M{
+            "type":           notificationType,
+            "deliveryStatus": status,
+            "fallback":       state,
+        },
+    }
+
+    _, err := collection.UpdateOne(ctx, filter, update)
+    return err
+}
+
+func (repository *MongoRepository) ClaimDueFallback(now time.Time, lease time.Duration) (*models.Notification, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{"fallback.checkAt": bson.M{"$lte": now}}
+    update := bson.M{
+        "$set": bson.M{"fallback.checkAt": now.Add(lease)},
+    }
+    updateOptions := options.FindOneAndUpdate().
+        SetSort(bson.D{{Key: "fallback.checkAt", Value: 1}}).
+        SetReturnDocument(options.After)
+
+    var notification models.Notification
+    err := collection.FindOneAndUpdate(ctx, filter, update, updateOptions).Decode(&notification)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, nil
+        }
+        return nil, err
+    }
+
+    return &notification, nil
+}
+
+func (repository *MongoRepository) DeferNotification(notification *models.Notification) error {
+    notification.DeliveryStatus.UpdatedAt = time.Now()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{"_id": notification.ID}
+    update := bson.M{
+        "$set": bson.M{
+            "type":            notification.Type,
+            "deliveryStatus":  notification.DeliveryStatus,
+            "deliverAt":       notification.DeliverAt,
+            "fallback":        notification.Fallback,
+            "releaseAttempts": notification.ReleaseAttempts,
+        },
+    }
+
+    _, err := collection.UpdateOne(ctx, filter, update)
+    return err
+}
+
+func (repository *MongoRepository) ClaimDueNotification(now time.Time, lease time.Duration) (*models.Notification, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{
+        "deliveryStatus.notificationStatus": bson.M{"$in": bson.A{models.Deferred, models.Scheduled}},
+        "deliverAt": bson.M{"$lte": now},
+    }
+    update := bson.M{
+        "$set": bson.M{"deliverAt": now.Add(lease), "dispatchedAt": now},
+    }
+    updateOptions := options.FindOneAndUpdate().
+        SetSort(bson.D{{Key: "deliverAt", Value: 1}}).
+        SetReturnDocument(options.After)
+
+    var notification models.Notification
+    err := collection.FindOneAndUpdate(ctx, filter, update, updateOptions).Decode(&notification)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, nil
+        }
+        return nil, err
+    }
+
+    return &notification, nil
+}
+
+func (repository *MongoRepository) CancelScheduled(externalId uuid.UUID) (int64, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{
+        "externalId": externalId,
+        "deliveryStatus.notificationStatus": models.Scheduled,
+        "dispatchedAt": bson.M{"$exists": false},
+    }
+    update := bson.M{
+        "$set": bson.M{
+            "deliveryStatus": models.DeliveryStatus{
+                NotificationStatus: models.Cancelled,
+                UpdatedAt:          time.Now(),
+            },
+        },
+    }
+
+    result, err := collection.UpdateMany(ctx, filter, update)
+    if err != nil {
+        return 0, err
+    }
+    return result.ModifiedCount, nil
+}
+
+func (repository *MongoRepository) GetOpenDigest(userId uuid.UUID, frequency models.DigestFrequency) (*models.Notification, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{
+        "userId": userId,
+        "digest.frequency": frequency,
+        "deliveryStatus.notificationStatus": models.Scheduled,
+        "dispatchedAt": bson.M{"$exists": false},
+    }
+    findOptions := options.FindOne().SetSort(bson.D{{Key: "deliverAt", Value: 1}})
+
+    var notification models.Notification
+    err := collection.FindOne(ctx, filter, findOptions).Decode(&notification)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, nil
+        }
+        return nil, err
+    }
+
+    return &notification, nil
+}
+
+func (repository *MongoRepository) AttachToDigest(notificationID, digestID primitive.ObjectID) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{"_id": notificationID}
+    update := bson.M{
+        "$set": bson.M{
+            "digestId": digestID,
+            "deliveryStatus": models.DeliveryStatus{
+                NotificationStatus: models.Batched,
+                UpdatedAt:          time.Now(),
+            },
+        },
+    }
+
+    _, err := collection.UpdateOne(ctx, filter, update)
+    return err
+}
+
+func (repository *MongoRepository) MoveToDigest(notificationID, from, to primitive.ObjectID) (bool, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{
+        "_id": notificationID,
+        "digestId": from,
+        "deliveryStatus.notificationStatus": models.Batched,
+    }
+    update := bson.M{
+        "$set": bson.M{"digestId": to, "deliveryStatus.updatedAt": time.Now()},
+    }
+
+    result, err := collection.UpdateOne(ctx, filter, update)
+    if err != nil {
+        return false, err
+    }
+    return result.ModifiedCount > 0, nil
+}
+
+func (repository *MongoRepository) CollectDigestItems(digestID primitive.ObjectID) ([]models.Notification, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{
+        "digestId": digestID,
+        "deliveryStatus.notificationStatus": models.Batched,
+    }
+    update := bson.M{
+        "$set": bson.M{
+            "deliveryStatus": models.DeliveryStatus{
+                NotificationStatus: models.Digested,
+                UpdatedAt:          time.Now(),
+            },
+        },
+    }
+    if _, err := collection.UpdateMany(ctx, filter, update); err != nil {
+        return nil, err
+    }
+
+    filter = bson.M{
+        "digestId": digestID,
+        "deliveryStatus.notificationStatus": models.Digested,
+    }
+    findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+    cursor, err := collection.Find(ctx, filter, findOptions)
+    if err != nil {
+        return nil, err
+    }
+
+    notifications := []models.Notification{}
+    if err = cursor.All(ctx, &notifications); err != nil {
+        return nil, err
+    }
+
+    return notifications, nil
+}
+
+func (repository *MongoRepository) FailDigestItems(digestID primitive.ObjectID, reason string) ([]models.Notification, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{
+        "digestId": digestID,
+        "deliveryStatus.notificationStatus": models.Digested,
+    }
+    cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+    if err != nil {
+        return nil, err
+    }
+    notifications := []models.Notification{}
+    if err = cursor.All(ctx, &notifications); err != nil {
+        return nil, err
+    }
+
+    status := models.DeliveryStatus{
+        NotificationStatus: models.Failed,
+        Error:              reason,
+        UpdatedAt:          time.Now(),
+    }
+    if _, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"deliveryStatus": status}}); err != nil {
+        return nil, err
+    }
+    for i := range notifications {
+        notifications[i].DeliveryStatus = status
+    }
+    return notifications, nil
+}
+
+func (repository *MongoRepository) UpdateDigestContent(notification *models.Notification) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    collection := repository.client.Database(repository.database).Collection(repository.collection)
+
+    filter := bson.M{"_id": notification.ID}
+    update := bson.M{
+        "$set": bson.M{
+            "subject":  notification.Subject,
+            "body":     notification.Body,
+            "mailInfo": notification.MailInfo,
+            "template": notification.Template,
+            "digest":   notification.Digest,
+        },
+    }
+
+    _, err := collection.UpdateOne(ctx, filter, update)
+    return err
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"testing"
+
+	"notificationservice/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// listedIndex decodes an index spec the way listing the indexes does.
+func listedIndex(t *testing.T, spec bson.D) bson.M {
+	t.Helper()
+	data, err := bson.Marshal(spec)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var listed bson.M
+	if err := bson.Unmarshal(data, &listed); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return listed
+}
+
+func TestOnlyExpires(t *testing.T) {
+	index := func(partialFilter interface{}) bson.D {
+		spec := bson.D{
+			{Key: "v", Value: 2},
+			{Key: "key", Value: bson.D{{Key: "expiresAt", Value: 1}}},
+			{Key: "name", Value: expiryIndexName},
+			{Key: "expireAfterSeconds", Value: int32(86400)},
+		}
+		if partialFilter != nil {
+			spec = append(spec, bson.E{Key: "partialFilterExpression", Value: partialFilter})
+		}
+		return spec
+	}
+
+	tests := []struct {
+		name string
+		spec bson.D
+		want bool
+	}{
+		{"current index", index(bson.D{{Key: "deliveryStatus.notificationStatus", Value: models.Expired}}), true},
+		{"index from before the partial filter", index(nil), false},
+		{"filter on another status", index(bson.D{{Key: "deliveryStatus.notificationStatus", Value: models.Sent}}), false},
+		{"wider filter", index(bson.D{{Key: "expiresAt", Value: bson.D{{Key: "$exists", Value: true}}}}), false},
+		{
+			"narrower filter",
+			index(bson.D{
+				{Key: "deliveryStatus.notificationStatus", Value: models.Expired},
+				{Key: "userId", Value: bson.D{{Key: "$exists", Value: true}}},
+			}),
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := onlyExpires(listedIndex(t, test.spec)); got != test.want {
+				t.Errorf("onlyExpires = %t, want %t", got, test.want)
+			}
+		})
+	}
+
+	if onlyExpires(nil) {
+		t.Error("a missing index is not limited to expired notifications")
+	}
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoWebhookRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoWebhookRepository(database *mongo.Database) *MongoWebhookRepository {
+	return &MongoWebhookRepository{collection: database.Collection("webhooks")}
+}
+
+func (repository *MongoWebhookRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := repository.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "tenantId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (repository *MongoWebhookRepository) SaveWebhook(webhook *models.Webhook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"userId": webhook.UserID, "tenantId": webhook.TenantID}
+	update := bson.M{
+		"$set": bson.M{
+			"url":       webhook.URL,
+			"secret":    webhook.Secret,
+			"updatedAt": now,
+		},
+		"$setOnInsert": bson.M{
+			"createdAt": now,
+		},
+	}
+	updateOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	return repository.collection.FindOneAndUpdate(ctx, filter, update, updateOptions).Decode(webhook)
+}
+
+func (repository *MongoWebhookRepository) GetWebhook(userId uuid.UUID, tenantId string) (*models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var webhook models.Webhook
+	err := repository.collection.FindOne(ctx, bson.M{"userId": userId, "tenantId": tenantId}).Decode(&webhook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (repository *MongoWebhookRepository) DeleteWebhook(userId uuid.UUID, tenantId string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := repository.collection.DeleteOne(ctx, bson.M{"userId": userId, "tenantId": tenantId})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
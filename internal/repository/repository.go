@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"time"
+
+	"notificationservice/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type NotificationFilter struct {
+	UserID      uuid.UUID
+	Status      models.NotificationStatus
+	Type        models.NotificationType
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Limit       int64
+	Offset      int64
+}
+
+type NotificationRepository interface {
+	SaveNotification(notification *models.Notification) error
+	GetUnsentNotifications(externalId uuid.UUID) (*models.Notification, error)
+	// ReplaceNotification overwrites the stored notification with the same ID.
+	ReplaceNotification(notification *models.Notification) error
+	UpdateNotificationStatus(notificationID primitive.ObjectID, status models.DeliveryStatus) error
+	// The unread methods take now to tell which notifications have expired
+	// and to stamp receivedAt.
+	GetUnreadNotifications(userId uuid.UUID, now time.Time) ([]models.Notification, error)
+	CountUnreadNotifications(userId uuid.UUID, now time.Time) (int64, error)
+	MarkNotificationsReceived(userId uuid.UUID, notificationIDs []primitive.ObjectID, now time.Time) error
+	MarkAllNotificationsReceived(userId uuid.UUID, now time.Time) error
+	FindNotifications(notificationFilter NotificationFilter) ([]models.Notification, error)
+	GetNotificationByID(notificationID primitive.ObjectID) (*models.Notification, error)
+	GetChildNotifications(parentID primitive.ObjectID) ([]models.Notification, error)
+	AddDeliveryAttempt(notificationID primitive.ObjectID, attempt models.DeliveryAttempt) error
+	UpdateFallbackState(notificationID primitive.ObjectID, notificationType models.NotificationType, status models.DeliveryStatus, state *models.FallbackState) error
+	// ClaimDueFallback returns a notification whose fallback check is due and
+	// pushes its check back by lease, so other instances skip it meanwhile.
+	ClaimDueFallback(now time.Time, lease time.Duration) (*models.Notification, error)
+	// DeferNotification stores the notification's type, status, deliverAt,
+	// fallback state and release attempts.
+	DeferNotification(notification *models.Notification) error
+	// ClaimDueNotification returns a deferred or scheduled notification whose
+	// deliverAt has passed, pushes deliverAt back by lease and sets
+	// dispatchedAt.
+	ClaimDueNotification(now time.Time, lease time.Duration) (*models.Notification, error)
+	// CancelScheduled cancels the scheduled notifications of a message that
+	// have not been dispatched yet and returns how many there were.
+	CancelScheduled(externalId uuid.UUID) (int64, error)
+	// GetOpenDigest returns the user's digest of the given frequency that is
+	// still collecting notifications, if any.
+	GetOpenDigest(userId uuid.UUID, frequency models.DigestFrequency) (*models.Notification, error)
+	// AttachToDigest marks a notification as batched into a digest.
+	AttachToDigest(notificationID, digestID primitive.ObjectID) error
+	// MoveToDigest moves a batched notification to another digest, unless the
+	// digest it is in has collected it already.
+	MoveToDigest(notificationID, from, to primitive.ObjectID) (bool, error)
+	// CollectDigestItems marks the notifications batched into a digest as
+	// digested and returns all it carries.
+	CollectDigestItems(digestID primitive.ObjectID) ([]models.Notification, error)
+	// FailDigestItems marks the notifications a digest collected as failed
+	// with the given error and returns them.
+	FailDigestItems(digestID primitive.ObjectID, reason string) ([]models.Notification, error)
+	// UpdateDigestContent stores a digest's rendered subject, body, mail
+	// details, template and digest info.
+	UpdateDigestContent(notification *models.Notification) error
+}
+
+type DeviceRepository interface {
+	SaveDevice(device *models.Device) error
+	GetDevices(userId uuid.UUID) ([]models.Device, error)
+	DeleteDevice(userId uuid.UUID, token string) (bool, error)
+	DeleteDevicesByToken(tokens []string) error
+}
+
+// WebhookRepository looks webhooks up by owner: a user ID with an empty
+// tenant, or uuid.Nil with a tenant ID.
+type WebhookRepository interface {
+	SaveWebhook(webhook *models.Webhook) error
+	GetWebhook(userId uuid.UUID, tenantId string) (*models.Webhook, error)
+	DeleteWebhook(userId uuid.UUID, tenantId string) (bool, error)
+}
+
+type PreferenceRepository interface {
+	SavePreference(preference *models.Preference) error
+	GetPreference(userId uuid.UUID, category string) (*models.Preference, error)
+	GetPreferences(userId uuid.UUID) ([]models.Preference, error)
+	DeletePreference(userId uuid.UUID, category string) (bool, error)
+}
+
+// AttachmentRepository keeps attachment content apart from the notification
+// documents, which refer to it by ID.
+type AttachmentRepository interface {
+	SaveAttachmentContent(content []byte) (primitive.ObjectID, error)
+	// GetAttachmentContent returns nil if the content no longer exists.
+	GetAttachmentContent(id primitive.ObjectID) ([]byte, error)
+}
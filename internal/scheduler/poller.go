@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Poller runs a task at a fixed interval until it is shut down. Runs never
+// overlap; a run that overruns the interval delays the next one.
+type Poller struct {
+	name     string
+	interval time.Duration
+	task     func() error
+
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+func NewPoller(name string, interval time.Duration, task func() error) *Poller {
+	return &Poller{
+		name:     name,
+		interval: interval,
+		task:     task,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+func (poller *Poller) Start() {
+	go poller.run()
+}
+
+func (poller *Poller) run() {
+	defer close(poller.stopped)
+
+	ticker := time.NewTicker(poller.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-poller.done:
+			return
+		case <-ticker.C:
+			if err := poller.task(); err != nil {
+				log.Printf("%s failed: %v", poller.name, err)
+			}
+		}
+	}
+}
+
+// Shutdown stops the poller and waits for a run in progress to finish.
+func (poller *Poller) Shutdown(ctx context.Context) error {
+	poller.closeOnce.Do(func() { close(poller.done) })
+
+	select {
+	case <-poller.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
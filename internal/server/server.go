@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+type Server struct {
+	mux        *http.ServeMux
+	httpServer *http.Server
+}
+
+func NewServer(port string) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		mux: mux,
+		httpServer: &http.Server{
+			Addr:              ":" + port,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+func (server *Server) Handle(pattern string, handler http.Handler) {
+	server.mux.Handle(pattern, handler)
+}
+
+func (server *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	server.mux.HandleFunc(pattern, handler)
+}
+
+func (server *Server) Start() error {
+	listener, err := net.Listen("tcp", server.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", server.httpServer.Addr, err)
+	}
+
+	go func() {
+		if err := server.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP server stopped unexpectedly: %v", err)
+		}
+	}()
+
+	log.Printf("HTTP server listening on %s", listener.Addr())
+	return nil
+}
+
+func (server *Server) Shutdown(ctx context.Context) error {
+	return server.httpServer.Shutdown(ctx)
+}
@@ -0,0 +1,84 @@
+package sms
+
+import "unicode/utf16"
+
+type Encoding string
+
+const (
+	GSM7 Encoding = "GSM-7"
+	UCS2 Encoding = "UCS-2"
+)
+
+const (
+	gsm7SingleLimit    = 160
+	gsm7SegmentLimit   = 153
+	ucs2SingleLimit    = 70
+	ucs2SegmentLimit   = 67
+	DefaultMaxSegments = 10
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet without the escape character.
+// gsm7Extension characters need an escape and take two septets.
+var (
+	gsm7Basic     = toSet("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+	gsm7Extension = toSet("\f^{}\\[~]|€")
+)
+
+type Segmentation struct {
+	Encoding Encoding
+	// Units counts septets for GSM-7 and UTF-16 code units for UCS-2.
+	Units    int
+	Segments int
+}
+
+// Segment works out how a body is split into concatenated SMS parts. A
+// character is never split across parts: an escaped GSM-7 character or a
+// UTF-16 surrogate pair that does not fit starts the next part.
+func Segment(body string) Segmentation {
+	encoding := GSM7
+	for _, r := range body {
+		if !gsm7Basic[r] && !gsm7Extension[r] {
+			encoding = UCS2
+			break
+		}
+	}
+
+	singleLimit, segmentLimit := gsm7SingleLimit, gsm7SegmentLimit
+	if encoding == UCS2 {
+		singleLimit, segmentLimit = ucs2SingleLimit, ucs2SegmentLimit
+	}
+
+	units := 0
+	segments, used := 1, 0
+	for _, r := range body {
+		width := unitWidth(encoding, r)
+		units += width
+		if used+width > segmentLimit {
+			segments++
+			used = 0
+		}
+		used += width
+	}
+	if units <= singleLimit {
+		segments = 1
+	}
+	return Segmentation{Encoding: encoding, Units: units, Segments: segments}
+}
+
+func unitWidth(encoding Encoding, r rune) int {
+	if encoding == UCS2 {
+		return utf16.RuneLen(r)
+	}
+	if gsm7Extension[r] {
+		return 2
+	}
+	return 1
+}
+
+func toSet(characters string) map[rune]bool {
+	set := make(map[rune]bool)
+	for _, r := range characters {
+		set[r] = true
+	}
+	return set
+}
@@ -0,0 +1,43 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSegment(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want Segmentation
+	}{
+		{"empty", "", Segmentation{GSM7, 0, 1}},
+		{"single GSM-7 part", strings.Repeat("a", 160), Segmentation{GSM7, 160, 1}},
+		{"two GSM-7 parts", strings.Repeat("a", 161), Segmentation{GSM7, 161, 2}},
+		{"full second GSM-7 part", strings.Repeat("a", 306), Segmentation{GSM7, 306, 2}},
+		{"third GSM-7 part", strings.Repeat("a", 307), Segmentation{GSM7, 307, 3}},
+		{"extension characters take two septets", strings.Repeat("€", 80), Segmentation{GSM7, 160, 1}},
+		{"extension characters overflow", strings.Repeat("€", 81), Segmentation{GSM7, 162, 2}},
+		{
+			"escaped character is not split across parts",
+			strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152),
+			Segmentation{GSM7, 306, 3},
+		},
+		{"GSM-7 accents stay GSM-7", "Grüße aus Köln", Segmentation{GSM7, 14, 1}},
+		{"one non-GSM character switches to UCS-2", strings.Repeat("a", 69) + "я", Segmentation{UCS2, 70, 1}},
+		{"two UCS-2 parts", strings.Repeat("я", 71), Segmentation{UCS2, 71, 2}},
+		{"surrogate pairs count twice", "😀😀", Segmentation{UCS2, 4, 1}},
+		{
+			"surrogate pair is not split across parts",
+			strings.Repeat("я", 66) + "😀" + strings.Repeat("я", 66),
+			Segmentation{UCS2, 134, 3},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Segment(test.body); got != test.want {
+				t.Errorf("Segment() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"notificationservice/internal/errors"
+)
+
+const (
+	defaultTimeout  = 10 * time.Second
+	maxErrorBodyLen = 512
+)
+
+type HTTPProviderConfig struct {
+	URL     string
+	APIKey  string
+	From    string
+	Timeout time.Duration
+}
+
+// HTTPProvider posts each message as JSON to a provider endpoint and reads
+// the outcome from the status code.
+type HTTPProvider struct {
+	config HTTPProviderConfig
+	client *http.Client
+}
+
+type sendRequest struct {
+	To       string   `json:"to"`
+	From     string   `json:"from,omitempty"`
+	Body     string   `json:"body"`
+	Encoding Encoding `json:"encoding"`
+	Segments int      `json:"segments"`
+}
+
+func NewHTTPProvider(config HTTPProviderConfig) *HTTPProvider {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &HTTPProvider{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+func (provider *HTTPProvider) Send(message *Message) error {
+	from := message.From
+	if from == "" {
+		from = provider.config.From
+	}
+	payload, err := json.Marshal(sendRequest{
+		To:       message.To,
+		From:     from,
+		Body:     message.Body,
+		Encoding: message.Encoding,
+		Segments: message.Segments,
+	})
+	if err != nil {
+		return errors.NewProcessingError("failed to serialize SMS", err)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, provider.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return errors.NewProcessingError("failed to build SMS provider request", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if provider.config.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+provider.config.APIKey)
+	}
+
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return errors.NewRetriableError("SMS provider request failed", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		io.Copy(io.Discard, response.Body)
+		return nil
+	}
+	return classifyResponse(response)
+}
+
+func classifyResponse(response *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodyLen))
+	description := fmt.Sprintf("SMS provider returned %s", response.Status)
+	if detail := strings.TrimSpace(string(body)); detail != "" {
+		description += ": " + detail
+	}
+
+	switch {
+	case response.StatusCode == http.StatusRequestTimeout ||
+		response.StatusCode == http.StatusTooManyRequests ||
+		response.StatusCode >= 500:
+		return errors.NewRetriableError(description, nil)
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		// Our credentials, not the message, are at fault
+		return errors.NewProcessingError(description, nil)
+	case response.StatusCode >= 400:
+		return errors.NewValidationError(description, nil)
+	}
+	return errors.NewProcessingError(description, nil)
+}
@@ -0,0 +1,99 @@
+package sms
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"notificationservice/internal/errors"
+)
+
+func TestHTTPProviderSend(t *testing.T) {
+	var received sendRequest
+	var authorization, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(HTTPProviderConfig{URL: server.URL, APIKey: "key", From: "+15550000000"})
+	segmentation := Segment("Grüße")
+	err := provider.Send(&Message{To: "+14155552671", Body: "Grüße", Encoding: segmentation.Encoding, Segments: segmentation.Segments})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	want := sendRequest{To: "+14155552671", From: "+15550000000", Body: "Grüße", Encoding: GSM7, Segments: 1}
+	if received != want {
+		t.Errorf("request = %+v, want %+v", received, want)
+	}
+	if authorization != "Bearer key" {
+		t.Errorf("Authorization = %q, want Bearer key", authorization)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+}
+
+func TestHTTPProviderSendPrefersMessageSender(t *testing.T) {
+	var received sendRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(HTTPProviderConfig{URL: server.URL, From: "+15550000000"})
+	if err := provider.Send(&Message{To: "+14155552671", From: "Acme", Body: "Hi"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if received.From != "Acme" {
+		t.Errorf("from = %q, want Acme", received.From)
+	}
+}
+
+func TestHTTPProviderErrorMapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   errors.ErrorType
+	}{
+		{"rate limited", http.StatusTooManyRequests, errors.RetriableError},
+		{"request timeout", http.StatusRequestTimeout, errors.RetriableError},
+		{"server error", http.StatusInternalServerError, errors.RetriableError},
+		{"unavailable", http.StatusServiceUnavailable, errors.RetriableError},
+		{"bad number", http.StatusBadRequest, errors.ValidationError},
+		{"unprocessable", http.StatusUnprocessableEntity, errors.ValidationError},
+		{"bad credentials", http.StatusUnauthorized, errors.ProcessingError},
+		{"forbidden", http.StatusForbidden, errors.ProcessingError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "provider detail", test.status)
+			}))
+			defer server.Close()
+
+			err := NewHTTPProvider(HTTPProviderConfig{URL: server.URL}).Send(&Message{To: "+14155552671", Body: "Hi"})
+			if got := errors.GetErrorType(err); got != test.want {
+				t.Errorf("error type = %q, want %q (%v)", got, test.want, err)
+			}
+		})
+	}
+}
+
+func TestHTTPProviderUnreachableIsRetriable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	err := NewHTTPProvider(HTTPProviderConfig{URL: url}).Send(&Message{To: "+14155552671", Body: "Hi"})
+	if !errors.IsRetriableError(err) {
+		t.Errorf("error = %v, want retriable", err)
+	}
+}
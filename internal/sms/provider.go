@@ -0,0 +1,29 @@
+package sms
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type Provider interface {
+	Send(message *Message) error
+}
+
+type Message struct {
+	To       string
+	From     string
+	Body     string
+	Encoding Encoding
+	Segments int
+}
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// ValidatePhoneNumber accepts only the E.164 form, "+" followed by the
+// country code and subscriber number without spaces or punctuation.
+func ValidatePhoneNumber(number string) error {
+	if !e164Pattern.MatchString(number) {
+		return fmt.Errorf("phone number %q is not in E.164 format", number)
+	}
+	return nil
+}
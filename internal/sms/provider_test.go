@@ -0,0 +1,29 @@
+package sms
+
+import "testing"
+
+func TestValidatePhoneNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		valid  bool
+	}{
+		{"+14155552671", true},
+		{"+447911123456", true},
+		{"+491", true},
+		{"+123456789012345", true},
+		{"14155552671", false},
+		{"+1 415 555 2671", false},
+		{"+1-415-555-2671", false},
+		{"+0123456789", false},
+		{"+1234567890123456", false},
+		{"+1", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		t.Run(test.number, func(t *testing.T) {
+			if err := ValidatePhoneNumber(test.number); (err == nil) != test.valid {
+				t.Errorf("ValidatePhoneNumber(%q) = %v, want valid %v", test.number, err, test.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	subjectFile  = "subject.tmpl"
+	htmlBodyFile = "body.html.tmpl"
+	textBodyFile = "body.txt.tmpl"
+)
+
+// FileStore reads templates from a directory laid out as
+//
+//	<root>/<templateId>/v<version>/subject.tmpl
+//	<root>/<templateId>/v<version>/body.html.tmpl
+//	<root>/<templateId>/v<version>/body.txt.tmpl
+//
+// Either body file may be omitted.
+type FileStore struct {
+	root string
+}
+
+func NewFileStore(root string) *FileStore {
+	return &FileStore{root: root}
+}
+
+func (store *FileStore) GetTemplate(templateID string, version int) (*Template, error) {
+	if templateID == "" || strings.ContainsAny(templateID, `/\`) || templateID == "." || templateID == ".." {
+		return nil, nil
+	}
+	templateDir := filepath.Join(store.root, templateID)
+
+	if version <= 0 {
+		latest, err := store.latestVersion(templateDir)
+		if err != nil || latest == 0 {
+			return nil, err
+		}
+		version = latest
+	}
+
+	versionDir := filepath.Join(templateDir, fmt.Sprintf("v%d", version))
+	info, err := os.Stat(versionDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	template := &Template{ID: templateID, Version: version, CreatedAt: info.ModTime()}
+	if template.Subject, err = readOptional(filepath.Join(versionDir, subjectFile)); err != nil {
+		return nil, err
+	}
+	if template.HTMLBody, err = readOptional(filepath.Join(versionDir, htmlBodyFile)); err != nil {
+		return nil, err
+	}
+	if template.TextBody, err = readOptional(filepath.Join(versionDir, textBodyFile)); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func (store *FileStore) latestVersion(templateDir string) (int, error) {
+	entries, err := os.ReadDir(templateDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	latest := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "v") {
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "v"))
+		if err == nil && version > latest {
+			latest = version
+		}
+	}
+	return latest, nil
+}
+
+func readOptional(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,88 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemplateFile(t *testing.T, root, templateID, version, name, content string) {
+	t.Helper()
+	dir := filepath.Join(root, templateID, version)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileStoreGetTemplate(t *testing.T) {
+	root := t.TempDir()
+	writeTemplateFile(t, root, "welcome", "v1", subjectFile, "Welcome")
+	writeTemplateFile(t, root, "welcome", "v1", textBodyFile, "Hello")
+	writeTemplateFile(t, root, "welcome", "v2", subjectFile, "Welcome, {{.name}}")
+	writeTemplateFile(t, root, "welcome", "v2", htmlBodyFile, "<p>Hello {{.name}}</p>")
+	writeTemplateFile(t, root, "welcome", "v10", subjectFile, "Welcome back")
+	writeTemplateFile(t, root, "welcome", "v10", textBodyFile, "Hello again")
+	writeTemplateFile(t, root, "welcome", "draft", subjectFile, "Not a version")
+	store := NewFileStore(root)
+
+	tests := []struct {
+		name        string
+		templateID  string
+		version     int
+		wantVersion int
+		wantSubject string
+		wantHTML    string
+		wantText    string
+	}{
+		{"latest version", "welcome", 0, 10, "Welcome back", "", "Hello again"},
+		{"specific version", "welcome", 1, 1, "Welcome", "", "Hello"},
+		{"HTML only", "welcome", 2, 2, "Welcome, {{.name}}", "<p>Hello {{.name}}</p>", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template, err := store.GetTemplate(test.templateID, test.version)
+			if err != nil {
+				t.Fatalf("GetTemplate: %v", err)
+			}
+			if template == nil {
+				t.Fatal("template not found")
+			}
+			if template.ID != test.templateID || template.Version != test.wantVersion {
+				t.Errorf("got %s version %d, want %s version %d", template.ID, template.Version, test.templateID, test.wantVersion)
+			}
+			if template.Subject != test.wantSubject || template.HTMLBody != test.wantHTML || template.TextBody != test.wantText {
+				t.Errorf("template = %+v", template)
+			}
+		})
+	}
+}
+
+func TestFileStoreUnknownTemplates(t *testing.T) {
+	root := t.TempDir()
+	writeTemplateFile(t, root, "welcome", "v1", subjectFile, "Welcome")
+	writeTemplateFile(t, filepath.Dir(root), "secret", "v1", subjectFile, "Outside the store")
+	store := NewFileStore(root)
+
+	tests := []struct {
+		name       string
+		templateID string
+		version    int
+	}{
+		{"unknown template", "goodbye", 0},
+		{"unknown version", "welcome", 2},
+		{"empty ID", "", 0},
+		{"path in ID", "../secret", 1},
+		{"parent directory", "..", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template, err := store.GetTemplate(test.templateID, test.version)
+			if err != nil || template != nil {
+				t.Errorf("GetTemplate = %+v, %v, want nil, nil", template, err)
+			}
+		})
+	}
+}
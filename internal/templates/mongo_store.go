@@ -0,0 +1,72 @@
+package templates
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps one document per template version in the templates
+// collection; publishing a change means inserting the next version.
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoStore(database *mongo.Database) *MongoStore {
+	return &MongoStore{collection: database.Collection("templates")}
+}
+
+func (store *MongoStore) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := store.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "templateId", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (store *MongoStore) GetTemplate(templateID string, version int) (*Template, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"templateId": templateID}
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	if version > 0 {
+		filter["version"] = version
+	}
+
+	var template Template
+	err := store.collection.FindOne(ctx, filter, findOptions).Decode(&template)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (store *MongoStore) SaveTemplate(template *Template) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if template.Version <= 0 {
+		latest, err := store.GetTemplate(template.ID, 0)
+		if err != nil {
+			return err
+		}
+		template.Version = 1
+		if latest != nil {
+			template.Version = latest.Version + 1
+		}
+	}
+	template.CreatedAt = time.Now()
+
+	_, err := store.collection.InsertOne(ctx, template)
+	return err
+}
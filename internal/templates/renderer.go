@@ -0,0 +1,131 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"notificationservice/internal/errors"
+)
+
+type compiledTemplate struct {
+	subject  *texttemplate.Template
+	htmlBody *htmltemplate.Template
+	textBody *texttemplate.Template
+}
+
+type Renderer struct {
+	store    Store
+	mutex    sync.RWMutex
+	compiled map[string]*compiledTemplate
+}
+
+func NewRenderer(store Store) *Renderer {
+	return &Renderer{
+		store:    store,
+		compiled: make(map[string]*compiledTemplate),
+	}
+}
+
+func (renderer *Renderer) Render(templateID string, version int, data map[string]interface{}) (*Rendered, error) {
+	template, err := renderer.store.GetTemplate(templateID, version)
+	if err != nil {
+		return nil, errors.NewRetriableError("failed to load template", err)
+	}
+	if template == nil {
+		if version > 0 {
+			return nil, errors.NewValidationError(fmt.Sprintf("unknown template %s version %d", templateID, version), nil)
+		}
+		return nil, errors.NewValidationError(fmt.Sprintf("unknown template %s", templateID), nil)
+	}
+
+	compiled, err := renderer.compile(template)
+	if err != nil {
+		return nil, errors.NewProcessingError(fmt.Sprintf("template %s version %d is invalid", template.ID, template.Version), err)
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	rendered := &Rendered{TemplateID: template.ID, Version: template.Version}
+	if rendered.Subject, err = executeText(compiled.subject, data); err != nil {
+		return nil, renderError(template, "subject", err)
+	}
+	if compiled.htmlBody != nil {
+		var buffer bytes.Buffer
+		if err := compiled.htmlBody.Execute(&buffer, data); err != nil {
+			return nil, renderError(template, "HTML body", err)
+		}
+		rendered.HTMLBody = buffer.String()
+	}
+	if compiled.textBody != nil {
+		if rendered.TextBody, err = executeText(compiled.textBody, data); err != nil {
+			return nil, renderError(template, "text body", err)
+		}
+	}
+	rendered.Subject = strings.TrimSpace(rendered.Subject)
+	return rendered, nil
+}
+
+// HasTemplate reports whether any version of a template exists.
+func (renderer *Renderer) HasTemplate(templateID string) (bool, error) {
+	template, err := renderer.store.GetTemplate(templateID, 0)
+	return template != nil, err
+}
+
+func (renderer *Renderer) compile(template *Template) (*compiledTemplate, error) {
+	key := fmt.Sprintf("%s@%d", template.ID, template.Version)
+
+	renderer.mutex.RLock()
+	compiled, ok := renderer.compiled[key]
+	renderer.mutex.RUnlock()
+	if ok {
+		return compiled, nil
+	}
+
+	compiled = &compiledTemplate{}
+	var err error
+	if compiled.subject, err = texttemplate.New("subject").Option("missingkey=error").Parse(template.Subject); err != nil {
+		return nil, err
+	}
+	if template.HTMLBody != "" {
+		if compiled.htmlBody, err = htmltemplate.New("html").Option("missingkey=error").Parse(template.HTMLBody); err != nil {
+			return nil, err
+		}
+	}
+	if template.TextBody != "" {
+		if compiled.textBody, err = texttemplate.New("text").Option("missingkey=error").Parse(template.TextBody); err != nil {
+			return nil, err
+		}
+	}
+	if compiled.htmlBody == nil && compiled.textBody == nil {
+		return nil, fmt.Errorf("template has neither an HTML nor a text body")
+	}
+
+	// Versions are immutable, so a compiled version never needs invalidating
+	renderer.mutex.Lock()
+	renderer.compiled[key] = compiled
+	renderer.mutex.Unlock()
+	return compiled, nil
+}
+
+func executeText(template *texttemplate.Template, data map[string]interface{}) (string, error) {
+	var buffer bytes.Buffer
+	if err := template.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// Execution only fails on the data side (missing keys, wrong types), so the
+// message is rejected rather than retried.
+func renderError(template *Template, part string, err error) error {
+	return errors.NewValidationError(
+		fmt.Sprintf("failed to render %s of template %s version %d", part, template.ID, template.Version),
+		err,
+	)
+}
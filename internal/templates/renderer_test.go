@@ -0,0 +1,121 @@
+package templates
+
+import (
+	"testing"
+
+	"notificationservice/internal/errors"
+)
+
+// countingStore serves templates by ID and counts the lookups.
+type countingStore struct {
+	templates map[string]*Template
+	lookups   int
+}
+
+func (store *countingStore) GetTemplate(templateID string, version int) (*Template, error) {
+	store.lookups++
+	return store.templates[templateID], nil
+}
+
+func newCountingStore(templates ...*Template) *countingStore {
+	store := &countingStore{templates: map[string]*Template{}}
+	for _, template := range templates {
+		store.templates[template.ID] = template
+	}
+	return store
+}
+
+var welcome = &Template{
+	ID:       "welcome",
+	Version:  2,
+	Subject:  "Welcome, {{.name}}",
+	HTMLBody: "<p>Hello {{.name}}</p>",
+	TextBody: "Hello {{.name}}",
+}
+
+func TestRender(t *testing.T) {
+	renderer := NewRenderer(newCountingStore(welcome))
+
+	rendered, err := renderer.Render("welcome", 0, map[string]interface{}{"name": "<Jane>"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	want := Rendered{
+		TemplateID: "welcome",
+		Version:    2,
+		Subject:    "Welcome, <Jane>",
+		HTMLBody:   "<p>Hello &lt;Jane&gt;</p>",
+		TextBody:   "Hello <Jane>",
+	}
+	if *rendered != want {
+		t.Errorf("rendered = %+v, want %+v", *rendered, want)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	invalid := &Template{ID: "invalid", Version: 1, Subject: "{{.name", TextBody: "Hello"}
+	renderer := NewRenderer(newCountingStore(welcome, invalid))
+
+	// None of these improve with a retry, so the consumer sends the message to
+	// the dead letter queue
+	tests := []struct {
+		name       string
+		templateID string
+		data       map[string]interface{}
+		wantType   errors.ErrorType
+	}{
+		{"missing variable", "welcome", map[string]interface{}{"greeting": "Hi"}, errors.ValidationError},
+		{"unknown template", "goodbye", nil, errors.ValidationError},
+		{"invalid template", "invalid", nil, errors.ProcessingError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := renderer.Render(test.templateID, 0, test.data)
+			if got := errors.GetErrorType(err); got != test.wantType {
+				t.Errorf("error type = %s (%v), want %s", got, err, test.wantType)
+			}
+			if errors.IsRetriableError(err) {
+				t.Errorf("%v is retriable", err)
+			}
+		})
+	}
+}
+
+func TestRenderCachesCompiledVersions(t *testing.T) {
+	store := newCountingStore(welcome)
+	renderer := NewRenderer(store)
+	data := map[string]interface{}{"name": "Jane"}
+
+	first, err := renderer.Render("welcome", 2, data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	compiled := renderer.compiled["welcome@2"]
+	if compiled == nil {
+		t.Fatal("version 2 was not cached")
+	}
+
+	// A version never changes, so the cached compilation is used even though
+	// the store now returns a different body for it
+	store.templates["welcome"] = &Template{ID: "welcome", Version: 2, Subject: "Changed", TextBody: "Changed"}
+	second, err := renderer.Render("welcome", 2, data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if *second != *first || renderer.compiled["welcome@2"] != compiled {
+		t.Errorf("second render = %+v, want the cached %+v", *second, *first)
+	}
+	if len(renderer.compiled) != 1 || store.lookups != 2 {
+		t.Errorf("compiled %d versions after %d lookups, want 1 after 2", len(renderer.compiled), store.lookups)
+	}
+
+	// A new version is compiled separately
+	store.templates["welcome"] = &Template{ID: "welcome", Version: 3, Subject: "Hi {{.name}}", TextBody: "Hi"}
+	third, err := renderer.Render("welcome", 0, data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if third.Version != 3 || third.Subject != "Hi Jane" || len(renderer.compiled) != 2 {
+		t.Errorf("latest render = %+v with %d compiled versions", *third, len(renderer.compiled))
+	}
+}
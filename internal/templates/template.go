@@ -0,0 +1,28 @@
+package templates
+
+import (
+	"time"
+)
+
+type Template struct {
+	ID        string    `bson:"templateId" json:"templateId"`
+	Version   int       `bson:"version" json:"version"`
+	Subject   string    `bson:"subject" json:"subject"`
+	HTMLBody  string    `bson:"htmlBody,omitempty" json:"htmlBody,omitempty"`
+	TextBody  string    `bson:"textBody,omitempty" json:"textBody,omitempty"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// Store looks up templates by ID. A version of 0 selects the latest version.
+// Implementations return nil, nil when the template does not exist.
+type Store interface {
+	GetTemplate(templateID string, version int) (*Template, error)
+}
+
+type Rendered struct {
+	TemplateID string
+	Version    int
+	Subject    string
+	HTMLBody   string
+	TextBody   string
+}